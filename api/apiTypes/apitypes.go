@@ -12,16 +12,18 @@ import (
 
 // CDM structs. TODO update with Isaac Kellog's newest CDM json defs.
 type CausalDecisionModel struct {
-	ID         int                  `gorm:"primaryKey" json:"-"`
-	CreatedAt  time.Time            `json:"-"`
-	UpdatedAt  time.Time            `json:"-"`
-	Schema     string               `json:"$schema"`
-	MetaID     int                  `json:"-"`
-	Meta       Meta                 `json:"meta"`
-	ParentUUID string               `json:"parentUUID,omitempty"`
-	ParentID   *int                 `json:"-"`
-	Parent     *CausalDecisionModel `json:"-"`
-	Diagrams   []Diagram            `gorm:"many2many:cdm_diagrams" json:"diagrams,omitempty"`
+	ID                int                  `gorm:"primaryKey" json:"-"`
+	CreatedAt         time.Time            `json:"-"`
+	UpdatedAt         time.Time            `json:"-"`
+	Schema            string               `json:"$schema"`
+	MetaID            int                  `json:"-"`
+	Meta              Meta                 `json:"meta"`
+	ParentUUID        string               `json:"parentUUID,omitempty"`
+	ParentID          *int                 `json:"-"`
+	Parent            *CausalDecisionModel `json:"-"`
+	Diagrams          []Diagram            `gorm:"many2many:cdm_diagrams" json:"diagrams,omitempty"`
+	ForkedFromVersion *int                 `json:"forkedFromVersion,omitempty"` // version of the parent this model was forked or last synced from
+	SyncedAtVersion   *int                 `json:"syncedAtVersion,omitempty"`   // version of this model when it was forked or last synced
 }
 
 type Meta struct {
@@ -29,8 +31,8 @@ type Meta struct {
 	CreatedAt     time.Time       `json:"-"`
 	UpdatedAt     time.Time       `json:"-"`
 	UUID          string          `gorm:"unique" json:"uuid"`
-	Name          string          `gorm:"index:idx_name_summary,class:FULLTEXT" json:"name,omitempty"`
-	Summary       string          `gorm:"index:idx_name_summary,class:FULLTEXT" json:"summary,omitempty"`
+	Name          string          `json:"name,omitempty"` // searched with a full-text index on databases that have one
+	Summary       string          `json:"summary,omitempty"`
 	Documentation json.RawMessage `json:"documentation,omitempty"`
 	Version       string          `json:"version,omitempty"`
 	Draft         bool            `json:"draft,omitempty"`
@@ -74,6 +76,14 @@ type CausalDependency struct {
 	Target    string    `json:"target"`
 }
 
+// ForkMapping records which component of a parent model a component of a forked model was copied from.
+type ForkMapping struct {
+	ID                  int    `gorm:"primaryKey" json:"-"`
+	CDMUUID             string `gorm:"index" json:"cdmuuid"`
+	ParentComponentUUID string `json:"parentComponentUUID"`
+	ComponentUUID       string `json:"componentUUID"`
+}
+
 type User struct {
 	ID       int    `gorm:"primaryKey" json:"-"`
 	UUID     string `json:"uuid"`
@@ -90,6 +100,215 @@ type Commit struct {
 	CDMUUID        string    `json:"cdmuuid"`
 	CreatedAt      time.Time `json:"CreatedAt"`
 	Version        int       `json:"version"`
+	Tag            string    `json:"tag,omitempty"`
+	Message        string    `json:"message,omitempty"`
+}
+
+// CompactionResult reports how many commits were removed from the history of a model when it was compacted.
+type CompactionResult struct {
+	CDMUUID        string `json:"cdmuuid"`
+	RemovedCommits int    `json:"removedCommits"`
+}
+
+// Component types reported by blame.
+const (
+	BlameTypeModel      = "model"
+	BlameTypeDiagram    = "diagram"
+	BlameTypeElement    = "element"
+	BlameTypeDependency = "dependency"
+)
+
+// ModelBlame attributes each component of a model to the commits that introduced and last changed it.
+type ModelBlame struct {
+	CDMUUID    string           `json:"cdmuuid"`
+	Version    int              `json:"version"`
+	Components []ComponentBlame `json:"components"`
+}
+
+// ComponentBlame is the provenance of a single model, diagram, element or dependency, identified by its meta UUID.
+type ComponentBlame struct {
+	UUID        string     `json:"uuid"`
+	Name        string     `json:"name,omitempty"`
+	Type        string     `json:"type"`
+	DiagramUUID string     `json:"diagramUUID,omitempty"`
+	Introduced  BlameEntry `json:"introduced"`
+	LastChanged BlameEntry `json:"lastChanged"`
+}
+
+// BlameEntry identifies the version of a model that touched a component, and who made it when.
+// Version 0 is the upload of the model, attributed to its creator.
+type BlameEntry struct {
+	Version  int       `json:"version"`
+	UserUUID string    `json:"useruuid"`
+	Username string    `json:"username,omitempty"`
+	Date     time.Time `json:"date"`
+}
+
+// Changelog lists the commits between two versions of a model and the components they added, removed and changed.
+type Changelog struct {
+	CDMUUID     string            `json:"cdmuuid"`
+	FromVersion int               `json:"fromVersion"`
+	ToVersion   int               `json:"toVersion"`
+	Entries     []ChangelogEntry  `json:"entries"`
+	Added       []ComponentChange `json:"added"`
+	Removed     []ComponentChange `json:"removed"`
+	Changed     []ComponentChange `json:"changed"`
+}
+
+// ChangelogEntry describes a single commit in a changelog.
+type ChangelogEntry struct {
+	Version  int               `json:"version"`
+	Message  string            `json:"message,omitempty"`
+	Tag      string            `json:"tag,omitempty"`
+	UserUUID string            `json:"useruuid"`
+	Username string            `json:"username,omitempty"`
+	Date     time.Time         `json:"date"`
+	Added    []ComponentChange `json:"added"`
+	Removed  []ComponentChange `json:"removed"`
+	Changed  []ComponentChange `json:"changed"`
+}
+
+// ComponentChange identifies a model, diagram, element or dependency that was added, removed or changed.
+type ComponentChange struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name,omitempty"`
+	Type        string `json:"type"`
+	DiagramUUID string `json:"diagramUUID,omitempty"`
+}
+
+// UpstreamStatus compares a forked model with its parent.
+// Behind counts the commits made to the parent since the fork point, and ahead counts the commits made to the fork since then.
+type UpstreamStatus struct {
+	CDMUUID           string `json:"cdmuuid"`
+	ParentUUID        string `json:"parentUUID"`
+	ForkedFromVersion int    `json:"forkedFromVersion"`
+	ParentVersion     int    `json:"parentVersion"`
+	Ahead             int    `json:"ahead"`
+	Behind            int    `json:"behind"`
+}
+
+// SyncConflict is a component that was changed both in a fork and in its parent, so the parent's change was not applied.
+type SyncConflict struct {
+	UUID                string `json:"uuid"`
+	ParentComponentUUID string `json:"parentComponentUUID"`
+	Type                string `json:"type"`
+	Reason              string `json:"reason"`
+}
+
+// SyncResult reports the changes pulled from a parent model into a fork, and the conflicts that were left alone.
+type SyncResult struct {
+	FromParentVersion int                  `json:"fromParentVersion"`
+	ToParentVersion   int                  `json:"toParentVersion"`
+	Applied           []ComponentChange    `json:"applied"`
+	Conflicts         []SyncConflict       `json:"conflicts"`
+	Model             *CausalDecisionModel `json:"model,omitempty"`
+}
+
+// ModelSummary is a lightweight view of a model, without its diagrams, used in family graphs.
+type ModelSummary struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Draft       bool   `json:"draft,omitempty"`
+	ParentUUID  string `json:"parentUUID,omitempty"`
+	CreatedDate string `json:"createdDate,omitempty"`
+	UpdatedDate string `json:"updatedDate,omitempty"`
+}
+
+// FamilyNode is a descendant of a model along with its own descendants.
+type FamilyNode struct {
+	ModelSummary
+	Children []*FamilyNode `json:"children,omitempty"`
+}
+
+// ModelFamily holds the ancestors of a model, earliest first, and the tree of its descendants.
+// Truncated is set when the depth limit left out further descendants, and CycleDetected when the parent UUIDs loop back on themselves.
+type ModelFamily struct {
+	Model         ModelSummary   `json:"model"`
+	Ancestors     []ModelSummary `json:"ancestors"`
+	Descendants   []*FamilyNode  `json:"descendants"`
+	Truncated     bool           `json:"truncated"`
+	CycleDetected bool           `json:"cycleDetected"`
+}
+
+// ValidationIssue is a problem found when validating a model, located by a JSON pointer into the model.
+type ValidationIssue struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// ValidationReport is the result of validating a model without saving it.
+type ValidationReport struct {
+	Valid  bool              `json:"valid"`
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// LintIssue is a problem a lint rule found in a model, located by a JSON pointer into the model.
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Pointer  string `json:"pointer"`
+	UUID     string `json:"uuid,omitempty"`
+	Message  string `json:"message"`
+}
+
+// LintReport lists the lint issues of a model under the rule configuration of an organization.
+type LintReport struct {
+	CDMUUID      string         `json:"cdmuuid"`
+	Organization string         `json:"organization,omitempty"`
+	Counts       map[string]int `json:"counts"`
+	Issues       []LintIssue    `json:"issues"`
+}
+
+// LintRule describes a lint rule and the severity it runs at for an organization.
+type LintRule struct {
+	ID              string `json:"id"`
+	Description     string `json:"description"`
+	DefaultSeverity string `json:"defaultSeverity"`
+	Severity        string `json:"severity"`
+}
+
+// LintRuleConfig overrides the severity of a lint rule for an organization.
+type LintRuleConfig struct {
+	ID           int    `gorm:"primaryKey" json:"-"`
+	Organization string `gorm:"index" json:"organization"`
+	Rule         string `json:"rule"`
+	Severity     string `json:"severity"`
+}
+
+// ModelArchive is everything about a model that moves with it between hub instances: the model as it is now,
+// its commits in version order, the users that created, updated or committed to it, and the summaries of its ancestors.
+type ModelArchive struct {
+	Model   CausalDecisionModel `json:"model"`
+	Commits []Commit            `json:"commits"`
+	Users   []User              `json:"users"`
+	Lineage []ModelSummary      `json:"lineage"`
+}
+
+// BundleImport reports the model created from a bundle, and the attachments in the bundle that were not kept.
+type BundleImport struct {
+	Model              *CausalDecisionModel `json:"model"`
+	Commits            int                  `json:"commits"`
+	SkippedAttachments []string             `json:"skippedAttachments"`
+}
+
+// BulkImportResult is the outcome of importing one model of a bulk import, identified by its position in the batch.
+// UUID is the UUID the model was created with.
+type BulkImportResult struct {
+	Index  int               `json:"index"`
+	UUID   string            `json:"uuid,omitempty"`
+	Status int               `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Issues []ValidationIssue `json:"issues,omitempty"`
+}
+
+// BulkImport reports the outcome of importing a batch of models. In an atomic import either every model is created or none are.
+type BulkImport struct {
+	Atomic  bool               `json:"atomic"`
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Results []BulkImportResult `json:"results"`
 }
 
 // testing functionality for CDM equality with other CDM.
@@ -0,0 +1,415 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package bundle reads and writes model bundles, the archives models are moved between hub instances in.
+// A bundle holds the model, its commit history, the users that worked on it and its lineage as JSON files,
+// along with any attachments, and a manifest listing the size and SHA-256 checksum of every other file.
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// Format is an archive format bundles can be written in.
+type Format string
+
+const (
+	FormatTarGz Format = "tar.gz"
+	FormatZip   Format = "zip"
+)
+
+// ParseFormat returns the archive format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatTarGz, "tgz":
+		return FormatTarGz, nil
+	case FormatZip:
+		return FormatZip, nil
+	}
+	return "", fmt.Errorf("unknown bundle format %s, expected tar.gz or zip", name)
+}
+
+// ContentType returns the media type of bundles in this format.
+func (format Format) ContentType() string {
+	if format == FormatZip {
+		return "application/zip"
+	}
+	return "application/gzip"
+}
+
+// Extension returns the file extension of bundles in this format.
+func (format Format) Extension() string {
+	return string(format)
+}
+
+// Identifiers of the bundle format, recorded in every manifest.
+const (
+	ManifestFormat = "opendi-model-bundle"
+	FormatVersion  = 1
+)
+
+// Paths of the files in a bundle. Attachments are stored under AttachmentsDir.
+const (
+	ManifestPath   = "manifest.json"
+	ModelPath      = "model.json"
+	CommitsPath    = "commits.json"
+	UsersPath      = "users.json"
+	LineagePath    = "lineage.json"
+	AttachmentsDir = "attachments/"
+)
+
+// MaxExtractedSize is the most bytes Read will extract from a bundle, so a small compressed bundle can't exhaust memory.
+const MaxExtractedSize = 256 << 20
+
+// Manifest describes a bundle and the files in it.
+type Manifest struct {
+	Format        string    `json:"format"`
+	FormatVersion int       `json:"formatVersion"`
+	CDMUUID       string    `json:"cdmuuid"`
+	Name          string    `json:"name,omitempty"`
+	Version       int       `json:"version"`
+	ExportedAt    time.Time `json:"exportedAt"`
+	Files         []File    `json:"files"`
+}
+
+// File is a file in a bundle other than the manifest.
+type File struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Bundle is the content of a bundle. Attachments are keyed by their name within AttachmentsDir.
+type Bundle struct {
+	Manifest    Manifest
+	Archive     apiTypes.ModelArchive
+	Attachments map[string][]byte
+}
+
+// New returns a bundle of the given archive, exported at the given time.
+func New(archive apiTypes.ModelArchive, exportedAt time.Time) *Bundle {
+	version := 0
+	if len(archive.Commits) > 0 {
+		version = archive.Commits[len(archive.Commits)-1].Version
+	}
+
+	return &Bundle{
+		Manifest: Manifest{
+			Format:        ManifestFormat,
+			FormatVersion: FormatVersion,
+			CDMUUID:       archive.Model.Meta.UUID,
+			Name:          archive.Model.Meta.Name,
+			Version:       version,
+			ExportedAt:    exportedAt.UTC(),
+		},
+		Archive:     archive,
+		Attachments: map[string][]byte{},
+	}
+}
+
+// entry is a file to be written to an archive.
+type entry struct {
+	path string
+	data []byte
+}
+
+// files returns the files of the bundle in the order they are written, with the manifest first.
+// The file list of the manifest is filled in from the other files.
+func (bundle *Bundle) files() ([]entry, error) {
+	var entries []entry
+	add := func(path string, value any) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{path, data})
+		return nil
+	}
+
+	commits := bundle.Archive.Commits
+	if commits == nil {
+		commits = []apiTypes.Commit{}
+	}
+	users := bundle.Archive.Users
+	if users == nil {
+		users = []apiTypes.User{}
+	}
+	lineage := bundle.Archive.Lineage
+	if lineage == nil {
+		lineage = []apiTypes.ModelSummary{}
+	}
+	for _, file := range []struct {
+		path  string
+		value any
+	}{
+		{ModelPath, bundle.Archive.Model},
+		{CommitsPath, commits},
+		{UsersPath, users},
+		{LineagePath, lineage},
+	} {
+		if err := add(file.path, file.value); err != nil {
+			return nil, err
+		}
+	}
+
+	var names []string
+	for name := range bundle.Attachments {
+		if err := checkAttachmentName(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entries = append(entries, entry{AttachmentsDir + name, bundle.Attachments[name]})
+	}
+
+	bundle.Manifest.Files = nil
+	for _, e := range entries {
+		bundle.Manifest.Files = append(bundle.Manifest.Files, File{Path: e.path, Size: len(e.data), SHA256: checksum(e.data)})
+	}
+	manifest, err := json.MarshalIndent(bundle.Manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]entry{{ManifestPath, manifest}}, entries...), nil
+}
+
+// Write writes the bundle to w as an archive in the given format.
+func (bundle *Bundle) Write(w io.Writer, format Format) error {
+	entries, err := bundle.files()
+	if err != nil {
+		return err
+	}
+	modified := bundle.Manifest.ExportedAt
+
+	if format == FormatZip {
+		archive := zip.NewWriter(w)
+		for _, e := range entries {
+			file, err := archive.CreateHeader(&zip.FileHeader{Name: e.path, Method: zip.Deflate, Modified: modified})
+			if err != nil {
+				return err
+			}
+			if _, err := file.Write(e.data); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	}
+
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.path, Mode: 0644, Size: int64(len(e.data)), ModTime: modified, Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(e.data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// Read reads a bundle written in either format, which is told apart by its leading bytes.
+// Every file is checked against the checksums in the manifest, and files the manifest doesn't list are rejected.
+func Read(r io.Reader) (*Bundle, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxExtractedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxExtractedSize {
+		return nil, errors.New("bundle is too large")
+	}
+
+	var files map[string][]byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		files, err = readTarGz(data)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		files, err = readZip(data)
+	default:
+		err = errors.New("bundle is neither a tar.gz nor a zip archive")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifestData, ok := files[ManifestPath]
+	if !ok {
+		return nil, errors.New("bundle has no manifest")
+	}
+	bundle := &Bundle{Attachments: map[string][]byte{}}
+	if err := json.Unmarshal(manifestData, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err.Error())
+	}
+	if bundle.Manifest.Format != ManifestFormat {
+		return nil, fmt.Errorf("not a model bundle: format is %q", bundle.Manifest.Format)
+	}
+	if bundle.Manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", bundle.Manifest.FormatVersion)
+	}
+	delete(files, ManifestPath)
+
+	listed := map[string]bool{}
+	for _, file := range bundle.Manifest.Files {
+		data, ok := files[file.Path]
+		if !ok {
+			return nil, fmt.Errorf("%s is listed in the manifest but missing from the bundle", file.Path)
+		}
+		if len(data) != file.Size || checksum(data) != file.SHA256 {
+			return nil, fmt.Errorf("%s does not match its checksum", file.Path)
+		}
+		listed[file.Path] = true
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, fmt.Errorf("%s is not listed in the manifest", name)
+		}
+	}
+
+	for _, file := range []struct {
+		path  string
+		value any
+	}{
+		{ModelPath, &bundle.Archive.Model},
+		{CommitsPath, &bundle.Archive.Commits},
+		{UsersPath, &bundle.Archive.Users},
+		{LineagePath, &bundle.Archive.Lineage},
+	} {
+		data, ok := files[file.path]
+		if !ok {
+			return nil, fmt.Errorf("bundle has no %s", file.path)
+		}
+		if err := json.Unmarshal(data, file.value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", file.path, err.Error())
+		}
+		delete(files, file.path)
+	}
+	if bundle.Archive.Model.Meta.UUID != bundle.Manifest.CDMUUID {
+		return nil, errors.New("the model in the bundle is not the one named by its manifest")
+	}
+
+	for name, data := range files {
+		attachment, ok := strings.CutPrefix(name, AttachmentsDir)
+		if !ok || checkAttachmentName(attachment) != nil {
+			return nil, fmt.Errorf("unexpected file %s in bundle", name)
+		}
+		bundle.Attachments[attachment] = data
+	}
+
+	return bundle, nil
+}
+
+// readTarGz extracts the regular files of a gzipped tar archive.
+func readTarGz(data []byte) (map[string][]byte, error) {
+	compressed, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	archive := tar.NewReader(compressed)
+
+	files := map[string][]byte{}
+	extracted := 0
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%s is not a regular file", header.Name)
+		}
+		if err := addFile(files, header.Name, archive, &extracted); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readZip extracts the files of a zip archive.
+func readZip(data []byte) (map[string][]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	extracted := 0
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		content, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = addFile(files, file.Name, content, &extracted)
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// addFile reads a file from an archive into files, keeping the total extracted under MaxExtractedSize.
+func addFile(files map[string][]byte, name string, r io.Reader, extracted *int) error {
+	name = strings.TrimPrefix(name, "./")
+	if name != path.Clean(name) || path.IsAbs(name) || strings.HasPrefix(name, "../") {
+		return fmt.Errorf("invalid path %s in bundle", name)
+	}
+	if _, ok := files[name]; ok {
+		return fmt.Errorf("%s appears more than once in bundle", name)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, int64(MaxExtractedSize-*extracted+1)))
+	if err != nil {
+		return err
+	}
+	*extracted += len(data)
+	if *extracted > MaxExtractedSize {
+		return errors.New("bundle is too large")
+	}
+
+	files[name] = data
+	return nil
+}
+
+// checkAttachmentName checks that an attachment is stored directly in AttachmentsDir.
+func checkAttachmentName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("invalid attachment name %q", name)
+	}
+	return nil
+}
+
+// checksum returns the hex encoded SHA-256 checksum of data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,157 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"opendi/model-hub/api/apiTypes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testArchive() apiTypes.ModelArchive {
+	return apiTypes.ModelArchive{
+		Model: apiTypes.CausalDecisionModel{
+			Schema: "https://opendi.org/schemas/cdm/v1/cdm.schema.json",
+			Meta: apiTypes.Meta{
+				UUID:    "model-uuid",
+				Name:    "Bundled Model",
+				Creator: apiTypes.User{UUID: "user-uuid", Username: "creator", Email: "creator@example.com"},
+			},
+			ParentUUID: "parent-uuid",
+		},
+		Commits: []apiTypes.Commit{
+			{Diff: `[{"op":"replace","path":"/meta/name","value":"Bundled Model"}]`, UserUUID: "user-uuid", CDMUUID: "model-uuid", Version: 1, Tag: "v1"},
+			{ParentCommitID: "1", Diff: `[]`, UserUUID: "user-uuid", CDMUUID: "model-uuid", Version: 2, Message: "second"},
+		},
+		Users:   []apiTypes.User{{UUID: "user-uuid", Username: "creator", Email: "creator@example.com"}},
+		Lineage: []apiTypes.ModelSummary{{UUID: "parent-uuid", Name: "Parent"}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	exportedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, format := range []Format{FormatTarGz, FormatZip} {
+		written := New(testArchive(), exportedAt)
+		written.Attachments["notes.txt"] = []byte("some notes")
+
+		var out bytes.Buffer
+		assert.NoError(t, written.Write(&out, format), format)
+
+		read, err := Read(&out)
+		if !assert.NoError(t, err, format) {
+			continue
+		}
+		assert.Equal(t, testArchive(), read.Archive, format)
+		assert.Equal(t, map[string][]byte{"notes.txt": []byte("some notes")}, read.Attachments, format)
+		assert.Equal(t, "model-uuid", read.Manifest.CDMUUID, format)
+		assert.Equal(t, 2, read.Manifest.Version, format)
+		assert.Equal(t, exportedAt, read.Manifest.ExportedAt, format)
+
+		var paths []string
+		for _, file := range read.Manifest.Files {
+			paths = append(paths, file.Path)
+			assert.Len(t, file.SHA256, 64)
+		}
+		assert.Equal(t, []string{ModelPath, CommitsPath, UsersPath, LineagePath, "attachments/notes.txt"}, paths, format)
+	}
+}
+
+func TestWriteIsDeterministic(t *testing.T) {
+	exportedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var first, second bytes.Buffer
+	assert.NoError(t, New(testArchive(), exportedAt).Write(&first, FormatTarGz))
+	assert.NoError(t, New(testArchive(), exportedAt).Write(&second, FormatTarGz))
+	assert.Equal(t, first.Bytes(), second.Bytes())
+}
+
+// writeTarGz writes the given files, in order, as a tar.gz archive.
+func writeTarGz(t *testing.T, files ...[2]string) []byte {
+	var out bytes.Buffer
+	compressed := gzip.NewWriter(&out)
+	archive := tar.NewWriter(compressed)
+	for _, file := range files {
+		assert.NoError(t, archive.WriteHeader(&tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), Typeflag: tar.TypeReg}))
+		_, err := archive.Write([]byte(file[1]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, archive.Close())
+	assert.NoError(t, compressed.Close())
+	return out.Bytes()
+}
+
+// validFiles returns the files of a valid bundle of testArchive, manifest first.
+func validFiles(t *testing.T) ([][2]string, Manifest) {
+	bundle := New(testArchive(), time.Now())
+	entries, err := bundle.files()
+	assert.NoError(t, err)
+
+	var files [][2]string
+	for _, e := range entries {
+		files = append(files, [2]string{e.path, string(e.data)})
+	}
+	return files, bundle.Manifest
+}
+
+func TestReadRejectsInvalidBundles(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("not an archive")))
+	assert.ErrorContains(t, err, "neither a tar.gz nor a zip")
+
+	files, _ := validFiles(t)
+	_, err = Read(bytes.NewReader(writeTarGz(t, files[1:]...)))
+	assert.ErrorContains(t, err, "no manifest")
+
+	// a file changed after the manifest was written
+	files, _ = validFiles(t)
+	files[1][1] = `{"$schema":"","meta":{"uuid":"model-uuid","name":"Changed"}}`
+	_, err = Read(bytes.NewReader(writeTarGz(t, files...)))
+	assert.ErrorContains(t, err, "model.json does not match its checksum")
+
+	// a file the manifest doesn't list
+	files, _ = validFiles(t)
+	files = append(files, [2]string{"attachments/extra.txt", "extra"})
+	_, err = Read(bytes.NewReader(writeTarGz(t, files...)))
+	assert.ErrorContains(t, err, "attachments/extra.txt is not listed in the manifest")
+
+	// a file the manifest lists but the archive lacks
+	files, _ = validFiles(t)
+	_, err = Read(bytes.NewReader(writeTarGz(t, files[:len(files)-1]...)))
+	assert.ErrorContains(t, err, "lineage.json is listed in the manifest but missing")
+
+	// paths escaping the bundle
+	files, _ = validFiles(t)
+	files = append(files, [2]string{"../escape.txt", "escape"})
+	_, err = Read(bytes.NewReader(writeTarGz(t, files...)))
+	assert.ErrorContains(t, err, "invalid path")
+
+	// a newer format version
+	files, manifest := validFiles(t)
+	manifest.FormatVersion = FormatVersion + 1
+	manifestData, _ := json.Marshal(manifest)
+	files[0][1] = string(manifestData)
+	_, err = Read(bytes.NewReader(writeTarGz(t, files...)))
+	assert.ErrorContains(t, err, "unsupported bundle format version")
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("ZIP")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatZip, format)
+
+	format, err = ParseFormat("tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatTarGz, format)
+
+	_, err = ParseFormat("rar")
+	assert.Error(t, err)
+}
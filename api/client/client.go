@@ -0,0 +1,240 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package client is a typed Go client for the model hub API, for services that embed the hub.
+// It has a method for every route of the API, taking a context and returning the apiTypes the hub responds with.
+// Requests that are safe to repeat are retried when the hub is unreachable or overloaded. Error responses are
+// returned as an *Error, which can be matched against ErrNotFound and the other sentinel errors with errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// Error is an error response of the hub, with the message of its {"Error": ...} body.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is reports whether target is the sentinel error for the status code of e.
+func (e *Error) Is(target error) bool {
+	var sentinel *Error
+	return errors.As(target, &sentinel) && sentinel.Message == "" && sentinel.StatusCode == e.StatusCode
+}
+
+// Sentinel errors for the status codes the hub responds with, to use with errors.Is.
+var (
+	ErrBadRequest          = &Error{StatusCode: http.StatusBadRequest}
+	ErrUnauthorized        = &Error{StatusCode: http.StatusUnauthorized}
+	ErrNotFound            = &Error{StatusCode: http.StatusNotFound}
+	ErrConflict            = &Error{StatusCode: http.StatusConflict}
+	ErrUnprocessableEntity = &Error{StatusCode: http.StatusUnprocessableEntity}
+	ErrInternalServerError = &Error{StatusCode: http.StatusInternalServerError}
+)
+
+// Client sends requests to a model hub. Its methods are safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	email      string
+	password   string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client requests are sent with, http.DefaultClient by default.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a request that is safe to repeat is retried, 2 by default.
+// The wait before each retry doubles, starting at backoff, unless the hub asks for a longer one with Retry-After.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithCredentials sets the email and password of the user requests are sent as, with HTTP basic auth.
+// Routes that rewrite the history of a model require the credentials of its creator.
+func WithCredentials(email, password string) Option {
+	return func(c *Client) {
+		c.email = email
+		c.password = password
+	}
+}
+
+// New returns a client for the hub at baseURL, such as http://localhost:8080.
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retries:    2,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// request is a request to the hub. Responses with a status in accept are successful even if they are errors,
+// for routes that report failures in their usual response body.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	accept      []int
+}
+
+// retryable reports whether a response with the given status is worth retrying.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// do sends a request, retrying it if it is a GET, and returns the body of a successful response.
+func (c *Client) do(ctx context.Context, req *request) ([]byte, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	wait := c.backoff
+	for tries := 0; ; tries++ {
+		data, retryAfter, err := c.attempt(ctx, req, target)
+		if err == nil || req.method != http.MethodGet || tries >= c.retries || ctx.Err() != nil {
+			return data, err
+		}
+		var apiErr *Error
+		if errors.As(err, &apiErr) && !retryable(apiErr.StatusCode) {
+			return nil, err
+		}
+
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// attempt sends a request once. Returns how long the hub asked to wait before retrying, if it did.
+func (c *Client) attempt(ctx context.Context, req *request, target string) ([]byte, time.Duration, error) {
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if c.email != "" {
+		httpReq.SetBasicAuth(c.email, c.password)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode < 300 {
+		return data, 0, nil
+	}
+	for _, status := range req.accept {
+		if resp.StatusCode == status {
+			return data, 0, nil
+		}
+	}
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	var errorBody struct {
+		Error string
+	}
+	if json.Unmarshal(data, &errorBody) != nil || errorBody.Error == "" {
+		errorBody.Error = http.StatusText(resp.StatusCode)
+	}
+	return nil, retryAfter, &Error{StatusCode: resp.StatusCode, Message: errorBody.Error}
+}
+
+// call sends a request and decodes its JSON response as a T.
+func call[T any](ctx context.Context, c *Client, req *request) (T, error) {
+	var out T
+	data, err := c.do(ctx, req)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("could not decode response of %s %s: %s", req.method, req.path, err.Error())
+	}
+	return out, nil
+}
+
+// get sends a GET request and decodes its JSON response as a T.
+func get[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	return call[T](ctx, c, &request{method: http.MethodGet, path: path, query: query})
+}
+
+// send sends a request with value as its JSON body and decodes its JSON response as a T.
+func send[T any](ctx context.Context, c *Client, method, path string, query url.Values, value any) (T, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		var out T
+		return out, err
+	}
+	return call[T](ctx, c, &request{method: method, path: path, query: query, body: body, contentType: "application/json"})
+}
+
+// Login checks the password of the user with the given email and returns the user.
+// The hub creates users that don't exist yet on their first login.
+func (c *Client) Login(ctx context.Context, email, password string) (*apiTypes.User, error) {
+	query := url.Values{"email": {email}, "password": {password}}
+	return call[*apiTypes.User](ctx, c, &request{method: http.MethodPost, path: "/login", query: query})
+}
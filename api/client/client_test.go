@@ -0,0 +1,248 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/bundle"
+	"opendi/model-hub/api/export"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a test hub that answers every request with the same response and records the requests it got.
+type recorder struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+	status   int
+	body     string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.RequestURI())
+	r.bodies = append(r.bodies, string(body))
+	r.mu.Unlock()
+
+	w.WriteHeader(r.status)
+	io.WriteString(w, r.body)
+}
+
+// newTestClient starts a recorder answering with the given status and body, and returns a client for it that
+// retries without waiting.
+func newTestClient(t *testing.T, status int, body string) (*Client, *recorder) {
+	hub := &recorder{status: status, body: body}
+	server := httptest.NewServer(hub)
+	t.Cleanup(server.Close)
+	return New(server.URL+"/", WithRetries(2, time.Millisecond)), hub
+}
+
+func TestRoutes(t *testing.T) {
+	ctx := context.Background()
+	asOf := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	model := &apiTypes.CausalDecisionModel{Meta: apiTypes.Meta{UUID: "m", Name: "Model"}}
+
+	tests := []struct {
+		request string
+		body    string
+		call    func(c *Client) error
+	}{
+		{"GET /v0/models", "[]", func(c *Client) error { _, err := c.GetModels(ctx); return err }},
+		{"GET /v0/models/m%20n", "{}", func(c *Client) error { _, err := c.GetModelByUUID(ctx, "m n"); return err }},
+		{"GET /v0/models/m?asOf=2025-01-02T03%3A04%3A05Z", "{}", func(c *Client) error { _, err := c.GetModelAsOf(ctx, "m", asOf); return err }},
+		{"GET /v0/models/modelVersion/m/3", "{}", func(c *Client) error { _, err := c.GetVersionOfModel(ctx, "m", 3); return err }},
+		{"POST /v0/models", "{}", func(c *Client) error { _, err := c.UploadModel(ctx, model); return err }},
+		{"PUT /v0/models?message=rename", "{}", func(c *Client) error { _, err := c.PutModel(ctx, model, "rename"); return err }},
+		{"POST /v0/models/validate", "{}", func(c *Client) error { _, err := c.ValidateModel(ctx, []byte(`{}`)); return err }},
+		{"POST /v0/models/bulk?atomic=true&provisionCreators=false", "{}", func(c *Client) error {
+			_, err := c.BulkImportModels(ctx, []apiTypes.CausalDecisionModel{*model}, BulkImportOptions{Atomic: true})
+			return err
+		}},
+		{"POST /v0/models?email=a%40example.com", "{}", func(c *Client) error {
+			_, err := c.ImportDMN(ctx, strings.NewReader("<definitions/>"), "a@example.com")
+			return err
+		}},
+		{"GET /v0/models/m/dmn", "<definitions/>", func(c *Client) error { _, err := c.ExportDMN(ctx, "m"); return err }},
+		{"GET /v0/models/m/bundle?format=zip", "", func(c *Client) error { _, err := c.ExportModelBundle(ctx, "m", bundle.FormatZip); return err }},
+		{"POST /v0/models/bundle", "{}", func(c *Client) error { _, err := c.ImportModelBundle(ctx, strings.NewReader("bundle")); return err }},
+		{"GET /v0/models/lineage/m", "[]", func(c *Client) error { _, err := c.GetModelLineage(ctx, "m"); return err }},
+		{"GET /v0/models/lineage/m?asOf=2025-01-02T03%3A04%3A05Z", "[]", func(c *Client) error { _, err := c.GetModelLineageAsOf(ctx, "m", asOf); return err }},
+		{"GET /v0/models/children/m", "[]", func(c *Client) error { _, err := c.GetModelChildren(ctx, "m"); return err }},
+		{"GET /v0/models/children/m?asOf=2025-01-02T03%3A04%3A05Z", "[]", func(c *Client) error { _, err := c.GetModelChildrenAsOf(ctx, "m", asOf); return err }},
+		{"GET /v0/models/m/family", "{}", func(c *Client) error { _, err := c.GetModelFamily(ctx, "m", -1); return err }},
+		{"GET /v0/models/m/family?depth=0", "{}", func(c *Client) error { _, err := c.GetModelFamily(ctx, "m", 0); return err }},
+		{"GET /v0/models/m/blame", "{}", func(c *Client) error { _, err := c.GetModelBlame(ctx, "m"); return err }},
+		{"GET /v0/models/m/changelog?format=json&from=1&to=2", "{}", func(c *Client) error { _, err := c.GetModelChangelog(ctx, "m", 1, 2); return err }},
+		{"GET /v0/models/m/changelog?format=markdown&from=1&to=2", "# Changelog", func(c *Client) error {
+			_, err := c.GetModelChangelogMarkdown(ctx, "m", 1, 2)
+			return err
+		}},
+		{"POST /v0/models/m/fork?name=Fork&version=2", "{}", func(c *Client) error {
+			_, err := c.ForkModel(ctx, "m", ForkOptions{Version: 2, Name: "Fork"})
+			return err
+		}},
+		{"GET /v0/models/m/upstream", "{}", func(c *Client) error { _, err := c.GetUpstreamStatus(ctx, "m"); return err }},
+		{"POST /v0/models/m/upstream/sync", "{}", func(c *Client) error { _, err := c.SyncWithParent(ctx, "m"); return err }},
+		{"GET /v0/models/m/lint?organization=acme", "{}", func(c *Client) error { _, err := c.LintModel(ctx, "m", "acme"); return err }},
+		{"GET /v0/models/m/diagrams/d/graph/topological-order", "[]", func(c *Client) error { _, err := c.GetTopologicalOrder(ctx, "m", "d"); return err }},
+		{"GET /v0/models/m/diagrams/d/graph/elements/e/upstream", "[]", func(c *Client) error { _, err := c.GetUpstreamElements(ctx, "m", "d", "e"); return err }},
+		{"GET /v0/models/m/diagrams/d/graph/elements/e/downstream", "[]", func(c *Client) error { _, err := c.GetDownstreamElements(ctx, "m", "d", "e"); return err }},
+		{"GET /v0/models/m/diagrams/d/graph/paths?from=a&limit=5&to=b", "[]", func(c *Client) error {
+			_, err := c.GetElementPaths(ctx, "m", "d", "a", "b", 5)
+			return err
+		}},
+		{"GET /v0/models/m/diagrams/d/graph/components", "[]", func(c *Client) error { _, err := c.GetStronglyConnectedComponents(ctx, "m", "d"); return err }},
+		{"GET /v0/models/m/diagrams/d/export?format=mermaid", "graph", func(c *Client) error {
+			_, err := c.ExportDiagram(ctx, "m", "d", export.FormatMermaid)
+			return err
+		}},
+		{"GET /v0/models/m/export?format=dot", "digraph", func(c *Client) error { _, err := c.ExportModel(ctx, "m", export.FormatDOT); return err }},
+		{"GET /v0/models/m/thumbnail.svg", "<svg/>", func(c *Client) error { _, err := c.GetModelThumbnail(ctx, "m"); return err }},
+		{"GET /v0/models/search/model/fraud%20model", "[]", func(c *Client) error { _, err := c.SearchModelsByName(ctx, "fraud model"); return err }},
+		{"GET /v0/models/search/user/ann", "[]", func(c *Client) error { _, err := c.SearchModelsByUser(ctx, "ann"); return err }},
+		{"GET /v0/commits", "[]", func(c *Client) error { _, err := c.GetCommits(ctx); return err }},
+		{"GET /v0/commits/m", "{}", func(c *Client) error { _, err := c.GetLatestCommitByModelUUID(ctx, "m"); return err }},
+		{"GET /v0/commits/model/m", "[]", func(c *Client) error { _, err := c.GetCommitsByModelUUID(ctx, "m"); return err }},
+		{"PUT /v0/commits/model/m/2/tag?tag=v2", "{}", func(c *Client) error { _, err := c.TagCommit(ctx, "m", 2, "v2"); return err }},
+		{"POST /v0/commits/model/m/squash?from=1&to=3", "{}", func(c *Client) error { _, err := c.SquashCommits(ctx, "m", 1, 3); return err }},
+		{"POST /v0/commits/model/m/compact?olderThan=720h0m0s", "{}", func(c *Client) error {
+			_, err := c.CompactModelHistory(ctx, "m", 720*time.Hour)
+			return err
+		}},
+		{"GET /v0/lint/rules", "[]", func(c *Client) error { _, err := c.GetLintRules(ctx, ""); return err }},
+		{"PUT /v0/lint/rules/acme", "[]", func(c *Client) error {
+			_, err := c.SetLintRules(ctx, "acme", map[string]string{"r": "off"})
+			return err
+		}},
+		{"POST /login?email=a%40example.com&password=secret", "{}", func(c *Client) error { _, err := c.Login(ctx, "a@example.com", "secret"); return err }},
+	}
+
+	for _, test := range tests {
+		client, hub := newTestClient(t, http.StatusOK, test.body)
+		assert.NoError(t, test.call(client), test.request)
+		assert.Equal(t, []string{test.request}, hub.requests)
+	}
+}
+
+func TestDecodesResponses(t *testing.T) {
+	client, hub := newTestClient(t, http.StatusCreated, `{"$schema": "schema", "meta": {"uuid": "new-uuid", "name": "Model"}}`)
+
+	model := &apiTypes.CausalDecisionModel{Schema: "schema", Meta: apiTypes.Meta{Name: "Model", Creator: apiTypes.User{Email: "a@example.com"}}}
+	created, err := client.UploadModel(context.Background(), model)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-uuid", created.Meta.UUID)
+	assert.JSONEq(t, `{"$schema": "schema", "meta": {"uuid": "", "name": "Model", "creator": {"uuid": "", "username": "", "email": "a@example.com"}}}`, hub.bodies[0])
+
+	client, _ = newTestClient(t, http.StatusOK, `[{"version": 2, "tag": "v2"}, {"version": 1}]`)
+	commits, err := client.GetCommitsByModelUUID(context.Background(), "m")
+	assert.NoError(t, err)
+	assert.Equal(t, []apiTypes.Commit{{Version: 2, Tag: "v2"}, {Version: 1}}, commits)
+
+	client, _ = newTestClient(t, http.StatusOK, `not json`)
+	_, err = client.GetModels(context.Background())
+	assert.ErrorContains(t, err, "could not decode response of GET /v0/models")
+}
+
+func TestCredentials(t *testing.T) {
+	var email, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, password, _ = r.BasicAuth()
+		io.WriteString(w, `{}`)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithCredentials("a@example.com", "secret")).TagCommit(context.Background(), "m", 1, "v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "a@example.com", email)
+	assert.Equal(t, "secret", password)
+}
+
+func TestErrors(t *testing.T) {
+	client, _ := newTestClient(t, http.StatusNotFound, `{"Error": "model not found"}`)
+	_, err := client.GetModelByUUID(context.Background(), "missing")
+
+	var apiErr *Error
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, &Error{StatusCode: http.StatusNotFound, Message: "model not found"}, apiErr)
+	assert.EqualError(t, err, "model not found (404 Not Found)")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrConflict)
+
+	client, _ = newTestClient(t, http.StatusInternalServerError, `<html>oops</html>`)
+	_, err = client.GetModels(context.Background())
+	assert.EqualError(t, err, "Internal Server Error (500 Internal Server Error)")
+	assert.ErrorIs(t, err, ErrInternalServerError)
+}
+
+func TestBulkImportReportsFailures(t *testing.T) {
+	client, _ := newTestClient(t, http.StatusUnprocessableEntity,
+		`{"atomic": false, "created": 0, "failed": 1, "results": [{"index": 0, "status": 409, "error": "could not find creator"}]}`)
+
+	report, err := client.BulkImportModels(context.Background(), []apiTypes.CausalDecisionModel{{}}, BulkImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, "could not find creator", report.Results[0].Error)
+}
+
+func TestRetries(t *testing.T) {
+	// GETs are retried until the hub recovers
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `[]`)
+	}))
+	defer server.Close()
+	_, err := New(server.URL, WithRetries(2, time.Millisecond)).GetModels(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, failures)
+
+	// but only as many times as configured
+	client, hub := newTestClient(t, http.StatusBadGateway, `{"Error": "bad gateway"}`)
+	_, err = client.GetModels(context.Background())
+	assert.ErrorContains(t, err, "bad gateway")
+	assert.Len(t, hub.requests, 3)
+
+	// requests that change the hub are never repeated
+	client, hub = newTestClient(t, http.StatusServiceUnavailable, `{"Error": "unavailable"}`)
+	_, err = client.UploadModel(context.Background(), &apiTypes.CausalDecisionModel{})
+	assert.Error(t, err)
+	assert.Len(t, hub.requests, 1)
+
+	// nor are errors that would happen again
+	client, hub = newTestClient(t, http.StatusNotFound, `{"Error": "model not found"}`)
+	_, err = client.GetModelByUUID(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Len(t, hub.requests, 1)
+}
+
+func TestContext(t *testing.T) {
+	hub := &recorder{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	// a cancelled context stops the wait for a retry
+	client := New(server.URL, WithRetries(5, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.GetModels(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Len(t, hub.requests, 1)
+}
@@ -0,0 +1,69 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// GetCommits returns every commit of every model.
+func (c *Client) GetCommits(ctx context.Context) ([]apiTypes.Commit, error) {
+	return get[[]apiTypes.Commit](ctx, c, "/v0/commits", nil)
+}
+
+// GetLatestCommitByModelUUID returns the commit of the latest version of a model.
+func (c *Client) GetLatestCommitByModelUUID(ctx context.Context, uuid string) (*apiTypes.Commit, error) {
+	return get[*apiTypes.Commit](ctx, c, "/v0/commits/"+url.PathEscape(uuid), nil)
+}
+
+// GetCommitsByModelUUID returns the commits of a model, newest first.
+func (c *Client) GetCommitsByModelUUID(ctx context.Context, uuid string) ([]apiTypes.Commit, error) {
+	return get[[]apiTypes.Commit](ctx, c, "/v0/commits/model/"+url.PathEscape(uuid), nil)
+}
+
+// TagCommit tags the commit of a version of a model.
+func (c *Client) TagCommit(ctx context.Context, uuid string, version int, tag string) (*apiTypes.Commit, error) {
+	path := fmt.Sprintf("/v0/commits/model/%s/%d/tag", url.PathEscape(uuid), version)
+	return call[*apiTypes.Commit](ctx, c, &request{method: http.MethodPut, path: path, query: url.Values{"tag": {tag}}})
+}
+
+// SquashCommits replaces the commits of the versions from from to to of a model with a single commit, renumbering
+// later versions.
+func (c *Client) SquashCommits(ctx context.Context, uuid string, from, to int) (*apiTypes.CompactionResult, error) {
+	query := url.Values{"from": {strconv.Itoa(from)}, "to": {strconv.Itoa(to)}}
+	path := "/v0/commits/model/" + url.PathEscape(uuid) + "/squash"
+	return call[*apiTypes.CompactionResult](ctx, c, &request{method: http.MethodPost, path: path, query: query})
+}
+
+// CompactModelHistory squashes the commits of a model older than the given age into snapshots, preserving
+// tagged versions.
+func (c *Client) CompactModelHistory(ctx context.Context, uuid string, olderThan time.Duration) (*apiTypes.CompactionResult, error) {
+	path := "/v0/commits/model/" + url.PathEscape(uuid) + "/compact"
+	query := url.Values{"olderThan": {olderThan.String()}}
+	return call[*apiTypes.CompactionResult](ctx, c, &request{method: http.MethodPost, path: path, query: query})
+}
+
+// GetLintRules returns the lint rules, with their severities as configured for the given organization if it isn't
+// empty.
+func (c *Client) GetLintRules(ctx context.Context, organization string) ([]apiTypes.LintRule, error) {
+	query := url.Values{}
+	if organization != "" {
+		query.Set("organization", organization)
+	}
+	return get[[]apiTypes.LintRule](ctx, c, "/v0/lint/rules", query)
+}
+
+// SetLintRules sets the severity of lint rules for an organization, keyed by rule ID, and returns the rules as
+// configured for it. The client must be created WithCredentials of a member of the organization.
+func (c *Client) SetLintRules(ctx context.Context, organization string, severities map[string]string) ([]apiTypes.LintRule, error) {
+	return send[[]apiTypes.LintRule](ctx, c, http.MethodPut, "/v0/lint/rules/"+url.PathEscape(organization), nil, severities)
+}
@@ -0,0 +1,286 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/bundle"
+	"opendi/model-hub/api/export"
+)
+
+// modelPath returns the path of a model route, with uuid escaped.
+func modelPath(uuid string, rest string) string {
+	return "/v0/models/" + url.PathEscape(uuid) + rest
+}
+
+// asOfQuery returns the query of a route reconstructing the past at asOf.
+func asOfQuery(asOf time.Time) url.Values {
+	return url.Values{"asOf": {asOf.Format(time.RFC3339)}}
+}
+
+// GetModels returns every model.
+func (c *Client) GetModels(ctx context.Context) ([]apiTypes.CausalDecisionModel, error) {
+	return get[[]apiTypes.CausalDecisionModel](ctx, c, "/v0/models", nil)
+}
+
+// GetModelByUUID returns the latest version of a model.
+func (c *Client) GetModelByUUID(ctx context.Context, uuid string) (*apiTypes.CausalDecisionModel, error) {
+	return get[*apiTypes.CausalDecisionModel](ctx, c, modelPath(uuid, ""), nil)
+}
+
+// GetModelAsOf returns a model as it was at the given time.
+func (c *Client) GetModelAsOf(ctx context.Context, uuid string, asOf time.Time) (*apiTypes.CausalDecisionModel, error) {
+	return get[*apiTypes.CausalDecisionModel](ctx, c, modelPath(uuid, ""), asOfQuery(asOf))
+}
+
+// GetVersionOfModel returns the given version of a model.
+func (c *Client) GetVersionOfModel(ctx context.Context, uuid string, version int) (*apiTypes.CausalDecisionModel, error) {
+	path := fmt.Sprintf("/v0/models/modelVersion/%s/%d", url.PathEscape(uuid), version)
+	return get[*apiTypes.CausalDecisionModel](ctx, c, path, nil)
+}
+
+// UploadModel creates a model. The hub gives it a new UUID and matches its creator to a user by email.
+func (c *Client) UploadModel(ctx context.Context, model *apiTypes.CausalDecisionModel) (*apiTypes.CausalDecisionModel, error) {
+	return send[*apiTypes.CausalDecisionModel](ctx, c, http.MethodPost, "/v0/models", nil, model)
+}
+
+// PutModel updates a model, recording message on the commit of the new version if it isn't empty.
+func (c *Client) PutModel(ctx context.Context, model *apiTypes.CausalDecisionModel, message string) (*apiTypes.CausalDecisionModel, error) {
+	query := url.Values{}
+	if message != "" {
+		query.Set("message", message)
+	}
+	return send[*apiTypes.CausalDecisionModel](ctx, c, http.MethodPut, "/v0/models", query, model)
+}
+
+// ValidateModel checks a model against its schema and its references without storing it. The model is given as
+// JSON, since a model that breaks its schema may not fit apiTypes.CausalDecisionModel.
+func (c *Client) ValidateModel(ctx context.Context, model json.RawMessage) (*apiTypes.ValidationReport, error) {
+	return call[*apiTypes.ValidationReport](ctx, c, &request{
+		method: http.MethodPost, path: "/v0/models/validate", body: model, contentType: "application/json",
+	})
+}
+
+// BulkImportOptions configures BulkImportModels.
+// Atomic creates either every model or none of them.
+// ProvisionCreators creates the creators of models that don't have an account yet, instead of rejecting the models.
+type BulkImportOptions struct {
+	Atomic            bool
+	ProvisionCreators bool
+}
+
+// BulkImportModels creates a batch of models and reports the outcome for each of them. A batch in which no model
+// was created is not an error; its results say why.
+func (c *Client) BulkImportModels(ctx context.Context, models []apiTypes.CausalDecisionModel, options BulkImportOptions) (*apiTypes.BulkImport, error) {
+	body, err := json.Marshal(models)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{
+		"atomic":            {strconv.FormatBool(options.Atomic)},
+		"provisionCreators": {strconv.FormatBool(options.ProvisionCreators)},
+	}
+	return call[*apiTypes.BulkImport](ctx, c, &request{
+		method: http.MethodPost, path: "/v0/models/bulk", query: query, body: body, contentType: "application/json",
+		accept: []int{http.StatusUnprocessableEntity},
+	})
+}
+
+// ImportDMN creates a model from a DMN file, created by the user with the given email.
+func (c *Client) ImportDMN(ctx context.Context, dmn io.Reader, email string) (*apiTypes.CausalDecisionModel, error) {
+	body, err := io.ReadAll(dmn)
+	if err != nil {
+		return nil, err
+	}
+	return call[*apiTypes.CausalDecisionModel](ctx, c, &request{
+		method: http.MethodPost, path: "/v0/models", query: url.Values{"email": {email}}, body: body, contentType: "application/xml",
+	})
+}
+
+// ExportDMN returns a model as a DMN file.
+func (c *Client) ExportDMN(ctx context.Context, uuid string) ([]byte, error) {
+	return c.do(ctx, &request{method: http.MethodGet, path: modelPath(uuid, "/dmn")})
+}
+
+// ExportModelBundle returns a bundle of a model and its history, to import into another hub.
+func (c *Client) ExportModelBundle(ctx context.Context, uuid string, format bundle.Format) ([]byte, error) {
+	return c.do(ctx, &request{method: http.MethodGet, path: modelPath(uuid, "/bundle"), query: url.Values{"format": {string(format)}}})
+}
+
+// ImportModelBundle creates a model from a bundle exported from another hub, keeping its UUIDs and history.
+func (c *Client) ImportModelBundle(ctx context.Context, modelBundle io.Reader) (*apiTypes.BundleImport, error) {
+	body, err := io.ReadAll(modelBundle)
+	if err != nil {
+		return nil, err
+	}
+	return call[*apiTypes.BundleImport](ctx, c, &request{
+		method: http.MethodPost, path: "/v0/models/bundle", body: body, contentType: "application/octet-stream",
+	})
+}
+
+// GetModelLineage returns a model and its ancestors.
+func (c *Client) GetModelLineage(ctx context.Context, uuid string) ([]apiTypes.CausalDecisionModel, error) {
+	return get[[]apiTypes.CausalDecisionModel](ctx, c, "/v0/models/lineage/"+url.PathEscape(uuid), nil)
+}
+
+// GetModelLineageAsOf returns a model and its ancestors as they were at the given time.
+func (c *Client) GetModelLineageAsOf(ctx context.Context, uuid string, asOf time.Time) ([]apiTypes.CausalDecisionModel, error) {
+	return get[[]apiTypes.CausalDecisionModel](ctx, c, "/v0/models/lineage/"+url.PathEscape(uuid), asOfQuery(asOf))
+}
+
+// GetModelChildren returns the models forked from a model.
+func (c *Client) GetModelChildren(ctx context.Context, uuid string) ([]apiTypes.CausalDecisionModel, error) {
+	return get[[]apiTypes.CausalDecisionModel](ctx, c, "/v0/models/children/"+url.PathEscape(uuid), nil)
+}
+
+// GetModelChildrenAsOf returns the models forked from a model as they were at the given time.
+func (c *Client) GetModelChildrenAsOf(ctx context.Context, uuid string, asOf time.Time) ([]apiTypes.CausalDecisionModel, error) {
+	return get[[]apiTypes.CausalDecisionModel](ctx, c, "/v0/models/children/"+url.PathEscape(uuid), asOfQuery(asOf))
+}
+
+// GetModelFamily returns the ancestors of a model and the tree of its descendants, down to the given depth.
+// A negative depth includes every generation.
+func (c *Client) GetModelFamily(ctx context.Context, uuid string, depth int) (*apiTypes.ModelFamily, error) {
+	query := url.Values{}
+	if depth >= 0 {
+		query.Set("depth", strconv.Itoa(depth))
+	}
+	return get[*apiTypes.ModelFamily](ctx, c, modelPath(uuid, "/family"), query)
+}
+
+// GetModelBlame attributes each component of a model to the commits that introduced and last changed it.
+func (c *Client) GetModelBlame(ctx context.Context, uuid string) (*apiTypes.ModelBlame, error) {
+	return get[*apiTypes.ModelBlame](ctx, c, modelPath(uuid, "/blame"), nil)
+}
+
+// changelogQuery returns the query of the changelog of the versions from from to to.
+func changelogQuery(from, to int, format string) url.Values {
+	return url.Values{"from": {strconv.Itoa(from)}, "to": {strconv.Itoa(to)}, "format": {format}}
+}
+
+// GetModelChangelog returns the changes made to a model between two versions.
+func (c *Client) GetModelChangelog(ctx context.Context, uuid string, from, to int) (*apiTypes.Changelog, error) {
+	return get[*apiTypes.Changelog](ctx, c, modelPath(uuid, "/changelog"), changelogQuery(from, to, "json"))
+}
+
+// GetModelChangelogMarkdown returns the changes made to a model between two versions as Markdown release notes.
+func (c *Client) GetModelChangelogMarkdown(ctx context.Context, uuid string, from, to int) (string, error) {
+	data, err := c.do(ctx, &request{method: http.MethodGet, path: modelPath(uuid, "/changelog"), query: changelogQuery(from, to, "markdown")})
+	return string(data), err
+}
+
+// ForkOptions configures ForkModel. Zero values fork the latest version, with the creator and name of the model.
+type ForkOptions struct {
+	Version int
+	Email   string
+	Name    string
+}
+
+// ForkModel creates a copy of a model that records it as its parent.
+func (c *Client) ForkModel(ctx context.Context, uuid string, options ForkOptions) (*apiTypes.CausalDecisionModel, error) {
+	query := url.Values{}
+	if options.Version > 0 {
+		query.Set("version", strconv.Itoa(options.Version))
+	}
+	if options.Email != "" {
+		query.Set("email", options.Email)
+	}
+	if options.Name != "" {
+		query.Set("name", options.Name)
+	}
+	return call[*apiTypes.CausalDecisionModel](ctx, c, &request{method: http.MethodPost, path: modelPath(uuid, "/fork"), query: query})
+}
+
+// GetUpstreamStatus counts the commits a fork is ahead of and behind its parent.
+func (c *Client) GetUpstreamStatus(ctx context.Context, uuid string) (*apiTypes.UpstreamStatus, error) {
+	return get[*apiTypes.UpstreamStatus](ctx, c, modelPath(uuid, "/upstream"), nil)
+}
+
+// SyncWithParent pulls the changes made to the parent of a fork into it, reporting the ones that conflict.
+func (c *Client) SyncWithParent(ctx context.Context, uuid string) (*apiTypes.SyncResult, error) {
+	return call[*apiTypes.SyncResult](ctx, c, &request{method: http.MethodPost, path: modelPath(uuid, "/upstream/sync")})
+}
+
+// LintModel checks a model against the lint rules, configured as for the given organization if it isn't empty.
+func (c *Client) LintModel(ctx context.Context, uuid string, organization string) (*apiTypes.LintReport, error) {
+	query := url.Values{}
+	if organization != "" {
+		query.Set("organization", organization)
+	}
+	return get[*apiTypes.LintReport](ctx, c, modelPath(uuid, "/lint"), query)
+}
+
+// diagramPath returns the path of a route of a diagram of a model.
+func diagramPath(uuid, diagramUUID, rest string) string {
+	return modelPath(uuid, "/diagrams/"+url.PathEscape(diagramUUID)+rest)
+}
+
+// GetTopologicalOrder returns the elements of a diagram ordered so that every element comes after its causes.
+func (c *Client) GetTopologicalOrder(ctx context.Context, uuid, diagramUUID string) ([]string, error) {
+	return get[[]string](ctx, c, diagramPath(uuid, diagramUUID, "/graph/topological-order"), nil)
+}
+
+// GetUpstreamElements returns the elements of a diagram an element depends on, directly or not.
+func (c *Client) GetUpstreamElements(ctx context.Context, uuid, diagramUUID, elementUUID string) ([]string, error) {
+	path := diagramPath(uuid, diagramUUID, "/graph/elements/"+url.PathEscape(elementUUID)+"/upstream")
+	return get[[]string](ctx, c, path, nil)
+}
+
+// GetDownstreamElements returns the elements of a diagram that depend on an element, directly or not.
+func (c *Client) GetDownstreamElements(ctx context.Context, uuid, diagramUUID, elementUUID string) ([]string, error) {
+	path := diagramPath(uuid, diagramUUID, "/graph/elements/"+url.PathEscape(elementUUID)+"/downstream")
+	return get[[]string](ctx, c, path, nil)
+}
+
+// GetElementPaths returns the paths between two elements of a diagram, at most limit of them, or the default
+// number of them if limit is 0.
+func (c *Client) GetElementPaths(ctx context.Context, uuid, diagramUUID, from, to string, limit int) ([][]string, error) {
+	query := url.Values{"from": {from}, "to": {to}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	return get[[][]string](ctx, c, diagramPath(uuid, diagramUUID, "/graph/paths"), query)
+}
+
+// GetStronglyConnectedComponents returns the groups of elements of a diagram that depend on each other in a cycle.
+func (c *Client) GetStronglyConnectedComponents(ctx context.Context, uuid, diagramUUID string) ([][]string, error) {
+	return get[[][]string](ctx, c, diagramPath(uuid, diagramUUID, "/graph/components"), nil)
+}
+
+// ExportDiagram returns a diagram of a model as a graph in the given format.
+func (c *Client) ExportDiagram(ctx context.Context, uuid, diagramUUID string, format export.Format) ([]byte, error) {
+	query := url.Values{"format": {string(format)}}
+	return c.do(ctx, &request{method: http.MethodGet, path: diagramPath(uuid, diagramUUID, "/export"), query: query})
+}
+
+// ExportModel returns every diagram of a model as a graph in the given format.
+func (c *Client) ExportModel(ctx context.Context, uuid string, format export.Format) ([]byte, error) {
+	query := url.Values{"format": {string(format)}}
+	return c.do(ctx, &request{method: http.MethodGet, path: modelPath(uuid, "/export"), query: query})
+}
+
+// GetModelThumbnail returns an SVG thumbnail of a model.
+func (c *Client) GetModelThumbnail(ctx context.Context, uuid string) ([]byte, error) {
+	return c.do(ctx, &request{method: http.MethodGet, path: modelPath(uuid, "/thumbnail.svg")})
+}
+
+// SearchModelsByName returns the models whose name or summary match a query.
+func (c *Client) SearchModelsByName(ctx context.Context, query string) ([]apiTypes.CausalDecisionModel, error) {
+	return get[[]apiTypes.CausalDecisionModel](ctx, c, "/v0/models/search/model/"+url.PathEscape(query), nil)
+}
+
+// SearchModelsByUser returns the models of the users whose username contains a query.
+func (c *Client) SearchModelsByUser(ctx context.Context, query string) ([]apiTypes.CausalDecisionModel, error) {
+	return get[[]apiTypes.CausalDecisionModel](ctx, c, "/v0/models/search/user/"+url.PathEscape(query), nil)
+}
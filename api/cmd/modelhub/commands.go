@@ -0,0 +1,272 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/client"
+	"opendi/model-hub/api/codec"
+
+	"github.com/wI2L/jsondiff"
+)
+
+// login checks the password of a user with the hub and stores who they are, so push can name them as the creator
+// of new models. The password is read from --password, $MODELHUB_PASSWORD or a prompt, in that order.
+func (c *cli) login(args []string) error {
+	flags := c.flags("login")
+	email := flags.String("email", c.credentials.Email, "email of the user")
+	password := flags.String("password", "", "password of the user")
+	if _, err := c.parse(flags, args, 0); err != nil {
+		return err
+	}
+	if *email == "" {
+		fmt.Fprintln(c.stderr, "usage: modelhub", c.usage)
+		return errUsage
+	}
+
+	if *password == "" {
+		*password = os.Getenv("MODELHUB_PASSWORD")
+	}
+	if *password == "" {
+		fmt.Fprint(c.stderr, "Password: ")
+		line, err := bufio.NewReader(c.stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	user, err := c.hub.Login(c.ctx, *email, *password)
+	if err != nil {
+		return err
+	}
+
+	creds := &credentials{Server: c.server, Email: user.Email, UUID: user.UUID, Username: user.Username}
+	if err := creds.save(c.path); err != nil {
+		return fmt.Errorf("could not store credentials: %s", err.Error())
+	}
+	return c.print(creds, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Logged in to %s as %s\n", creds.Server, creds.Email)
+		return err
+	})
+}
+
+// readModelFile reads a model from a JSON or YAML file, chosen by its extension.
+func readModelFile(path string) (*apiTypes.CausalDecisionModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = codec.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+	}
+
+	var model apiTypes.CausalDecisionModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("%s is not a model: %s", path, err.Error())
+	}
+	return &model, nil
+}
+
+// push uploads a model file. A model whose uuid exists on the hub is updated, creating a new version of it; any
+// other model is created, with a new uuid and, unless the file names one, the logged in user as its creator.
+func (c *cli) push(args []string) error {
+	flags := c.flags("push")
+	message := flags.String("message", "", "message describing the change, recorded on the commit of an update")
+	positional, err := c.parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	model, err := readModelFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	update := false
+	if model.Meta.UUID != "" {
+		_, err := c.hub.GetModelByUUID(c.ctx, model.Meta.UUID)
+		switch {
+		case err == nil:
+			update = true
+		case !errors.Is(err, client.ErrNotFound):
+			return err
+		}
+	}
+
+	if !update {
+		if model.Meta.Creator.Email == "" {
+			if c.credentials.Email == "" {
+				return fmt.Errorf("the model has no creator email and nobody is logged in, run modelhub login first")
+			}
+			model.Meta.Creator.Email = c.credentials.Email
+		}
+		created, err := c.hub.UploadModel(c.ctx, model)
+		if err != nil {
+			return err
+		}
+		return c.print(created, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Created %s\n", created.Meta.UUID)
+			return err
+		})
+	}
+
+	updated, err := c.hub.PutModel(c.ctx, model, *message)
+	if err != nil {
+		return err
+	}
+	return c.print(updated, func(w io.Writer) error {
+		commit, err := c.hub.GetLatestCommitByModelUUID(c.ctx, updated.Meta.UUID)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "Updated %s to version %d\n", updated.Meta.UUID, commit.Version)
+		return err
+	})
+}
+
+// getModel returns a model, or one of its versions when version is positive.
+func (c *cli) getModel(uuid string, version int) (*apiTypes.CausalDecisionModel, error) {
+	if version > 0 {
+		return c.hub.GetVersionOfModel(c.ctx, uuid, version)
+	}
+	return c.hub.GetModelByUUID(c.ctx, uuid)
+}
+
+// pull prints a model, or one of its versions. Models have no text form, so text output is JSON.
+func (c *cli) pull(args []string) error {
+	flags := c.flags("pull")
+	version := flags.Int("version", 0, "version to pull instead of the latest")
+	positional, err := c.parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	model, err := c.getModel(positional[0], *version)
+	if err != nil {
+		return err
+	}
+	return c.print(model, nil)
+}
+
+// log lists the commits of a model, newest first.
+func (c *cli) log(args []string) error {
+	positional, err := c.parse(c.flags("log"), args, 1)
+	if err != nil {
+		return err
+	}
+
+	commits, err := c.hub.GetCommitsByModelUUID(c.ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].Version > commits[j].Version })
+
+	return c.print(commits, func(w io.Writer) error {
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "VERSION\tDATE\tUSER\tTAG\tMESSAGE")
+		for _, commit := range commits {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", commit.Version, commit.CreatedAt.Format("2006-01-02 15:04:05"),
+				commit.UserUUID, commit.Tag, commit.Message)
+		}
+		return table.Flush()
+	})
+}
+
+// diff prints the JSON patch that turns one version of a model into another.
+func (c *cli) diff(args []string) error {
+	positional, err := c.parse(c.flags("diff"), args, 3)
+	if err != nil {
+		return err
+	}
+
+	var versions [2][]byte
+	for i, arg := range positional[1:] {
+		version, err := strconv.Atoi(arg)
+		if err != nil || version < 1 {
+			return fmt.Errorf("version %s is not a positive number", arg)
+		}
+		model, err := c.getModel(positional[0], version)
+		if err != nil {
+			return err
+		}
+		if versions[i], err = json.Marshal(model); err != nil {
+			return err
+		}
+	}
+
+	patch, err := jsondiff.CompareJSON(versions[0], versions[1])
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		patch = jsondiff.Patch{}
+	}
+
+	return c.print(patch, func(w io.Writer) error {
+		for _, op := range patch {
+			value, err := json.Marshal(op.Value)
+			if err != nil {
+				return err
+			}
+			switch op.Type {
+			case jsondiff.OperationAdd:
+				fmt.Fprintf(w, "+ %s: %s\n", op.Path, value)
+			case jsondiff.OperationRemove:
+				fmt.Fprintf(w, "- %s\n", op.Path)
+			case jsondiff.OperationReplace:
+				fmt.Fprintf(w, "~ %s: %s\n", op.Path, value)
+			case jsondiff.OperationMove, jsondiff.OperationCopy:
+				fmt.Fprintf(w, "%s %s -> %s\n", op.Type, op.From, op.Path)
+			}
+		}
+		return nil
+	})
+}
+
+// search lists the models whose name matches a query, or the models of a user.
+func (c *cli) search(args []string) error {
+	flags := c.flags("search")
+	by := flags.String("by", "model", "what to search by: model or user")
+	positional, err := c.parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *by != "model" && *by != "user" {
+		return fmt.Errorf("cannot search by %s, expected model or user", *by)
+	}
+
+	search := c.hub.SearchModelsByName
+	if *by == "user" {
+		search = c.hub.SearchModelsByUser
+	}
+	models, err := search(c.ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	return c.print(models, func(w io.Writer) error {
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "UUID\tNAME\tCREATOR\tSUMMARY")
+		for _, model := range models {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", model.Meta.UUID, model.Meta.Name, model.Meta.Creator.Email, model.Meta.Summary)
+		}
+		return table.Flush()
+	})
+}
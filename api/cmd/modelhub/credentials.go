@@ -0,0 +1,65 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// credentials are what login remembers: the hub logged in to and the user logged in as.
+// The hub doesn't issue tokens, so the password is checked at login and never stored.
+type credentials struct {
+	Server   string `json:"server"`
+	Email    string `json:"email"`
+	UUID     string `json:"uuid"`
+	Username string `json:"username"`
+}
+
+// credentialsPath returns where credentials are stored: $MODELHUB_CREDENTIALS if set, otherwise
+// modelhub/credentials.json in the user config directory.
+func credentialsPath() (string, error) {
+	if path := os.Getenv("MODELHUB_CREDENTIALS"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find the config directory, set MODELHUB_CREDENTIALS: %s", err.Error())
+	}
+	return filepath.Join(dir, "modelhub", "credentials.json"), nil
+}
+
+// loadCredentials reads the credentials stored at path. Returns empty credentials if nobody has logged in yet.
+func loadCredentials(path string) (*credentials, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &credentials{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var creds credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("could not read credentials %s: %s", path, err.Error())
+	}
+	return &creds, nil
+}
+
+// save stores the credentials at path, readable by the current user only.
+func (creds *credentials) save(path string) error {
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
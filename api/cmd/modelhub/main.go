@@ -0,0 +1,170 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Command modelhub is a command line client for the model hub API, for scripting the hub without curl.
+//
+// Usage:
+//
+//	modelhub login --email <email>
+//	modelhub push <model.json|model.yaml> [--message <message>]
+//	modelhub pull <uuid> [--version <version>]
+//	modelhub log <uuid>
+//	modelhub diff <uuid> <from> <to>
+//	modelhub search <query> [--by model|user]
+//
+// Every command takes --server, the base URL of the hub, and -o, the output format: text, json or yaml.
+// The server defaults to $MODELHUB_SERVER, then to the server of the last login, then to http://localhost:8080.
+// Login stores the server and the identity of the user in $MODELHUB_CREDENTIALS, by default
+// credentials.json in the modelhub directory of the user config directory.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"opendi/model-hub/api/client"
+)
+
+// defaultServer is the hub used when no server is given and none was logged in to.
+const defaultServer = "http://localhost:8080"
+
+// cli holds the state shared by the commands of a single invocation.
+type cli struct {
+	ctx         context.Context
+	usage       string
+	server      string
+	output      string
+	credentials *credentials
+	path        string
+	hub         *client.Client
+	stdin       io.Reader
+	stdout      io.Writer
+	stderr      io.Writer
+}
+
+// command is a subcommand of modelhub. run is given the arguments after the name of the command.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"login", "login --email <email> [--password <password>]", "check a password and remember the user", (*cli).login},
+	{"push", "push <file> [--message <message>]", "create a model, or update it if its uuid exists", (*cli).push},
+	{"pull", "pull <uuid> [--version <version>]", "print a model, or one of its versions", (*cli).pull},
+	{"log", "log <uuid>", "list the commits of a model, newest first", (*cli).log},
+	{"diff", "diff <uuid> <from> <to>", "print the JSON patch between two versions of a model", (*cli).diff},
+	{"search", "search <query> [--by model|user]", "search models by name, or by user", (*cli).search},
+}
+
+// errUsage is returned by commands called with the wrong arguments. The usage has already been printed.
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command named by the first argument and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		c := &cli{ctx: context.Background(), usage: cmd.usage, stdin: stdin, stdout: stdout, stderr: stderr}
+		var err error
+		if c.path, err = credentialsPath(); err == nil {
+			c.credentials, err = loadCredentials(c.path)
+		}
+		if err == nil {
+			err = cmd.run(c, args[1:])
+		}
+		switch {
+		case errors.Is(err, errUsage):
+			return 2
+		case err != nil:
+			fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "unknown command %s\n", args[0])
+	printUsage(stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: modelhub <command> [arguments] [--server <url>] [-o text|json|yaml]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-50s %s\n", cmd.usage, cmd.summary)
+	}
+}
+
+// flags returns the flag set of a command, with the flags every command takes.
+func (c *cli) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.StringVar(&c.server, "server", "", "base URL of the hub")
+	flags.StringVar(&c.output, "o", "text", "output format: text, json or yaml")
+	flags.StringVar(&c.output, "output", "text", "output format: text, json or yaml")
+	return flags
+}
+
+// parse parses the arguments of a command, which may mix flags and positional arguments, and checks that exactly
+// want positional arguments were given. Returns them in order.
+func (c *cli) parse(flags *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != want {
+		fmt.Fprintln(c.stderr, "usage: modelhub", c.usage)
+		return nil, errUsage
+	}
+
+	switch c.output {
+	case "text", "json", "yaml":
+	default:
+		return nil, fmt.Errorf("unknown output format %s, expected text, json or yaml", c.output)
+	}
+
+	if c.server == "" {
+		c.server = os.Getenv("MODELHUB_SERVER")
+	}
+	if c.server == "" {
+		c.server = c.credentials.Server
+	}
+	if c.server == "" {
+		c.server = defaultServer
+	}
+	c.server = strings.TrimSuffix(c.server, "/")
+	c.hub = client.New(c.server, client.WithHTTPClient(&http.Client{Timeout: time.Minute}))
+	return positional, nil
+}
@@ -0,0 +1,212 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testHub is a stand-in for the hub, serving a model with uuid "known" in three versions.
+type testHub struct {
+	requests []string
+	bodies   map[string]map[string]any
+}
+
+func (h *testHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests = append(h.requests, r.Method+" "+r.URL.RequestURI())
+	body, _ := io.ReadAll(r.Body)
+	if len(body) > 0 {
+		var decoded map[string]any
+		json.Unmarshal(body, &decoded)
+		h.bodies[r.Method+" "+r.URL.Path] = decoded
+	}
+
+	version := func(n int) string {
+		summary := ""
+		if n > 1 {
+			summary = fmt.Sprintf(`, "summary": "Changed %d times"`, n-1)
+		}
+		return fmt.Sprintf(`{"$schema": "schema", "meta": {"uuid": "known", "name": "Version %d"%s}}`, n, summary)
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "POST /login":
+		if r.URL.Query().Get("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"Error": "password is incorrect"}`)
+			return
+		}
+		io.WriteString(w, `{"uuid": "user-uuid", "username": "engineer", "email": "`+r.URL.Query().Get("email")+`"}`)
+	case "GET /v0/models/known", "PUT /v0/models":
+		io.WriteString(w, version(3))
+	case "POST /v0/models":
+		io.WriteString(w, `{"meta": {"uuid": "created-uuid", "name": "New"}}`)
+	case "GET /v0/models/modelVersion/known/1":
+		io.WriteString(w, version(1))
+	case "GET /v0/models/modelVersion/known/2":
+		io.WriteString(w, version(2))
+	case "GET /v0/commits/known":
+		io.WriteString(w, `{"version": 4, "cdmuuid": "known"}`)
+	case "GET /v0/commits/model/known":
+		io.WriteString(w, `[{"version": 1, "message": "first"}, {"version": 3, "tag": "v3"}, {"version": 2}]`)
+	case "GET /v0/models/search/model/fraud detection":
+		io.WriteString(w, `[{"meta": {"uuid": "known", "name": "Fraud Detection", "creator": {"email": "a@example.com"}}}]`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"Error": "model not found"}`)
+	}
+}
+
+// setUp starts a test hub and points the credentials of the CLI at a temporary file.
+func setUp(t *testing.T) (*testHub, *httptest.Server, string) {
+	hub := &testHub{bodies: map[string]map[string]any{}}
+	server := httptest.NewServer(hub)
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "modelhub", "credentials.json")
+	t.Setenv("MODELHUB_CREDENTIALS", path)
+	t.Setenv("MODELHUB_SERVER", "")
+	t.Setenv("MODELHUB_PASSWORD", "")
+	return hub, server, path
+}
+
+// runCLI runs the CLI with the given arguments and stdin, returning its exit code, stdout and stderr.
+func runCLI(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestLogin(t *testing.T) {
+	_, server, path := setUp(t)
+
+	code, _, stderr := runCLI("wrong\n", "login", "--email", "engineer@example.com", "--server", server.URL)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "password is incorrect (401 Unauthorized)")
+	assert.NoFileExists(t, path)
+
+	code, stdout, _ := runCLI("secret\n", "login", "--email", "engineer@example.com", "--server", server.URL+"/")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "Logged in to "+server.URL+" as engineer@example.com\n", stdout)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	creds, err := loadCredentials(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &credentials{Server: server.URL, Email: "engineer@example.com", UUID: "user-uuid", Username: "engineer"}, creds)
+	data, _ := os.ReadFile(path)
+	assert.NotContains(t, string(data), "secret")
+}
+
+func TestPush(t *testing.T) {
+	hub, server, path := setUp(t)
+	assert.NoError(t, (&credentials{Server: server.URL, Email: "engineer@example.com"}).save(path))
+	dir := t.TempDir()
+
+	// a model the hub doesn't know is created, by the logged in user
+	newModel := filepath.Join(dir, "new.yaml")
+	assert.NoError(t, os.WriteFile(newModel, []byte("meta:\n  uuid: unknown\n  name: New\n"), 0644))
+	code, stdout, stderr := runCLI("", "push", newModel)
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "Created created-uuid\n", stdout)
+	created := hub.bodies["POST /v0/models"]
+	assert.Equal(t, "New", created["meta"].(map[string]any)["name"])
+	assert.Equal(t, "engineer@example.com", created["meta"].(map[string]any)["creator"].(map[string]any)["email"])
+
+	// a model the hub knows is updated
+	knownModel := filepath.Join(dir, "known.json")
+	assert.NoError(t, os.WriteFile(knownModel, []byte(`{"meta": {"uuid": "known", "name": "Known"}}`), 0644))
+	code, stdout, stderr = runCLI("", "push", knownModel, "--message", "rename")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "Updated known to version 4\n", stdout)
+	assert.Contains(t, hub.requests, "PUT /v0/models?message=rename")
+	assert.Equal(t, "", hub.bodies["PUT /v0/models"]["meta"].(map[string]any)["creator"].(map[string]any)["email"])
+
+	code, _, stderr = runCLI("", "push", filepath.Join(dir, "missing.json"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no such file")
+}
+
+func TestPull(t *testing.T) {
+	_, server, _ := setUp(t)
+
+	code, stdout, _ := runCLI("", "pull", "known", "--version", "1", "-o", "yaml", "--server", server.URL)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "$schema: schema\nmeta:\n  uuid: known\n  name: Version 1\n  creator:\n    uuid: \"\"\n    username: \"\"\n    email: \"\"\n", stdout)
+
+	code, stdout, _ = runCLI("", "pull", "--server", server.URL, "known")
+	assert.Equal(t, 0, code)
+	var model map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &model))
+	assert.Equal(t, "Version 3", model["meta"].(map[string]any)["name"])
+
+	code, _, stderr := runCLI("", "pull", "missing", "--server", server.URL)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "Error: model not found (404 Not Found)\n", stderr)
+
+	code, _, stderr = runCLI("", "pull", "--server", server.URL)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "usage: modelhub pull <uuid> [--version <version>]\n", stderr)
+}
+
+func TestLog(t *testing.T) {
+	_, server, _ := setUp(t)
+
+	code, stdout, _ := runCLI("", "log", "known", "--server", server.URL)
+	assert.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Len(t, lines, 4)
+	assert.Regexp(t, `^VERSION\s+DATE\s+USER\s+TAG\s+MESSAGE$`, lines[0])
+	assert.Regexp(t, `^3\s.*v3\s*$`, lines[1])
+	assert.Regexp(t, `^2\s`, lines[2])
+	assert.Regexp(t, `^1\s.*first$`, lines[3])
+}
+
+func TestDiff(t *testing.T) {
+	_, server, _ := setUp(t)
+
+	code, stdout, _ := runCLI("", "diff", "known", "1", "2", "--server", server.URL)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "~ /meta/name: \"Version 2\"\n+ /meta/summary: \"Changed 1 times\"\n", stdout)
+
+	code, stdout, _ = runCLI("", "diff", "known", "1", "2", "-o", "json", "--server", server.URL)
+	assert.Equal(t, 0, code)
+	var patch []map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &patch))
+	assert.Len(t, patch, 2)
+
+	code, _, stderr := runCLI("", "diff", "known", "one", "2", "--server", server.URL)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "version one is not a positive number")
+}
+
+func TestSearch(t *testing.T) {
+	hub, server, _ := setUp(t)
+	t.Setenv("MODELHUB_SERVER", server.URL)
+
+	code, stdout, _ := runCLI("", "search", "fraud detection")
+	assert.Equal(t, 0, code)
+	assert.Regexp(t, `^UUID\s+NAME\s+CREATOR\s+SUMMARY\nknown\s+Fraud Detection\s+a@example.com\s*\n$`, stdout)
+	assert.Contains(t, hub.requests, "GET /v0/models/search/model/fraud%20detection")
+
+	code, _, stderr := runCLI("", "search", "fraud", "-o", "xml")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "unknown output format xml")
+
+	code, _, _ = runCLI("", "frobnicate")
+	assert.Equal(t, 2, code)
+}
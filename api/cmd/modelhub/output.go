@@ -0,0 +1,34 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"opendi/model-hub/api/codec"
+)
+
+// print writes a value in the output format. Text output is written by text, or is indented JSON when text is
+// nil, for values such as models that have no better text form.
+func (c *cli) print(value any, text func(w io.Writer) error) error {
+	if c.output == "text" && text != nil {
+		return text(c.stdout)
+	}
+
+	data, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	if c.output == "yaml" {
+		if data, err = codec.JSONToYAML(data); err != nil {
+			return err
+		}
+	} else {
+		data = append(data, '\n')
+	}
+	_, err = c.stdout.Write(data)
+	return err
+}
@@ -0,0 +1,245 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package codec converts models between JSON and YAML, so they can be kept as hand-edited YAML while the API
+// stores and validates JSON. Conversions go through the document tree rather than Go values, so object keys
+// keep their order and numbers keep their literal form, and a document survives a round trip unchanged.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSONToYAML converts a JSON document to YAML.
+func JSONToYAML(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	node, err := jsonNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// jsonNode reads the next JSON value from the decoder as a YAML node.
+func jsonNode(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		switch value {
+		case '{':
+			node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				child, err := jsonNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)}, child)
+			}
+			_, err := decoder.Token()
+			return node, err
+		case '[':
+			node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for decoder.More() {
+				child, err := jsonNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, child)
+			}
+			_, err := decoder.Token()
+			return node, err
+		}
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(string(value), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(value)}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(value)}, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected JSON token %v", token)
+}
+
+// Limits on expanding aliases when converting YAML to JSON. Every use of an alias copies the node it refers to,
+// so a small document with nested aliases can expand to gigabytes of JSON.
+const (
+	// maxAliases is the number of aliases a document may expand, counting those within expanded aliases.
+	maxAliases = 10000
+	// maxExpansion is how many times larger than the YAML document its JSON may be, on top of minOutputLimit.
+	maxExpansion = 16
+	// minOutputLimit is the size the JSON of any document may reach, however small the document.
+	minOutputLimit = 1 << 20
+)
+
+// ErrTooLarge is returned when the aliases of a YAML document expand past the limits of the conversion.
+var ErrTooLarge = errors.New("YAML document expands to too much JSON")
+
+// YAMLToJSON converts the first document of a YAML stream to compact JSON. Documents whose aliases expand too
+// often or too far fail with ErrTooLarge.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, errors.New("empty YAML document")
+	}
+
+	var out bytes.Buffer
+	w := jsonWriter{out: &out, limit: minOutputLimit + maxExpansion*len(data)}
+	if err := w.write(document.Content[0]); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// jsonWriter writes YAML nodes as JSON, keeping count of the aliases it expands.
+type jsonWriter struct {
+	out     *bytes.Buffer
+	aliases int
+	// limit is the size the JSON may reach.
+	limit int
+}
+
+// write writes a YAML node to the buffer as JSON.
+func (w *jsonWriter) write(node *yaml.Node) error {
+	if w.out.Len() > w.limit {
+		return ErrTooLarge
+	}
+
+	out := w.out
+	switch node.Kind {
+	case yaml.AliasNode:
+		if w.aliases++; w.aliases > maxAliases {
+			return ErrTooLarge
+		}
+		return w.write(node.Alias)
+	case yaml.MappingNode:
+		out.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: object keys must be scalars", key.Line)
+			}
+			if key.ShortTag() == "!!merge" {
+				return fmt.Errorf("line %d: merge keys are not supported", key.Line)
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			writeString(out, key.Value)
+			out.WriteByte(':')
+			if err := w.write(node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	case yaml.SequenceNode:
+		out.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := w.write(child); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case yaml.ScalarNode:
+		return writeScalar(out, node)
+	default:
+		return fmt.Errorf("line %d: unexpected YAML node", node.Line)
+	}
+
+	return nil
+}
+
+// writeScalar writes a YAML scalar as the JSON value of its resolved type. Numbers already written as JSON
+// numbers are copied as they are, so their precision and form are kept.
+func writeScalar(out *bytes.Buffer, node *yaml.Node) error {
+	switch node.ShortTag() {
+	case "!!null":
+		out.WriteString("null")
+	case "!!bool":
+		var value bool
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatBool(value))
+	case "!!int", "!!float":
+		if json.Valid([]byte(node.Value)) {
+			out.WriteString(node.Value)
+			return nil
+		}
+		var value float64
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			return fmt.Errorf("line %d: %s cannot be represented in JSON", node.Line, node.Value)
+		}
+		if node.ShortTag() == "!!int" {
+			var integer int64
+			if err := node.Decode(&integer); err == nil {
+				out.WriteString(strconv.FormatInt(integer, 10))
+				return nil
+			}
+		}
+		out.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	default:
+		// Strings, and types JSON has no equivalent of such as timestamps, are kept as written.
+		writeString(out, node.Value)
+	}
+
+	return nil
+}
+
+// writeString writes a JSON string literal without escaping HTML characters.
+func writeString(out *bytes.Buffer, value string) {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	// Encode terminates the value with a newline.
+	out.Truncate(out.Len() - 1)
+}
@@ -0,0 +1,102 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package codec
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONToYAML(t *testing.T) {
+	out, err := JSONToYAML([]byte(`{"name":"loan","version":1,"ratio":1.50,"flag":"true","count":"12","empty":{},"list":[],"nothing":null,"ok":false}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `name: loan
+version: 1
+ratio: 1.50
+flag: "true"
+count: "12"
+empty: {}
+list: []
+nothing: null
+ok: false
+`, string(out))
+
+	_, err = JSONToYAML([]byte(`{"a":1} {"b":2}`))
+	assert.Error(t, err)
+
+	_, err = JSONToYAML([]byte(`{"a":`))
+	assert.Error(t, err)
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	out, err := YAMLToJSON([]byte(`
+zeta: 1
+alpha: 0x10
+beta: 1_000
+gamma: 2.5e3
+delta: yes
+epsilon: "<b>"
+shared: &anchor
+  key: value
+copy: *anchor
+when: 2024-01-01
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"zeta":1,"alpha":16,"beta":1000,"gamma":2.5e3,"delta":"yes","epsilon":"<b>","shared":{"key":"value"},"copy":{"key":"value"},"when":"2024-01-01"}`, string(out))
+
+	_, err = YAMLToJSON([]byte(`value: .inf`))
+	assert.Error(t, err)
+
+	_, err = YAMLToJSON([]byte(``))
+	assert.Error(t, err)
+
+	_, err = YAMLToJSON([]byte(`[1, 2`))
+	assert.Error(t, err)
+}
+
+// TestRoundTrip checks that a model converted to YAML and back is byte for byte the compacted original, including
+// the free-form content of its elements.
+func TestRoundTrip(t *testing.T) {
+	for _, file := range []string{"../test_files/model.json", "../test_files/model4.json"} {
+		original, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		compact, err := YAMLToJSON(original) // JSON is YAML, so this compacts it
+		assert.NoError(t, err)
+
+		asYAML, err := JSONToYAML(original)
+		assert.NoError(t, err)
+		back, err := YAMLToJSON(asYAML)
+		assert.NoError(t, err)
+
+		assert.Equal(t, string(compact), string(back), file)
+		assert.JSONEq(t, string(original), string(back), file)
+	}
+}
+
+// TestBillionLaughs checks that nested aliases can't expand a small document into a huge one.
+func TestBillionLaughs(t *testing.T) {
+	laughs := `a: &a ["lol","lol","lol","lol","lol","lol","lol","lol","lol"]
+b: &b [*a,*a,*a,*a,*a,*a,*a,*a,*a]
+c: &c [*b,*b,*b,*b,*b,*b,*b,*b,*b]
+d: &d [*c,*c,*c,*c,*c,*c,*c,*c,*c]
+e: &e [*d,*d,*d,*d,*d,*d,*d,*d,*d]
+f: &f [*e,*e,*e,*e,*e,*e,*e,*e,*e]
+g: &g [*f,*f,*f,*f,*f,*f,*f,*f,*f]
+h: &h [*g,*g,*g,*g,*g,*g,*g,*g,*g]
+i: &i [*h,*h,*h,*h,*h,*h,*h,*h,*h]
+`
+	_, err := YAMLToJSON([]byte(laughs))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// A few aliases of a large node expand too far even though there are few of them.
+	large := "a: &a [" + strings.Repeat(`"lol",`, 100000) + `"lol"]` + "\nb: [" + strings.Repeat("*a,", 100) + "*a]\n"
+	_, err = YAMLToJSON([]byte(large))
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
@@ -0,0 +1,64 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"opendi/model-hub/api/config"
+)
+
+const configUsage = "usage: modelhub config print [-o yaml|toml] [flags]"
+
+// configCommand prints the configuration the server would run with, given the same configuration file,
+// environment and flags, with the database password masked. Returns the exit code of the command, which is 1
+// when the configuration is invalid, after the configuration and what is wrong with it have been printed.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := flags.String("o", "yaml", "output format: yaml or toml")
+	configFlags := config.NewFlags(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 || *format != "yaml" && *format != "toml" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+
+	loadEnvironment()
+	cfg, err := configFlags.Load(os.LookupEnv)
+	if err != nil {
+		fmt.Println("Error loading configuration: ", err)
+		return 1
+	}
+
+	var out []byte
+	if *format == "toml" {
+		out, err = toml.Marshal(cfg.Redacted())
+	} else {
+		out, err = yaml.Marshal(cfg.Redacted())
+	}
+	if err != nil {
+		fmt.Println("Error printing configuration: ", err)
+		return 1
+	}
+	os.Stdout.Write(out)
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
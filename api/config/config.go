@@ -0,0 +1,244 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package config holds the configuration of the hub. Every setting has a default, which a YAML or TOML
+// configuration file overrides, which the environment overrides, which the command line overrides.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The drivers database.driver may name.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// sslModes are the values database.sslmode may take, as PostgreSQL defines them.
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Config is the configuration of the hub.
+type Config struct {
+	Server   Server   `yaml:"server" toml:"server"`
+	Database Database `yaml:"database" toml:"database"`
+	CORS     CORS     `yaml:"cors" toml:"cors"`
+	Auth     Auth     `yaml:"auth" toml:"auth"`
+	Limits   Limits   `yaml:"limits" toml:"limits"`
+	Logging  Logging  `yaml:"logging" toml:"logging"`
+}
+
+// Server is where the API listens. An empty address listens on every interface.
+type Server struct {
+	Address string `yaml:"address" toml:"address"`
+	Port    int    `yaml:"port" toml:"port"`
+}
+
+// Database is the database the hub keeps everything in. MySQL and PostgreSQL servers are described by their
+// hostname, port, username, password and name, a SQLite database by the path of its file, and a database in
+// memory by nothing at all.
+type Database struct {
+	Driver   string `yaml:"driver" toml:"driver"`
+	Hostname string `yaml:"hostname" toml:"hostname"`
+	Port     int    `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	Path     string `yaml:"path" toml:"path"`
+	// SSLMode is the sslmode of connections to a PostgreSQL server, left to the driver when it is empty.
+	SSLMode string `yaml:"sslmode" toml:"sslmode"`
+	// ConnectTimeout is how long to keep trying to reach a server that does not accept connections yet.
+	ConnectTimeout Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+}
+
+// CORS is which web pages may call the API from the browser.
+type CORS struct {
+	// AllowOrigins are the origins of the pages, such as the frontend, or * for every origin.
+	AllowOrigins List `yaml:"allow_origins" toml:"allow_origins"`
+	// AllowCredentials lets the pages send cookies and authorization headers.
+	AllowCredentials bool `yaml:"allow_credentials" toml:"allow_credentials"`
+}
+
+// Auth is how users sign in.
+type Auth struct {
+	// RegisterOnLogin creates a user for every email that logs in without an account.
+	RegisterOnLogin bool `yaml:"register_on_login" toml:"register_on_login"`
+}
+
+// Limits bound the resources requests and history take.
+type Limits struct {
+	// MaxBodySize is the size of the largest request body the API reads.
+	MaxBodySize Size `yaml:"max_body_size" toml:"max_body_size"`
+	// CommitRetention is how long commits are kept before they are squashed away every day, forever when 0.
+	CommitRetention Duration `yaml:"commit_retention" toml:"commit_retention"`
+}
+
+// Logging is what the API logs and how.
+type Logging struct {
+	// Level is the least severe level logged: debug, info, warn or error.
+	Level string `yaml:"level" toml:"level"`
+	// Format is text, for people, or json, for log collectors.
+	Format string `yaml:"format" toml:"format"`
+}
+
+// Default returns the configuration of a hub that nothing configures. It listens on localhost:8080 for the
+// frontend on localhost:3000, and still needs to be told where the MySQL server is.
+func Default() Config {
+	return Config{
+		Server: Server{Address: "localhost", Port: 8080},
+		Database: Database{
+			Driver:         DriverMySQL,
+			ConnectTimeout: Duration(30 * time.Second),
+		},
+		CORS: CORS{
+			AllowOrigins:     List{"http://localhost:3000"},
+			AllowCredentials: true,
+		},
+		Auth:    Auth{RegisterOnLogin: true},
+		Limits:  Limits{MaxBodySize: 256 * MiB},
+		Logging: Logging{Level: "info", Format: "text"},
+	}
+}
+
+// Validate returns an error describing every invalid setting of the configuration, or nil if there are none.
+func (c Config) Validate() error {
+	return errors.Join(c.Server.Validate(), c.Database.Validate(), c.CORS.Validate(), c.Limits.Validate(), c.Logging.Validate())
+}
+
+// Redacted returns a copy of the configuration without secrets, to show.
+func (c Config) Redacted() Config {
+	if c.Database.Password != "" {
+		c.Database.Password = "********"
+	}
+	return c
+}
+
+// Addr returns the address the server listens on, as net.Listen expects it.
+func (s Server) Addr() string {
+	return net.JoinHostPort(s.Address, strconv.Itoa(s.Port))
+}
+
+// Validate returns an error unless the port is valid.
+func (s Server) Validate() error {
+	return validatePort("server.port", s.Port)
+}
+
+// Validate returns an error describing every setting the driver needs that is missing or invalid.
+func (d Database) Validate() error {
+	var errs []error
+	switch d.Driver {
+	case DriverMySQL, DriverPostgres:
+		for _, setting := range []struct{ key, value string }{
+			{"database.hostname", d.Hostname},
+			{"database.username", d.Username},
+			{"database.password", d.Password},
+			{"database.name", d.Name},
+		} {
+			if setting.value == "" {
+				errs = append(errs, fmt.Errorf("%s is not set", describe(setting.key)))
+			}
+		}
+		if d.Port == 0 {
+			errs = append(errs, fmt.Errorf("%s is not set", describe("database.port")))
+		} else {
+			errs = append(errs, validatePort("database.port", d.Port))
+		}
+		if d.Driver == DriverPostgres && d.SSLMode != "" && !slices.Contains(sslModes, d.SSLMode) {
+			errs = append(errs, fmt.Errorf("%s is %q, expected one of %s", describe("database.sslmode"), d.SSLMode, strings.Join(sslModes, ", ")))
+		}
+	case DriverSQLite:
+		if d.Path == "" {
+			errs = append(errs, fmt.Errorf("%s is not set", describe("database.path")))
+		}
+	case DriverMemory:
+	default:
+		errs = append(errs, fmt.Errorf("%s is %q, expected %s, %s, %s or %s", describe("database.driver"), d.Driver, DriverMySQL, DriverPostgres, DriverSQLite, DriverMemory))
+	}
+	if d.ConnectTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", describe("database.connect_timeout")))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns an error unless every origin is a web origin, or the only origin is * without credentials,
+// which browsers refuse.
+func (c CORS) Validate() error {
+	if len(c.AllowOrigins) == 0 {
+		return fmt.Errorf("%s names no origin", describe("cors.allow_origins"))
+	}
+	var errs []error
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			if len(c.AllowOrigins) > 1 {
+				errs = append(errs, fmt.Errorf("%s has * and other origins, which * already allows", describe("cors.allow_origins")))
+			}
+			if c.AllowCredentials {
+				errs = append(errs, fmt.Errorf("%s is * while %s is true, which browsers refuse", describe("cors.allow_origins"), describe("cors.allow_credentials")))
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+			errs = append(errs, fmt.Errorf("%s has %q, expected an origin such as https://example.com:3000", describe("cors.allow_origins"), origin))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns an error unless the limits are positive, or 0 for the retention.
+func (l Limits) Validate() error {
+	var errs []error
+	if l.MaxBodySize <= 0 {
+		errs = append(errs, fmt.Errorf("%s must be positive", describe("limits.max_body_size")))
+	}
+	if l.CommitRetention < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", describe("limits.commit_retention")))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns an error unless the level and format are known.
+func (l Logging) Validate() error {
+	var errs []error
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("%s is %q, expected debug, info, warn or error", describe("logging.level"), l.Level))
+	}
+	if l.Format != "text" && l.Format != "json" {
+		errs = append(errs, fmt.Errorf("%s is %q, expected text or json", describe("logging.format"), l.Format))
+	}
+	return errors.Join(errs...)
+}
+
+// NewLogger returns a logger that writes to w at the configured level, in the configured format.
+func (l Logging) NewLogger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	options := &slog.HandlerOptions{Level: level}
+	if l.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// validatePort returns an error unless port is a TCP port.
+func validatePort(key string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%s is %d, expected 1 to 65535", describe(key), port)
+	}
+	return nil
+}
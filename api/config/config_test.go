@@ -0,0 +1,205 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// env returns a lookup function over a fixed environment.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+// writeFile writes a configuration file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// load parses args with the configuration flags and loads the configuration.
+func load(t *testing.T, args []string, vars map[string]string) (*Config, error) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	configFlags := NewFlags(flags)
+	require.NoError(t, flags.Parse(args))
+	return configFlags.Load(env(vars))
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "modelhub.yaml", `
+server:
+  port: 9000
+database:
+  hostname: file-host
+  username: file-user
+limits:
+  commit_retention: 720h
+`)
+
+	c, err := load(t, []string{"-config", path, "-database.hostname", "flag-host"}, map[string]string{
+		"OPEN_DI_DB_USERNAME": "env-user",
+		"OPEN_DI_DB_NAME":     "env-name",
+		"OPEN_DI_DB_PASSWORD": "",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 9000, c.Server.Port, "the file overrides the defaults")
+	assert.Equal(t, "localhost", c.Server.Address, "settings nothing overrides keep their default")
+	assert.Equal(t, "env-user", c.Database.Username, "the environment overrides the file")
+	assert.Equal(t, "env-name", c.Database.Name)
+	assert.Equal(t, "flag-host", c.Database.Hostname, "flags override the file")
+	assert.Equal(t, "", c.Database.Password, "empty environment variables are not set")
+	assert.Equal(t, Duration(720*time.Hour), c.Limits.CommitRetention)
+}
+
+func TestLoadFlagsOverrideEnvironment(t *testing.T) {
+	c, err := load(t, []string{"-cors.allow_origins", "https://a.example, https://b.example", "-logging.level=debug"}, map[string]string{
+		"OPENDI_MODEL_HUB_CORS_ORIGINS": "https://env.example",
+		"OPENDI_MODEL_HUB_LOG_LEVEL":    "warn",
+		"OPENDI_MODEL_HUB_LOG_FORMAT":   "json",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, List{"https://a.example", "https://b.example"}, c.CORS.AllowOrigins)
+	assert.Equal(t, "debug", c.Logging.Level)
+	assert.Equal(t, "json", c.Logging.Format)
+}
+
+func TestLoadFileFromEnvironment(t *testing.T) {
+	path := writeFile(t, "modelhub.toml", `
+[database]
+driver = "sqlite"
+path = "hub.db"
+
+[cors]
+allow_origins = ["https://hub.example"]
+
+[limits]
+max_body_size = "64MiB"
+`)
+
+	c, err := load(t, nil, map[string]string{EnvFile: path})
+	require.NoError(t, err)
+
+	assert.Equal(t, DriverSQLite, c.Database.Driver)
+	assert.Equal(t, "hub.db", c.Database.Path)
+	assert.Equal(t, List{"https://hub.example"}, c.CORS.AllowOrigins)
+	assert.Equal(t, 64*MiB, c.Limits.MaxBodySize)
+	assert.NoError(t, c.Validate())
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	_, err := load(t, []string{"-config", writeFile(t, "modelhub.yaml", "databse:\n  driver: sqlite\n")}, nil)
+	assert.ErrorContains(t, err, "databse", "unknown YAML keys are errors")
+
+	_, err = load(t, []string{"-config", writeFile(t, "modelhub.toml", "[database]\ndriverr = \"sqlite\"\n")}, nil)
+	assert.ErrorContains(t, err, "driverr", "unknown TOML keys are errors")
+
+	_, err = load(t, []string{"-config", writeFile(t, "modelhub.json", "{}")}, nil)
+	assert.ErrorContains(t, err, "neither YAML nor TOML")
+
+	_, err = load(t, []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+	assert.Error(t, err)
+
+	c, err := load(t, []string{"-config", writeFile(t, "empty.yaml", "")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), *c)
+}
+
+func TestLoadEnvErrors(t *testing.T) {
+	_, err := load(t, nil, map[string]string{"OPENDI_MODEL_HUB_PORT": "eighty"})
+	assert.ErrorContains(t, err, "OPENDI_MODEL_HUB_PORT")
+
+	_, err = load(t, nil, map[string]string{"OPENDI_MODEL_HUB_COMMIT_RETENTION": "30 days"})
+	assert.ErrorContains(t, err, "OPENDI_MODEL_HUB_COMMIT_RETENTION")
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	err := c.Validate()
+	assert.ErrorContains(t, err, "database.hostname (OPEN_DI_DB_HOSTNAME) is not set")
+	assert.ErrorContains(t, err, "database.password (OPEN_DI_DB_PASSWORD) is not set")
+	assert.ErrorContains(t, err, "database.port (OPEN_DI_DB_PORT) is not set")
+
+	c.Database = Database{Driver: DriverPostgres, Hostname: "db", Port: 5432, Username: "hub", Password: "secret", Name: "hub", SSLMode: "require"}
+	assert.NoError(t, c.Validate())
+
+	c.Database.SSLMode = "always"
+	assert.ErrorContains(t, c.Validate(), "database.sslmode")
+
+	c.Database = Database{Driver: DriverMemory}
+	assert.NoError(t, c.Validate())
+
+	c.Database.Driver = "oracle"
+	assert.ErrorContains(t, c.Validate(), `database.driver (OPEN_DI_DB_DRIVER) is "oracle"`)
+
+	c.Database = Database{Driver: DriverSQLite}
+	assert.ErrorContains(t, c.Validate(), "database.path")
+
+	c = Default()
+	c.Database = Database{Driver: DriverMemory}
+	c.Server.Port = 70000
+	c.CORS.AllowOrigins = List{"localhost:3000", "https://hub.example/app"}
+	c.Limits.MaxBodySize = 0
+	c.Logging = Logging{Level: "verbose", Format: "xml"}
+	err = c.Validate()
+	for _, key := range []string{"server.port", `"localhost:3000"`, `"https://hub.example/app"`, "limits.max_body_size", "logging.level", "logging.format"} {
+		assert.ErrorContains(t, err, key)
+	}
+
+	c = Default()
+	c.Database = Database{Driver: DriverMemory}
+	c.CORS.AllowOrigins = List{"*"}
+	assert.ErrorContains(t, c.Validate(), "browsers refuse", "every origin may not send credentials")
+	c.CORS.AllowCredentials = false
+	assert.NoError(t, c.Validate())
+	c.CORS.AllowOrigins = nil
+	assert.ErrorContains(t, c.Validate(), "names no origin")
+}
+
+func TestSize(t *testing.T) {
+	for text, size := range map[string]Size{"0": 0, "512": 512, "512B": 512, "2KiB": 2 * KiB, "64MiB": 64 * MiB, "1 GiB": GiB} {
+		var s Size
+		assert.NoError(t, s.Set(text), text)
+		assert.Equal(t, size, s, text)
+	}
+	assert.Equal(t, "1536KiB", (1536 * KiB).String())
+	assert.Equal(t, "1000B", Size(1000).String())
+
+	var s Size
+	assert.Error(t, s.Set("lots"))
+	assert.Error(t, s.Set("64MB"))
+	assert.Error(t, s.Set("99999999999GiB"))
+}
+
+func TestRedacted(t *testing.T) {
+	c := Default()
+	assert.Empty(t, c.Redacted().Database.Password, "an empty password stays empty, to show it is not set")
+
+	c.Database.Password = "hunter2"
+	assert.Equal(t, "********", c.Redacted().Database.Password)
+	assert.Equal(t, "hunter2", c.Database.Password)
+}
+
+func TestNewLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := Logging{Level: "warn", Format: "json"}.NewLogger(&out)
+	logger.Info("hidden")
+	logger.Warn("shown", "port", 8080)
+	assert.NotContains(t, out.String(), "hidden")
+	assert.Contains(t, out.String(), `"msg":"shown","port":8080`)
+}
@@ -0,0 +1,185 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile is the environment variable naming the configuration file, when the -config flag does not.
+const EnvFile = "OPENDI_MODEL_HUB_CONFIG"
+
+// environment names the environment variable of every setting, by its key, in the order they are documented.
+// The names predate the configuration file, so the database ones keep their own prefix.
+var environment = []struct{ key, env string }{
+	{"server.address", "OPENDI_MODEL_HUB_ADDRESS"},
+	{"server.port", "OPENDI_MODEL_HUB_PORT"},
+	{"database.driver", "OPEN_DI_DB_DRIVER"},
+	{"database.hostname", "OPEN_DI_DB_HOSTNAME"},
+	{"database.port", "OPEN_DI_DB_PORT"},
+	{"database.username", "OPEN_DI_DB_USERNAME"},
+	{"database.password", "OPEN_DI_DB_PASSWORD"},
+	{"database.name", "OPEN_DI_DB_NAME"},
+	{"database.path", "OPEN_DI_DB_PATH"},
+	{"database.sslmode", "OPEN_DI_DB_SSLMODE"},
+	{"database.connect_timeout", "OPEN_DI_DB_CONNECT_TIMEOUT"},
+	{"cors.allow_origins", "OPENDI_MODEL_HUB_CORS_ORIGINS"},
+	{"cors.allow_credentials", "OPENDI_MODEL_HUB_CORS_CREDENTIALS"},
+	{"auth.register_on_login", "OPENDI_MODEL_HUB_REGISTER_ON_LOGIN"},
+	{"limits.max_body_size", "OPENDI_MODEL_HUB_MAX_BODY_SIZE"},
+	{"limits.commit_retention", "OPENDI_MODEL_HUB_COMMIT_RETENTION"},
+	{"logging.level", "OPENDI_MODEL_HUB_LOG_LEVEL"},
+	{"logging.format", "OPENDI_MODEL_HUB_LOG_FORMAT"},
+}
+
+// describe returns the key of a setting along with its environment variable, for error messages.
+func describe(key string) string {
+	for _, setting := range environment {
+		if setting.key == key {
+			return key + " (" + setting.env + ")"
+		}
+	}
+	return key
+}
+
+// define defines a flag named after the key of every setting on the flag set, bound to the setting in c.
+func (c *Config) define(flags *flag.FlagSet) {
+	flags.StringVar(&c.Server.Address, "server.address", c.Server.Address, "`address` the API listens on, every interface when empty")
+	flags.IntVar(&c.Server.Port, "server.port", c.Server.Port, "`port` the API listens on")
+	flags.StringVar(&c.Database.Driver, "database.driver", c.Database.Driver, "database `driver`: mysql, postgres, sqlite or memory")
+	flags.StringVar(&c.Database.Hostname, "database.hostname", c.Database.Hostname, "`hostname` of the database server")
+	flags.IntVar(&c.Database.Port, "database.port", c.Database.Port, "`port` of the database server")
+	flags.StringVar(&c.Database.Username, "database.username", c.Database.Username, "`username` on the database server")
+	flags.StringVar(&c.Database.Password, "database.password", c.Database.Password, "`password` on the database server")
+	flags.StringVar(&c.Database.Name, "database.name", c.Database.Name, "`name` of the database on the server")
+	flags.StringVar(&c.Database.Path, "database.path", c.Database.Path, "`path` of the SQLite database file")
+	flags.StringVar(&c.Database.SSLMode, "database.sslmode", c.Database.SSLMode, "sslmode of PostgreSQL connections")
+	flags.Var(&c.Database.ConnectTimeout, "database.connect_timeout", "how long to wait for the database server to accept connections")
+	flags.Var(&c.CORS.AllowOrigins, "cors.allow_origins", "comma separated `origins` allowed to call the API from the browser, or *")
+	flags.BoolVar(&c.CORS.AllowCredentials, "cors.allow_credentials", c.CORS.AllowCredentials, "let browsers send credentials with cross-origin requests")
+	flags.BoolVar(&c.Auth.RegisterOnLogin, "auth.register_on_login", c.Auth.RegisterOnLogin, "create a user for every unknown email that logs in")
+	flags.Var(&c.Limits.MaxBodySize, "limits.max_body_size", "largest request body read, such as 64MiB")
+	flags.Var(&c.Limits.CommitRetention, "limits.commit_retention", "how long commits are kept before they are squashed, forever when 0")
+	flags.StringVar(&c.Logging.Level, "logging.level", c.Logging.Level, "least severe `level` logged: debug, info, warn or error")
+	flags.StringVar(&c.Logging.Format, "logging.format", c.Logging.Format, "log `format`: text or json")
+}
+
+// settings returns a flag set bound to the settings of c, to set them by key.
+func (c *Config) settings() *flag.FlagSet {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	c.define(flags)
+	return flags
+}
+
+// LoadFile overrides the configuration with the settings in a YAML or TOML file, chosen by its extension.
+// Settings the file does not mention are left alone, and settings the configuration does not have are errors.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read configuration file: %s", err.Error())
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(c); errors.Is(err, io.EOF) {
+			err = nil // the file is empty
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			keys := make([]string, len(strict.Errors))
+			for i, unknown := range strict.Errors {
+				keys[i] = strings.Join(unknown.Key(), ".")
+			}
+			err = fmt.Errorf("unknown settings %s", strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("configuration file %s is neither YAML nor TOML, expected a .yaml, .yml or .toml extension", path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse configuration file %s: %s", path, err.Error())
+	}
+	return nil
+}
+
+// LoadEnv overrides the configuration with the environment variables lookupEnv finds set and not empty.
+func (c *Config) LoadEnv(lookupEnv func(string) (string, bool)) error {
+	settings := c.settings()
+	for _, setting := range environment {
+		value, ok := lookupEnv(setting.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := settings.Set(setting.key, value); err != nil {
+			return fmt.Errorf("environment variable %s is %q: %s", setting.env, value, err.Error())
+		}
+	}
+	return nil
+}
+
+// Flags are the command-line flags that override the configuration, along with the -config flag naming the
+// configuration file.
+type Flags struct {
+	flags  *flag.FlagSet
+	path   string
+	values Config
+}
+
+// NewFlags defines the configuration flags on a flag set, next to any flags of the command that owns it.
+func NewFlags(flags *flag.FlagSet) *Flags {
+	f := &Flags{flags: flags, values: Default()}
+	flags.StringVar(&f.path, "config", "", "YAML or TOML configuration `file`, "+EnvFile+" when not set")
+	f.values.define(flags)
+	return f
+}
+
+// Load returns the configuration the defaults, the configuration file, the environment and the flags give, each
+// overriding the ones before it. The flag set must already have parsed the command line. The configuration is
+// not validated, so that it can be shown even when it is invalid.
+func (f *Flags) Load(lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := Default()
+
+	path := f.path
+	if path == "" {
+		path, _ = lookupEnv(EnvFile)
+	}
+	if path != "" {
+		if err := c.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.LoadEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+
+	// Only the flags given on the command line override, as the others hold defaults.
+	settings := c.settings()
+	var err error
+	f.flags.Visit(func(given *flag.Flag) {
+		if err == nil && settings.Lookup(given.Name) != nil {
+			err = settings.Set(given.Name, given.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,108 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration written as time.ParseDuration reads it, such as 90s or 720h, in files, the
+// environment and flags alike.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set implements flag.Value.
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 90s or 720h", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// Size is a number of bytes, written as a whole number of bytes or of the binary unit it ends with, such as 512KiB.
+type Size int64
+
+// The units of a Size.
+const (
+	B   Size = 1
+	KiB      = 1024 * B
+	MiB      = 1024 * KiB
+	GiB      = 1024 * MiB
+)
+
+// sizeUnits are the units a Size may end with, largest first.
+var sizeUnits = []struct {
+	suffix string
+	size   Size
+}{{"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB}, {"B", B}}
+
+// String writes the size in the largest unit that divides it.
+func (s Size) String() string {
+	for _, unit := range sizeUnits {
+		if s != 0 && s%unit.size == 0 {
+			return strconv.FormatInt(int64(s/unit.size), 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// Set implements flag.Value.
+func (s *Size) Set(value string) error {
+	number, unit := strings.TrimSpace(value), B
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n > int64(1<<63-1)/int64(unit) {
+		return fmt.Errorf("%q is not a size such as 512KiB or 64MiB", value)
+	}
+	*s = Size(n) * unit
+	return nil
+}
+
+func (s Size) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Size) UnmarshalText(text []byte) error {
+	return s.Set(string(text))
+}
+
+// List is a list of strings, written as a list in files and separated by commas in the environment and flags.
+type List []string
+
+func (l List) String() string {
+	return strings.Join(l, ",")
+}
+
+// Set implements flag.Value, replacing the list.
+func (l *List) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"opendi/model-hub/api/apiTypes"
+	jsonDiffHelpers "opendi/model-hub/api/jsondiffhelpers"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// addModelUsers adds the creators and updaters of a model and all its components to users, keyed by UUID.
+func addModelUsers(model apiTypes.CausalDecisionModel, users map[string]apiTypes.User) {
+	addMeta := func(meta apiTypes.Meta) {
+		for _, user := range append([]apiTypes.User{meta.Creator}, meta.Updaters...) {
+			if user.UUID != "" {
+				users[user.UUID] = apiTypes.User{UUID: user.UUID, Username: user.Username, Email: user.Email}
+			}
+		}
+	}
+
+	addMeta(model.Meta)
+	for _, diagram := range model.Diagrams {
+		addMeta(diagram.Meta)
+		for _, element := range diagram.Elements {
+			addMeta(element.Meta)
+		}
+		for _, dependency := range diagram.Dependencies {
+			addMeta(dependency.Meta)
+		}
+	}
+}
+
+// ExportModelArchive gathers everything needed to move the model with the given UUID to another hub instance:
+// the model, its commits in version order, every user that appears in any version of it or made one of its
+// commits, and the summaries of its ancestors, earliest first.
+func (s *GormStore) ExportModelArchive(uuid string) (int, *apiTypes.ModelArchive, error) {
+	status, states, commits, err := s.getModelHistory(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	users := map[string]apiTypes.User{}
+	for _, state := range states {
+		addModelUsers(state, users)
+	}
+	for _, commit := range commits {
+		if _, ok := users[commit.UserUUID]; ok || commit.UserUUID == "" {
+			continue
+		}
+		if _, user, err := s.GetUserByUUID(commit.UserUUID); err == nil {
+			users[user.UUID] = apiTypes.User{UUID: user.UUID, Username: user.Username, Email: user.Email}
+		}
+	}
+
+	status, lineage, err := s.GetModelLineage(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	archive := &apiTypes.ModelArchive{
+		Model:   states[len(states)-1],
+		Commits: commits,
+		Users:   []apiTypes.User{},
+		Lineage: []apiTypes.ModelSummary{},
+	}
+	for _, user := range users {
+		archive.Users = append(archive.Users, user)
+	}
+	sort.Slice(archive.Users, func(i, j int) bool {
+		if archive.Users[i].Email != archive.Users[j].Email {
+			return archive.Users[i].Email < archive.Users[j].Email
+		}
+		return archive.Users[i].UUID < archive.Users[j].UUID
+	})
+	for _, ancestor := range lineage {
+		archive.Lineage = append(archive.Lineage, summarizeModel(ancestor))
+	}
+
+	return http.StatusOK, archive, nil
+}
+
+// checkArchiveHistory checks that the commits of an archive are numbered from 1 without gaps and that their
+// diffs can be undone one by one from the archived model, so every version of it can be reconstructed.
+func checkArchiveHistory(archive *apiTypes.ModelArchive) error {
+	for i, commit := range archive.Commits {
+		if commit.Version != i+1 {
+			return fmt.Errorf("commit history is missing version %d", i+1)
+		}
+	}
+
+	modelBytes, err := json.Marshal(archive.Model)
+	if err != nil {
+		return err
+	}
+	for i := len(archive.Commits) - 1; i >= 0; i-- {
+		modelBytes, err = jsonDiffHelpers.ApplyInvertedPatch(modelBytes, []byte(archive.Commits[i].Diff))
+		if err != nil {
+			return fmt.Errorf("diff of version %d does not apply to the model: %s", archive.Commits[i].Version, err.Error())
+		}
+	}
+	return nil
+}
+
+// importUsers matches the users of an archive to the users of this instance by email, creating the ones that
+// don't exist yet. New users keep their UUID unless another user already has it.
+// Returns the map from the UUIDs of the archived users to the UUIDs of their users here, for the ones that differ.
+func importUsers(tx *gorm.DB, users []apiTypes.User) (map[string]string, error) {
+	uuids := map[string]string{}
+	for _, user := range users {
+		if user.Email == "" {
+			continue
+		}
+
+		var existingUser apiTypes.User
+		if err := tx.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
+			if user.UUID != "" && existingUser.UUID != user.UUID {
+				uuids[user.UUID] = existingUser.UUID
+			}
+			continue
+		}
+
+		newUser := apiTypes.User{UUID: user.UUID, Username: user.Username, Email: user.Email}
+		if newUser.Username == "" {
+			newUser.Username = user.Email
+		}
+		var count int64
+		tx.Model(&apiTypes.User{}).Where("uuid = ?", user.UUID).Count(&count)
+		if user.UUID == "" || count > 0 {
+			newUUID, err := generateUUID()
+			if err != nil {
+				return nil, err
+			}
+			newUser.UUID = newUUID
+			if user.UUID != "" {
+				uuids[user.UUID] = newUUID
+			}
+		}
+
+		if err := tx.Create(&newUser).Error; err != nil {
+			return nil, fmt.Errorf("could not create user %s: %s", user.Email, err.Error())
+		}
+	}
+	return uuids, nil
+}
+
+// ImportModelArchive creates a model from an archive exported from another hub instance, keeping the UUIDs of the
+// model and its components and the versions of its commits. The users of the archive are matched to the users of
+// this instance by email, and the user UUIDs recorded on the commits are remapped to theirs.
+// The parent of the model is linked if it exists here; otherwise its UUID is kept as a reference.
+func (s *GormStore) ImportModelArchive(archive *apiTypes.ModelArchive) (int, *apiTypes.CausalDecisionModel, error) {
+	model := archive.Model
+	if model.Meta.UUID == "" {
+		return http.StatusBadRequest, nil, fmt.Errorf("archived model has no uuid")
+	}
+
+	// Check the history before anything is written, so a broken archive isn't half imported.
+	if err := checkArchiveHistory(archive); err != nil {
+		return http.StatusUnprocessableEntity, nil, err
+	}
+
+	// The UUIDs are kept, so none of them may already be in use.
+	_, uuids := flattenModelComponents(model)
+	var count int64
+	if err := s.db.Model(&apiTypes.Meta{}).Where("uuid IN ?", uuids).Count(&count).Error; err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	if count > 0 {
+		return http.StatusConflict, nil, fmt.Errorf("model with uuid %s or some of its components already exist", model.Meta.UUID)
+	}
+
+	// Begin transaction.
+	transaction := s.db.Begin()
+	if transaction.Error != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
+	}
+
+	userUUIDs, err := importUsers(transaction, archive.Users)
+	if err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, err
+	}
+
+	// Creators and updaters are matched to the imported users by email.
+	if err := matchUUIDsToID(transaction, &model); err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, err
+	}
+	if err := transaction.Create(&model.Meta).Error; err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not create model meta: %s", err.Error())
+	}
+	if err := transaction.Create(&model).Error; err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not create model: %s", err.Error())
+	}
+
+	// Recreate the commits in order, chaining each to the one before it.
+	parentCommitID := ""
+	for _, archived := range archive.Commits {
+		commit := archived
+		commit.ID = 0
+		commit.CDMUUID = model.Meta.UUID
+		commit.ParentCommitID = parentCommitID
+		if newUUID, ok := userUUIDs[commit.UserUUID]; ok {
+			commit.UserUUID = newUUID
+		}
+		if len(userUUIDs) > 0 {
+			diff, err := remapUUIDs(json.RawMessage(commit.Diff), userUUIDs)
+			if err != nil {
+				transaction.Rollback()
+				return http.StatusUnprocessableEntity, nil, fmt.Errorf("diff of version %d is not valid JSON: %s", commit.Version, err.Error())
+			}
+			commit.Diff = string(diff)
+		}
+
+		if err := transaction.Create(&commit).Error; err != nil {
+			transaction.Rollback()
+			return http.StatusInternalServerError, nil, fmt.Errorf("could not create commit: %s", err.Error())
+		}
+		parentCommitID = fmt.Sprintf("%d", commit.ID)
+	}
+
+	// Commit the transaction; error out if commit fails.
+	if err := transaction.Commit().Error; err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	status, imported, err := s.GetModelByUUID(model.Meta.UUID)
+	if err != nil {
+		return status, nil, err
+	}
+	return http.StatusCreated, imported, nil
+}
@@ -0,0 +1,218 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"opendi/model-hub/api/apiTypes"
+
+	"gorm.io/gorm"
+)
+
+// BulkImportOptions configures CreateModelsInBulk.
+// Atomic creates every model in a single transaction, so either all of them are created or none are.
+// ProvisionCreators creates the creators of models that don't have an account yet, instead of rejecting the models.
+type BulkImportOptions struct {
+	Atomic            bool
+	ProvisionCreators bool
+}
+
+// bulkOrder orders the models of a batch so that every model comes after its parent when the parent is in the same
+// batch, identified by the UUID it was uploaded with. Models whose parent references loop back on themselves are
+// returned separately, since they can't be created.
+func bulkOrder(models []apiTypes.CausalDecisionModel, batchUUIDs map[string]int) ([]int, []int) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(models))
+	var order, cyclic []int
+
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		switch state[i] {
+		case visiting:
+			return false
+		case done:
+			return true
+		}
+		state[i] = visiting
+		ok := true
+		if parent, inBatch := batchUUIDs[models[i].ParentUUID]; inBatch {
+			ok = visit(parent)
+		}
+		state[i] = done
+		if ok {
+			order = append(order, i)
+		} else {
+			cyclic = append(cyclic, i)
+		}
+		return ok
+	}
+
+	for i := range models {
+		visit(i)
+	}
+	return order, cyclic
+}
+
+// resolveCreator matches the creator of a model to an existing user by email, creating the user if provision is set.
+func resolveCreator(tx *gorm.DB, model *apiTypes.CausalDecisionModel, provision bool) (int, error) {
+	email := model.Meta.Creator.Email
+	if email == "" {
+		return http.StatusBadRequest, fmt.Errorf("model has no creator email")
+	}
+
+	var user apiTypes.User
+	if err := tx.Where("email = ?", email).First(&user).Error; err != nil {
+		if !provision {
+			return http.StatusConflict, fmt.Errorf("could not find creator: %s", email)
+		}
+
+		uuid, err := generateUUID()
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		user = apiTypes.User{UUID: uuid, Username: model.Meta.Creator.Username, Email: email}
+		if user.Username == "" {
+			user.Username = email
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("could not create creator: %s", err.Error())
+		}
+	}
+
+	model.Meta.Creator = user
+	model.Meta.CreatorID = user.ID
+	return http.StatusOK, nil
+}
+
+// CreateModelsInBulk creates a batch of models, recording the outcome of each in results, which is indexed like models.
+// Models whose result already holds an error were rejected by the caller and are not created.
+// Like CreateModelGivenEmail, every model is given a new UUID and must have a creator with an email. A ParentUUID
+// naming the uploaded UUID of another model in the batch is rewritten to the UUID that model is created with, and
+// parents are created before their children. Children of models that aren't created aren't created either.
+func (s *GormStore) CreateModelsInBulk(models []apiTypes.CausalDecisionModel, results []apiTypes.BulkImportResult, options BulkImportOptions) {
+	failed := false
+	for i := range results {
+		results[i].Index = i
+		failed = failed || results[i].Error != ""
+	}
+	fail := func(i int, status int, err error) {
+		results[i].UUID = ""
+		results[i].Status = status
+		results[i].Error = err.Error()
+		failed = true
+	}
+
+	// Index the models by the UUIDs they were uploaded with, so their children in the batch can find them.
+	batchUUIDs := map[string]int{}
+	for i, model := range models {
+		if model.Meta.UUID == "" {
+			continue
+		}
+		if first, ok := batchUUIDs[model.Meta.UUID]; ok {
+			if results[i].Error == "" {
+				fail(i, http.StatusConflict, fmt.Errorf("uuid %s is used by model %d of the batch too", model.Meta.UUID, first))
+			}
+			continue
+		}
+		batchUUIDs[model.Meta.UUID] = i
+	}
+
+	order, cyclic := bulkOrder(models, batchUUIDs)
+	for _, i := range cyclic {
+		fail(i, http.StatusUnprocessableEntity, fmt.Errorf("parent references within the batch form a cycle"))
+	}
+
+	var transaction *gorm.DB
+	if options.Atomic {
+		if transaction = s.db.Begin(); transaction.Error != nil {
+			for i := range results {
+				fail(i, http.StatusInternalServerError, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error()))
+			}
+			return
+		}
+	}
+
+	for _, i := range order {
+		if results[i].Error != "" || (options.Atomic && failed) {
+			continue
+		}
+		model := models[i]
+
+		if parent, ok := batchUUIDs[model.ParentUUID]; ok {
+			if results[parent].Error != "" {
+				fail(i, http.StatusFailedDependency, fmt.Errorf("parent model %d of the batch was not created", parent))
+				continue
+			}
+			model.ParentUUID = results[parent].UUID
+		}
+
+		// An atomic import reads through its transaction, as the transaction may lock out other connections.
+		store := s
+		if options.Atomic {
+			store = NewGormStore(transaction)
+		}
+		uuid, err := store.generateUniqueMetaUUID()
+		if err != nil {
+			fail(i, http.StatusInternalServerError, err)
+			continue
+		}
+		model.Meta.UUID = uuid
+		// Only forking a model sets the versions it was forked from.
+		model.ForkedFromVersion = nil
+		model.SyncedAtVersion = nil
+
+		tx := transaction
+		if !options.Atomic {
+			if tx = s.db.Begin(); tx.Error != nil {
+				fail(i, http.StatusInternalServerError, fmt.Errorf("could not begin transaction: %s", tx.Error.Error()))
+				continue
+			}
+		}
+
+		status, err := resolveCreator(tx, &model, options.ProvisionCreators)
+		if err == nil {
+			// Anything that goes wrong from here on is not the fault of the model.
+			status = http.StatusInternalServerError
+			err = insertModel(tx, &model)
+		}
+		if !options.Atomic {
+			if err == nil {
+				err = tx.Commit().Error
+			} else {
+				tx.Rollback()
+			}
+		}
+		if err != nil {
+			fail(i, status, err)
+			continue
+		}
+
+		results[i].UUID = uuid
+		results[i].Status = http.StatusCreated
+	}
+
+	if options.Atomic {
+		if !failed {
+			if err := transaction.Commit().Error; err != nil {
+				for i := range results {
+					fail(i, http.StatusInternalServerError, fmt.Errorf("could not commit transaction: %s", err.Error()))
+				}
+			}
+			return
+		}
+
+		transaction.Rollback()
+		for i := range results {
+			if results[i].Error == "" {
+				fail(i, http.StatusFailedDependency, fmt.Errorf("not created because another model of the batch failed"))
+			}
+		}
+	}
+}
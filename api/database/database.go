@@ -7,114 +7,138 @@ package database
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/config"
+	jsonDiffHelpers "opendi/model-hub/api/jsondiffhelpers"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/wI2L/jsondiff"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// global db instance
-var dbInstance *gorm.DB
+// ResetTables drops every table of the database, whether or not a migration created it, and migrates the empty
+// database to the latest schema.
+func (s *GormStore) ResetTables() {
 
-func CreateTablesIfNotCreated() error {
+	// Drop all tables
+	tables, _ := s.db.Migrator().GetTables() // Get all table names
 
-	// AutoMigrate all the structs defined in apitypes.go
-	err := dbInstance.AutoMigrate(
-		&apiTypes.CausalDecisionModel{},
-		&apiTypes.User{},
-		&apiTypes.Meta{},
-		&apiTypes.Diagram{},
-		&apiTypes.DiaElement{},
-		&apiTypes.CausalDependency{},
-		&apiTypes.Commit{},
-	)
-	return err
+	for _, table := range tables {
+		if strings.HasPrefix(table, "sqlite_") {
+			continue // SQLite keeps its own bookkeeping in tables that can't be dropped
+		}
+		s.db.Migrator().DropTable(table)
+	}
+
+	if _, err := s.Migrate(LatestSchemaVersion()); err != nil {
+		fmt.Println("Error migrating database: ", err)
+	}
 
 }
 
-func ResetTables() {
+// Open connects to the database the settings describe, without checking its schema. The settings choose between
+// a MySQL or PostgreSQL server, a SQLite file and a database kept in memory, which is migrated to the latest schema
+// as nothing else could migrate it. A server that cannot be reached yet, as while it starts up next to the hub,
+// is tried again until the connect timeout of the settings runs out. Returns a store backed by the connection.
+func Open(settings config.Database) (*GormStore, error) {
 
-	dbInstance := GetDBInstance()
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
 
-	// Drop all tables
-	var tables []string
-	dbInstance.Raw("SHOW TABLES").Scan(&tables) // Get all table names
+	deadline := time.Now().Add(time.Duration(settings.ConnectTimeout))
+	var db *gorm.DB
+	for {
+		dialector, err := dialectorFor(settings)
+		if err != nil {
+			return nil, err
+		}
+		db, err = gorm.Open(dialector, &gorm.Config{})
+		if err == nil {
+			break
+		}
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
+		var netErr *net.OpError
+		if !errors.As(err, &netErr) || time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(time.Second)
+	}
 
-	for _, table := range tables {
-		dbInstance.Migrator().DropTable(table)
+	store := NewGormStore(db)
+	if settings.Driver == DriverMemory {
+		if _, err := store.Migrate(LatestSchemaVersion()); err != nil {
+			store.Close()
+			return nil, err
+		}
 	}
 
-	CreateTablesIfNotCreated()
+	return store, nil
 
 }
 
-// initialize db instance with expected tables.
-func InitializeDBInstance() (int, error) {
-
-	// Construct the Data Source Name (DSN) for the database connection
+// Initialize connects to the database the settings describe, like Open, and checks that its schema is at the
+// version this build expects.
+func Initialize(settings config.Database) (*GormStore, error) {
 
-	// Check to make sure the environment variables for the database connection are set before using them
-	username, ok := os.LookupEnv("OPEN_DI_DB_USERNAME")
-	if !ok || username == "" {
-		return 1, fmt.Errorf("environment variable OPEN_DI_DB_USERNAME is not set or empty")
-	}
-	password, ok := os.LookupEnv("OPEN_DI_DB_PASSWORD")
-	if !ok || password == "" {
-		return 1, fmt.Errorf("environment variable OPEN_DI_DB_PASSWORD is not set or empty")
-	}
-	hostname, ok := os.LookupEnv("OPEN_DI_DB_HOSTNAME")
-	if !ok || hostname == "" {
-		return 1, fmt.Errorf("environment variable OPEN_DI_DB_HOSTNAME is not set or empty")
-	}
-	port, ok := os.LookupEnv("OPEN_DI_DB_PORT")
-	if !ok || port == "" {
-		return 1, fmt.Errorf("environment variable OPEN_DI_DB_PORT is not set or empty")
+	store, err := Open(settings)
+	if err != nil {
+		return nil, err
 	}
-	dbname, ok := os.LookupEnv("OPEN_DI_DB_NAME")
-	if !ok || dbname == "" {
-		return 1, fmt.Errorf("environment variable OPEN_DI_DB_NAME is not set or empty")
+
+	if err := store.CheckSchema(); err != nil {
+		store.Close()
+		return nil, err
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", username, password, hostname, port, dbname)
+	return store, nil
 
-	var err error
-	if dbInstance != nil {
-		sqlDB, _ := dbInstance.DB()
-		sqlDB.Close()
-		dbInstance = nil
+}
 
+// settingsFromEnv returns the database settings the OPEN_DI_DB_* environment variables give.
+func settingsFromEnv() (config.Database, error) {
+	c := config.Default()
+	if err := c.LoadEnv(os.LookupEnv); err != nil {
+		return config.Database{}, err
 	}
+	return c.Database, nil
+}
 
-	dbInstance, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// OpenDBInstance connects to the database the OPEN_DI_DB_* environment variables configure, like Open.
+func OpenDBInstance() (*GormStore, error) {
+	settings, err := settingsFromEnv()
 	if err != nil {
-		dbInstance = nil
-		return 1, err
+		return nil, err
 	}
+	return Open(settings)
+}
 
-	err = CreateTablesIfNotCreated()
+// InitializeDBInstance connects to the database the OPEN_DI_DB_* environment variables configure, like Initialize.
+func InitializeDBInstance() (*GormStore, error) {
+	settings, err := settingsFromEnv()
 	if err != nil {
-		return 1, err
+		return nil, err
 	}
-
-	return 0, nil
-
-}
-
-// gets singleton db instance
-func GetDBInstance() *gorm.DB {
-	return dbInstance
+	return Initialize(settings)
 }
 
 // function for getting all models in Go struct  - remember, in Go, public methods have to be capitalized
-func GetAllModels() (int, []apiTypes.CausalDecisionModel, error) {
+func (s *GormStore) GetAllModels() (int, []apiTypes.CausalDecisionModel, error) {
 	var models []apiTypes.CausalDecisionModel
 	// Updated query to preload associated fields
-	if err := dbInstance.
+	if err := s.db.
 		Preload("Meta").
 		Preload("Diagrams").
 		Preload("Diagrams.Meta").
@@ -133,10 +157,10 @@ func GetAllModels() (int, []apiTypes.CausalDecisionModel, error) {
 }
 
 // function for getting all commits in Go struct  - remember, in Go, public methods have to be capitalized
-func GetAllCommits() (int, []apiTypes.Commit, error) {
+func (s *GormStore) GetAllCommits() (int, []apiTypes.Commit, error) {
 	var commits []apiTypes.Commit
 	// Updated query to preload associated fields
-	if err := dbInstance.
+	if err := s.db.
 		Find(&commits).Error; err != nil {
 		return http.StatusInternalServerError, nil, err
 	}
@@ -146,9 +170,9 @@ func GetAllCommits() (int, []apiTypes.Commit, error) {
 }
 
 // helper function for creating a user given a user object. Doesn't check for if it's possible to create
-func createUserGivenObject(user apiTypes.User) (*apiTypes.User, error) {
+func (s *GormStore) createUserGivenObject(user apiTypes.User) (*apiTypes.User, error) {
 	// Begin transaction.
-	transaction := dbInstance.Begin()
+	transaction := s.db.Begin()
 	if transaction.Error != nil {
 		return nil, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
 	}
@@ -165,7 +189,7 @@ func createUserGivenObject(user apiTypes.User) (*apiTypes.User, error) {
 // Example method that creates sample models in the database
 // creates 2 models, parent and child.
 // also creates creators for those models
-func CreateExampleModels() {
+func (s *GormStore) CreateExampleModels() {
 	creator := apiTypes.User{
 		ID:       1,
 		UUID:     "user-uuid-creator",
@@ -174,7 +198,7 @@ func CreateExampleModels() {
 		Password: "p",
 	}
 
-	createUserGivenObject(creator)
+	s.createUserGivenObject(creator)
 	/*
 		updater := apiTypes.User{
 			ID:       2,
@@ -213,7 +237,7 @@ func CreateExampleModels() {
 		Diagrams:  nil,
 	}
 
-	if err := dbInstance.Create(&model).Error; err != nil {
+	if err := s.db.Create(&model).Error; err != nil {
 		fmt.Println("Error creating model: ", err)
 	}
 
@@ -226,7 +250,7 @@ func CreateExampleModels() {
 		Password: "p",
 	}
 
-	createUserGivenObject(childCreator)
+	s.createUserGivenObject(childCreator)
 
 	/*
 		childUpdater := apiTypes.User{
@@ -268,10 +292,25 @@ func CreateExampleModels() {
 		Diagrams:   nil,
 	}
 
-	if err := dbInstance.Create(&childModel).Error; err != nil {
+	if err := s.db.Create(&childModel).Error; err != nil {
 		fmt.Println("Error creating child model: ", err)
 	}
 
+	s.syncSequences()
+}
+
+// syncSequences moves the sequences PostgreSQL numbers new rows with past the largest ID of their table, as rows
+// created with fixed IDs, such as the example models, don't advance them.
+func (s *GormStore) syncSequences() {
+	if s.db.Dialector.Name() != DriverPostgres {
+		return
+	}
+	tables, _ := s.db.Migrator().GetTables()
+	for _, table := range tables {
+		if s.db.Migrator().HasColumn(table, "id") {
+			s.db.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), MAX(id)) FROM ?", table, clause.Table{Name: table})
+		}
+	}
 }
 
 //an example  of what this can do is it can allow upload model to upload a model with a diagram that already existed in the database
@@ -531,65 +570,65 @@ func generateUUID() (string, error) {
 }
 
 // CreateModel encapsulates the GORM functionality for creating a model with its metadata in a transaction
-func CreateModel(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
+func (s *GormStore) CreateModel(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
 	// No need to ensure no other model with the same UUID exists. CreateModelGivenEmail creates a unique UUID for us.
 
+	// Only forking a model sets the versions it was forked from.
+	uploadedModel.ForkedFromVersion = nil
+	uploadedModel.SyncedAtVersion = nil
+
 	// Begin transaction.
-	transaction := dbInstance.Begin()
+	transaction := s.db.Begin()
 	if transaction.Error != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
 	}
 
+	if err := insertModel(transaction, uploadedModel); err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, err
+	}
+
+	// Commit the transaction; error out if commit fails.
+	if err := transaction.Commit().Error; err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return http.StatusCreated, nil
+}
+
+// insertModel creates a model with its metadata in the given transaction.
+func insertModel(tx *gorm.DB, uploadedModel *apiTypes.CausalDecisionModel) error {
 	// Match all UUIDs in the model to existing database IDs where possible
 	// This will ensure that we are not duplicating pre-existing components
 	// but rather reusing them.
-	if err := matchUUIDsToID(transaction, uploadedModel); err != nil {
-		transaction.Rollback()
-		return http.StatusInternalServerError, err
+	if err := matchUUIDsToID(tx, uploadedModel); err != nil {
+		return err
 	}
 
 	// Create meta in transaction; error out on failure.
-	if err := transaction.Create(&uploadedModel.Meta).Error; err != nil {
-		transaction.Rollback()
-		return http.StatusInternalServerError, fmt.Errorf("could not create model meta: %s", err.Error())
+	if err := tx.Create(&uploadedModel.Meta).Error; err != nil {
+		return fmt.Errorf("could not create model meta: %s", err.Error())
 	}
 
 	// Create the model in transaction; error out on failure.
-	if err := transaction.Create(&uploadedModel).Error; err != nil {
-		transaction.Rollback()
-		return http.StatusInternalServerError, fmt.Errorf("could not create model: %s", err.Error())
-	}
-
-	// Commit the transaction; error out if commit fails.
-	if err := transaction.Commit().Error; err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("could not commit transaction: %s", err.Error())
+	if err := tx.Create(&uploadedModel).Error; err != nil {
+		return fmt.Errorf("could not create model: %s", err.Error())
 	}
 
-	return http.StatusCreated, nil
+	return nil
 }
 
 // Creates model in database given emails of creator
 // this method expects a model with the Creator object filled in with a non-null Email.
 // the updaters functionality is not done yet.
-func CreateModelGivenEmail(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
+func (s *GormStore) CreateModelGivenEmail(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
 
-	var count int64
 	//keep generating UUIDs until a unique one is found
-	for {
-		// Generate a UUID for the model.
-		uuid, err := generateUUID()
-		if err != nil {
-			return http.StatusInternalServerError, fmt.Errorf("could not generate UUID: %s", err.Error())
-		}
-		uploadedModel.Meta.UUID = uuid
-
-		// Ensure no other model with the same UUID exists.
-		dbInstance.Model(&apiTypes.Meta{}).Where("uuid = ?", uploadedModel.Meta.UUID).Count(&count)
-		if count == 0 {
-			break
-		}
-
+	uuid, err := s.generateUniqueMetaUUID()
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
+	uploadedModel.Meta.UUID = uuid
 
 	/*
 		// Try to retrieve updater id information from the meta, then find an updater with that id in the database.
@@ -615,28 +654,28 @@ func CreateModelGivenEmail(uploadedModel *apiTypes.CausalDecisionModel) (int, er
 	// this method expects a model with the Creator object filled in with a non-null Email.
 	email := uploadedModel.Meta.Creator.Email
 	//string is not copied
-	status, user, _ := GetUserByEmail(email)
+	status, user, _ := s.GetUserByEmail(email)
 	if status != http.StatusOK {
 		return http.StatusConflict, fmt.Errorf("could not find creator: %s", email)
 	}
 	uploadedModel.Meta.Creator = *user
 	uploadedModel.Meta.CreatorID = user.ID
-	return CreateModel(uploadedModel)
+	return s.CreateModel(uploadedModel)
 }
 
 // GetModelByUUID encapsulates the GORM functionality for getting a model by its UUID
-func GetModelByUUID(uuid string) (int, *apiTypes.CausalDecisionModel, error) {
+func (s *GormStore) GetModelByUUID(uuid string) (int, *apiTypes.CausalDecisionModel, error) {
 	var meta apiTypes.Meta
 
 	// Find the meta record with the given UUID.
-	if err := dbInstance.Where("uuid = ?", uuid).First(&meta).Error; err != nil {
+	if err := s.db.Where("uuid = ?", uuid).First(&meta).Error; err != nil {
 		return http.StatusNotFound, nil, fmt.Errorf("meta with uuid %s not found", uuid)
 	}
 
 	var model apiTypes.CausalDecisionModel
 
 	// Find the model that has the found meta record, preloading associated fields.
-	if err := dbInstance.
+	if err := s.db.
 		Preload("Meta").
 		Preload("Diagrams").
 		Preload("Diagrams.Meta").
@@ -661,21 +700,33 @@ func GetModelByUUID(uuid string) (int, *apiTypes.CausalDecisionModel, error) {
 }
 
 // GetUserByID encapsulates the GORM functionality for getting a user by their ID
-func GetUserByID(id int) (int, *apiTypes.User, error) {
+func (s *GormStore) GetUserByID(id int) (int, *apiTypes.User, error) {
 	var user apiTypes.User
 
 	// Find the user record with the given ID.
-	if err := dbInstance.Where("id = ?", id).First(&user).Error; err != nil {
+	if err := s.db.Where("id = ?", id).First(&user).Error; err != nil {
 		return http.StatusNotFound, nil, fmt.Errorf("user with id %d not found", id)
 	}
 
 	return http.StatusOK, &user, nil
 }
 
+// GetUserByUUID encapsulates the GORM functionality for getting a user by their UUID
+func (s *GormStore) GetUserByUUID(uuid string) (int, *apiTypes.User, error) {
+	var user apiTypes.User
+
+	// Find the user record with the given UUID.
+	if err := s.db.Where("uuid = ?", uuid).First(&user).Error; err != nil {
+		return http.StatusNotFound, nil, fmt.Errorf("user with uuid %s not found", uuid)
+	}
+
+	return http.StatusOK, &user, nil
+}
+
 // get the latest commit for a model with the given UUID
-func GetLatestCommitForModelUUID(uuid string) (int, *apiTypes.Commit, error) {
+func (s *GormStore) GetLatestCommitForModelUUID(uuid string) (int, *apiTypes.Commit, error) {
 	var commit apiTypes.Commit
-	err := dbInstance.Where("cdm_uuid = ?", uuid).
+	err := s.db.Where("cdm_uuid = ?", uuid).
 		Order("created_at DESC").
 		First(&commit).Error
 
@@ -689,54 +740,194 @@ func GetLatestCommitForModelUUID(uuid string) (int, *apiTypes.Commit, error) {
 }
 
 // gets commit by primary key id
-func GetCommitByID(id int) (int, *apiTypes.Commit, error) {
+func (s *GormStore) GetCommitByID(id int) (int, *apiTypes.Commit, error) {
 	var commit apiTypes.Commit
-	err := dbInstance.Where("id = ?", id).First(&commit).Error
+	err := s.db.Where("id = ?", id).First(&commit).Error
 	if err != nil {
 		return http.StatusNotFound, nil, err
 	}
 	return http.StatusOK, &commit, nil
 }
 
+// get the latest commit for a model with the given UUID that was made at or before the given time
+func (s *GormStore) GetLatestCommitForModelUUIDAsOf(uuid string, asOf time.Time) (int, *apiTypes.Commit, error) {
+	var commit apiTypes.Commit
+	err := s.db.Where("cdm_uuid = ? AND created_at <= ?", uuid, asOf).
+		Order("version DESC").
+		First(&commit).Error
+
+	if err == gorm.ErrRecordNotFound {
+		return http.StatusNotFound, nil, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	return http.StatusOK, &commit, nil
+}
+
+// GetVersionOfModel reconstructs the model with the given UUID as it was at the given commit version.
+// Version 0 is the model as it was originally uploaded, before any commits were made.
+// The inverted diffs of the commits are applied to the latest version of the model, starting from
+// the latest commit and going backwards until the requested version is reached.
+func (s *GormStore) GetVersionOfModel(uuid string, version int) (int, *apiTypes.CausalDecisionModel, error) {
+	//get latest version of model.
+	_, latestVersionOfModel, err := s.GetModelByUUID(uuid)
+	if err != nil {
+		return http.StatusNotFound, nil, err
+	}
+	//get latest commit for model UUID.
+	status, commit, err := s.GetLatestCommitForModelUUID(uuid)
+	if version == 0 && status == http.StatusNotFound {
+		return http.StatusOK, latestVersionOfModel, nil
+	}
+	if status == http.StatusNotFound {
+		return http.StatusConflict, nil, fmt.Errorf("Version requested is greater than the latest version")
+	}
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	//if the version requested is the latest version of the model, just return.
+	if version == commit.Version {
+		return http.StatusOK, latestVersionOfModel, nil
+	}
+	//if the version requested is greater than the latest version of the model, return error.
+	if version > commit.Version {
+		return http.StatusConflict, nil, fmt.Errorf("Version requested is greater than the latest version")
+	}
+	if version < 0 {
+		return http.StatusBadRequest, nil, fmt.Errorf("Version requested is less than 0")
+	}
+
+	currVersion := commit.Version
+	currCommit := commit
+
+	currModelBytes, err := json.Marshal(latestVersionOfModel)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	// loop through the commits until we reach the version we want.
+	// we need to apply the diff to the model in reverse order, so we start with the latest commit and go backwards.
+	for {
+		diff := []byte(currCommit.Diff)
+		modified, err := jsonDiffHelpers.ApplyInvertedPatch(currModelBytes, diff)
+		if err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+
+		//reset variables for next iteration of applying patches
+		currModelBytes = modified
+		currVersion--
+		parentIdStr := currCommit.ParentCommitID
+
+		//if we reach the version we want, return the model
+		if currVersion <= version {
+			break
+		}
+
+		//if we encounter a null parent id, return error.
+		if parentIdStr == "" {
+			return http.StatusInternalServerError, nil, fmt.Errorf("No parent ID")
+		}
+
+		parentId, _ := strconv.ParseInt(parentIdStr, 10, 64)
+
+		_, currCommit, err = s.GetCommitByID(int(parentId))
+		if err != nil {
+			return http.StatusInternalServerError, nil, fmt.Errorf("could not find parent commit %s: %s", parentIdStr, err.Error())
+		}
+	}
+
+	var finalModel apiTypes.CausalDecisionModel
+	if err := json.Unmarshal(currModelBytes, &finalModel); err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	return http.StatusOK, &finalModel, nil
+}
+
+// GetModelByUUIDAsOf reconstructs the model with the given UUID as it was at the given time.
+// The state is resolved to the latest commit made at or before that time, or to the
+// originally uploaded model if no commits had been made yet.
+func (s *GormStore) GetModelByUUIDAsOf(uuid string, asOf time.Time) (int, *apiTypes.CausalDecisionModel, error) {
+	status, model, err := s.GetModelByUUID(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	// The model can't be reconstructed for a time before it was uploaded.
+	if model.CreatedAt.After(asOf) {
+		return http.StatusNotFound, nil, fmt.Errorf("model with uuid %s did not exist at %s", uuid, asOf.Format(time.RFC3339))
+	}
+
+	version := 0
+	status, commit, err := s.GetLatestCommitForModelUUIDAsOf(uuid, asOf)
+	if status == http.StatusInternalServerError {
+		return status, nil, err
+	}
+	if status == http.StatusOK {
+		version = commit.Version
+	}
+
+	return s.GetVersionOfModel(uuid, version)
+}
+
 // UpdateModel encapsulates the GORM functionality for updating a model with its metadata in a transaction. This is a helper method for a PUT to a model.
 //
 //	Currently, for diagrams associated with the model, it creates diagrams that are not already in the database.
 //
 // however, for exisitng diagrams, it doesn't change them.
-func UpdateModel(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
+func (s *GormStore) UpdateModel(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
 	// Begin transaction.
-	transaction := dbInstance.Begin()
+	transaction := s.db.Begin()
 	if transaction.Error != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
 	}
 
-	// Match all UUIDs in the uploaded model to existing database IDs
-	if err := matchUUIDsToID(transaction, uploadedModel); err != nil {
+	if status, err := updateModel(transaction, uploadedModel); err != nil {
 		transaction.Rollback()
+		return status, err
+	}
+
+	// Commit the transaction
+	if err := transaction.Commit().Error; err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return http.StatusCreated, nil
+}
+
+// updateModel updates a model with its metadata in the given transaction.
+func updateModel(tx *gorm.DB, uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
+	// Match all UUIDs in the uploaded model to existing database IDs
+	if err := matchUUIDsToID(tx, uploadedModel); err != nil {
 		return http.StatusInternalServerError, err
 	}
 
-	// First, get the existing model with all associations to properly handle removals
+	// First, get the existing model with all associations to properly handle removals. Its row stays locked until
+	// the transaction ends, so the update waits for a rewrite of the history of the model to finish.
 	var existingModel apiTypes.CausalDecisionModel
-	if err := transaction.
+	if err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
 		Preload("Meta").
 		Preload("Meta.Updaters").
 		Preload("Diagrams").
 		Where("meta_id = ?", uploadedModel.Meta.ID).
 		First(&existingModel).Error; err != nil {
-		transaction.Rollback()
 		return http.StatusNotFound, fmt.Errorf("model with UUID %s not found", uploadedModel.Meta.UUID)
 	}
 
+	// The versions a model was forked or synced from are set by the hub, never by the client.
+	uploadedModel.ForkedFromVersion = existingModel.ForkedFromVersion
+	uploadedModel.SyncedAtVersion = existingModel.SyncedAtVersion
+
 	// Clear model diagrams association
-	if err := transaction.Model(&existingModel).Association("Diagrams").Clear(); err != nil {
-		transaction.Rollback()
+	if err := tx.Model(&existingModel).Association("Diagrams").Clear(); err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not clear model diagrams: %s", err.Error())
 	}
 
 	// Clear meta updaters association
-	if err := transaction.Model(&existingModel.Meta).Association("Updaters").Clear(); err != nil {
-		transaction.Rollback()
+	if err := tx.Model(&existingModel.Meta).Association("Updaters").Clear(); err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not clear meta updaters: %s", err.Error())
 	}
 
@@ -752,7 +943,7 @@ func UpdateModel(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
 		if uploadedModel.Diagrams[i].ID != 0 {
 			var existingDiagram apiTypes.Diagram
 			// Do nothing on error, and treat it as a new diagram (don't set it to the existing diagram)
-			if err := transaction.Where("id = ?", uploadedModel.Diagrams[i].ID).First(&existingDiagram).Error; err == nil {
+			if err := tx.Where("id = ?", uploadedModel.Diagrams[i].ID).First(&existingDiagram).Error; err == nil {
 				uploadedModel.Diagrams[i] = existingDiagram
 			}
 		}
@@ -764,50 +955,66 @@ func UpdateModel(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
 		if uploadedModel.Meta.Updaters[i].ID != 0 {
 			var existingUpdater apiTypes.User
 			// Do nothing on error, and treat it as a new updater (don't set it to the existing updater)
-			if err := transaction.Where("id = ?", uploadedModel.Meta.Updaters[i].ID).First(&existingUpdater).Error; err == nil {
+			if err := tx.Where("id = ?", uploadedModel.Meta.Updaters[i].ID).First(&existingUpdater).Error; err == nil {
 				uploadedModel.Meta.Updaters[i] = existingUpdater
 			}
 		}
 	}
 
 	// Update the model meta
-	if err := transaction.Save(&uploadedModel.Meta).Error; err != nil {
-		transaction.Rollback()
+	if err := tx.Save(&uploadedModel.Meta).Error; err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not update model: %s", err.Error())
 	}
 
 	// Update the model
-	if err := transaction.Save(&uploadedModel.Meta).Error; err != nil {
-		transaction.Rollback()
+	if err := tx.Save(&uploadedModel.Meta).Error; err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not update model: %s", err.Error())
 	}
 
 	// Update the model
-	if err := transaction.Save(&uploadedModel).Error; err != nil {
-		transaction.Rollback()
+	if err := tx.Save(&uploadedModel).Error; err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not update model: %s", err.Error())
 	}
 
-	// Commit the transaction
-	if err := transaction.Commit().Error; err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("could not commit transaction: %s", err.Error())
-	}
-
 	return http.StatusCreated, nil
 }
 
 // Database method for PUT to a model.
-func UpdateModelAndCreateCommit(uploadedModel *apiTypes.CausalDecisionModel, oldModel *apiTypes.CausalDecisionModel) (*apiTypes.CausalDecisionModel, int, error) {
+func (s *GormStore) UpdateModelAndCreateCommit(uploadedModel *apiTypes.CausalDecisionModel, oldModel *apiTypes.CausalDecisionModel) (*apiTypes.CausalDecisionModel, int, error) {
+	return s.UpdateModelAndCreateCommitWithMessage(uploadedModel, oldModel, "")
+}
+
+// Database method for PUT to a model, recording a message describing the change on the commit.
+func (s *GormStore) UpdateModelAndCreateCommitWithMessage(uploadedModel *apiTypes.CausalDecisionModel, oldModel *apiTypes.CausalDecisionModel, message string) (*apiTypes.CausalDecisionModel, int, error) {
+	// Update the model and create the commit in one transaction, so a bad put changes neither.
+	transaction := s.db.Begin()
+	if transaction.Error != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
+	}
+
+	changedModel, status, err := NewGormStore(transaction).updateModelAndCreateCommit(uploadedModel, oldModel, message)
+	if err != nil {
+		transaction.Rollback()
+		return nil, status, err
+	}
+
+	// Commit the transaction; error out if commit fails.
+	if err := transaction.Commit().Error; err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
 
-	// Update the model before creating the commit so that on a bad
-	// put, we don't have to roll back the commit.
-	if status, err := UpdateModel(uploadedModel); err != nil {
-		//TODO fix this so that if we get an error here, we roll back the update
-		// Return error based on the UpdateModel function response
+	return changedModel, http.StatusOK, nil
+}
+
+// updateModelAndCreateCommit updates a model and records the change as a commit. The store must be backed by a
+// transaction, which the caller commits.
+func (s *GormStore) updateModelAndCreateCommit(uploadedModel *apiTypes.CausalDecisionModel, oldModel *apiTypes.CausalDecisionModel, message string) (*apiTypes.CausalDecisionModel, int, error) {
+	// Update the model before creating the commit, as the commit is the diff to the updated model.
+	if status, err := updateModel(s.db, uploadedModel); err != nil {
 		return nil, status, err
 	}
 
-	status, changedModel, err := GetModelByUUID(uploadedModel.Meta.UUID)
+	status, changedModel, err := s.GetModelByUUID(uploadedModel.Meta.UUID)
 	if err != nil {
 		return nil, status, err
 	}
@@ -862,8 +1069,9 @@ func UpdateModelAndCreateCommit(uploadedModel *apiTypes.CausalDecisionModel, old
 
 	commit.Diff = string(jsonData)
 	commit.UserUUID = uploadedModel.Meta.Creator.UUID
+	commit.Message = message
 
-	status, parent, err := GetLatestCommitForModelUUID(uploadedModel.Meta.UUID)
+	status, parent, err := s.GetLatestCommitForModelUUID(uploadedModel.Meta.UUID)
 
 	//if there's no latest commit for this model, this must be the first.
 	if status == http.StatusNotFound {
@@ -877,17 +1085,17 @@ func UpdateModelAndCreateCommit(uploadedModel *apiTypes.CausalDecisionModel, old
 		commit.Version = parent.Version + 1
 	}
 	//finally, create the commit that we made.
-	if status, err := CreateCommit(&commit); err != nil {
-		return nil, status, err
+	if err := s.db.Create(&commit).Error; err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("could not create commit: %s", err.Error())
 	}
 	return changedModel, http.StatusOK, nil
 }
 
 // CreateCommit encapsulates the GORM functionality for creating a commit in a transaction
-func CreateCommit(uploadedCommit *apiTypes.Commit) (int, error) {
+func (s *GormStore) CreateCommit(uploadedCommit *apiTypes.Commit) (int, error) {
 
 	// Begin transaction.
-	transaction := dbInstance.Begin()
+	transaction := s.db.Begin()
 	if transaction.Error != nil {
 		return http.StatusInternalServerError, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
 	}
@@ -906,18 +1114,18 @@ func CreateCommit(uploadedCommit *apiTypes.Commit) (int, error) {
 	return http.StatusCreated, nil
 }
 
-func GetUserByEmail(email string) (int, *apiTypes.User, error) {
+func (s *GormStore) GetUserByEmail(email string) (int, *apiTypes.User, error) {
 	var user apiTypes.User
 
 	// Find the user record with the given ID.
-	if err := dbInstance.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
 		return http.StatusNotFound, nil, fmt.Errorf("user with email %s not found", email)
 	}
 
 	return http.StatusOK, &user, nil
 }
 
-func CreateUser(email string, password string) (*apiTypes.User, error) {
+func (s *GormStore) CreateUser(email string, password string) (*apiTypes.User, error) {
 	var newuser apiTypes.User
 	// if you have an int field marked as a primary key with autoIncrement in GORM and it is left as 0 (its zero value),
 	// GORM will interpret it as "not explicitly set" and will allow the database to generate an auto-incremented value for it
@@ -929,14 +1137,14 @@ func CreateUser(email string, password string) (*apiTypes.User, error) {
 	newuser.UUID = newuuid
 	// Ensure no other user with this email exists
 	var count int64
-	dbInstance.Model(&apiTypes.User{}).Where("email = ?", email).Count(&count)
+	s.db.Model(&apiTypes.User{}).Where("email = ?", email).Count(&count)
 	if count > 0 {
 		// If a user with the same email exists, return a conflict error.
 		return nil, fmt.Errorf("a user with email %s already exists", email)
 	}
 
 	// Begin transaction.
-	transaction := dbInstance.Begin()
+	transaction := s.db.Begin()
 	if transaction.Error != nil {
 		return nil, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
 	}
@@ -952,13 +1160,16 @@ func CreateUser(email string, password string) (*apiTypes.User, error) {
 }
 
 // if user doesn't exist, we create the user with the given email and password. TODO change this .
-func UserLogin(email string, password string) (int, *apiTypes.User, error) {
+func (s *GormStore) UserLogin(email string, password string, register bool) (int, *apiTypes.User, error) {
 
-	status, user, _ := GetUserByEmail(email)
+	status, user, _ := s.GetUserByEmail(email)
 
 	if status != 200 {
+		if !register {
+			return http.StatusUnauthorized, nil, fmt.Errorf("user does not exist")
+		}
 		//For now, let's just create a new user
-		newuser, err := CreateUser(email, password)
+		newuser, err := s.CreateUser(email, password)
 		if err != nil {
 			return http.StatusConflict, nil, fmt.Errorf("user does not exist and could not create new user")
 		}
@@ -974,8 +1185,42 @@ func UserLogin(email string, password string) (int, *apiTypes.User, error) {
 
 // / GetModelLineage returns the ancestry of a model given its UUID.
 // It retrieves the model and its ancestors in reverse order, starting from the most recent ancestor.
-func GetModelLineage(uuid string) (int, []apiTypes.CausalDecisionModel, error) {
-	status, modelPtr, err := GetModelByUUID(uuid)
+func (s *GormStore) GetModelLineage(uuid string) (int, []apiTypes.CausalDecisionModel, error) {
+	status, modelPtr, err := s.GetModelByUUID(uuid)
+
+	if err != nil {
+		return status, nil, err
+	}
+
+	model := *modelPtr
+
+	var lineage []apiTypes.CausalDecisionModel
+
+	for model.ParentUUID != "" {
+		_, parentPtr, err := s.GetModelByUUID(model.ParentUUID)
+
+		if err != nil {
+			break
+		}
+
+		parent := *parentPtr
+		lineage = append(lineage, parent)
+		model = parent
+	}
+
+	// Reverse the lineage so that the earliest ancestor is first.
+	for i, j := 0, len(lineage)-1; i < j; i, j = i+1, j-1 {
+		lineage[i], lineage[j] = lineage[j], lineage[i]
+	}
+
+	return http.StatusOK, lineage, nil
+}
+
+// GetModelLineageAsOf returns the ancestry of a model given its UUID as it was at the given time.
+// Both the model and each of its ancestors are reconstructed as they were at that time, so the
+// lineage follows the parent UUIDs the models had back then.
+func (s *GormStore) GetModelLineageAsOf(uuid string, asOf time.Time) (int, []apiTypes.CausalDecisionModel, error) {
+	status, modelPtr, err := s.GetModelByUUIDAsOf(uuid, asOf)
 
 	if err != nil {
 		return status, nil, err
@@ -986,7 +1231,7 @@ func GetModelLineage(uuid string) (int, []apiTypes.CausalDecisionModel, error) {
 	var lineage []apiTypes.CausalDecisionModel
 
 	for model.ParentUUID != "" {
-		_, parentPtr, err := GetModelByUUID(model.ParentUUID)
+		_, parentPtr, err := s.GetModelByUUIDAsOf(model.ParentUUID, asOf)
 
 		if err != nil {
 			break
@@ -1006,9 +1251,9 @@ func GetModelLineage(uuid string) (int, []apiTypes.CausalDecisionModel, error) {
 }
 
 // get the children of this model.
-func GetModelChildren(uuid string) (int, []apiTypes.CausalDecisionModel, error) {
+func (s *GormStore) GetModelChildren(uuid string) (int, []apiTypes.CausalDecisionModel, error) {
 	var children []apiTypes.CausalDecisionModel
-	if err := dbInstance.
+	if err := s.db.
 		Preload("Meta").
 		Preload("Diagrams").
 		Preload("Diagrams.Meta").
@@ -1032,13 +1277,60 @@ func GetModelChildren(uuid string) (int, []apiTypes.CausalDecisionModel, error)
 	return http.StatusOK, children, nil
 }
 
-func SearchModelsByName(name string) (int, []apiTypes.CausalDecisionModel, error) {
+// GetModelChildrenAsOf returns the children of this model as they were at the given time.
+// Children that did not exist yet, or that did not have this model as their parent at that time, are left out.
+func (s *GormStore) GetModelChildrenAsOf(uuid string, asOf time.Time) (int, []apiTypes.CausalDecisionModel, error) {
+	status, children, err := s.GetModelChildren(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	// A model whose parent never changed has the same parent as it had then, so the candidates are today's children
+	// and every model a commit has re-parented.
+	candidates := []string{}
+	for _, child := range children {
+		candidates = append(candidates, child.Meta.UUID)
+	}
+	var reparented []string
+	if err := s.db.Model(&apiTypes.Commit{}).
+		Distinct("cdm_uuid").
+		Where("diff LIKE ?", `%"path":"/parentUUID"%`).
+		Order("cdm_uuid").
+		Pluck("cdm_uuid", &reparented).Error; err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not find re-parented models: %s", err.Error())
+	}
+	candidates = append(candidates, reparented...)
+
+	childrenAsOf := []apiTypes.CausalDecisionModel{}
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		if seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+
+		// Models that did not exist yet are left out.
+		status, childAsOf, err := s.GetModelByUUIDAsOf(candidate, asOf)
+		if status == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return status, nil, err
+		}
+
+		if childAsOf.ParentUUID == uuid {
+			childrenAsOf = append(childrenAsOf, *childAsOf)
+		}
+	}
+
+	return http.StatusOK, childrenAsOf, nil
+}
+
+func (s *GormStore) SearchModelsByName(name string) (int, []apiTypes.CausalDecisionModel, error) {
 	var models []apiTypes.CausalDecisionModel
 
 	// Use GORM's query builder to work with Full-Text Search
-	if err := dbInstance.
-		Joins("JOIN meta ON causal_decision_models.meta_id = meta.id").
-		Where("MATCH(meta.name, meta.summary) AGAINST(? IN NATURAL LANGUAGE MODE)", name).
+	if err := s.matchName(s.db.Joins("JOIN meta ON causal_decision_models.meta_id = meta.id"), name).
 		Preload("Meta").
 		Preload("Diagrams").
 		Preload("Diagrams.Meta").
@@ -1055,13 +1347,12 @@ func SearchModelsByName(name string) (int, []apiTypes.CausalDecisionModel, error
 	return http.StatusOK, models, nil
 }
 
-func SearchModelsByUser(username string) (int, []apiTypes.CausalDecisionModel, error) {
+func (s *GormStore) SearchModelsByUser(username string) (int, []apiTypes.CausalDecisionModel, error) {
 	var models []apiTypes.CausalDecisionModel
 
-	if err := dbInstance.
+	if err := matchUsername(s.db.
 		Joins("JOIN meta ON causal_decision_models.meta_id = meta.id").
-		Joins("JOIN users ON meta.creator_id = users.id").
-		Where("users.username LIKE ?", "%"+username+"%").
+		Joins("JOIN users ON meta.creator_id = users.id"), username).
 		Preload("Meta").
 		Preload("Diagrams").
 		Preload("Diagrams.Meta").
@@ -1079,9 +1370,9 @@ func SearchModelsByUser(username string) (int, []apiTypes.CausalDecisionModel, e
 }
 
 // GetCommitsByModelUUID returns all commits for a model UUID, ordered by version
-func GetCommitsByModelUUID(uuid string) (int, []apiTypes.Commit, error) {
+func (s *GormStore) GetCommitsByModelUUID(uuid string) (int, []apiTypes.Commit, error) {
 	var commits []apiTypes.Commit
-	err := dbInstance.Where("cdm_uuid = ?", uuid).
+	err := s.db.Where("cdm_uuid = ?", uuid).
 		Order("version DESC").
 		Find(&commits).Error
 
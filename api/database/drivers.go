@@ -0,0 +1,80 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"opendi/model-hub/api/config"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// The drivers the database settings may name.
+const (
+	DriverMySQL    = config.DriverMySQL
+	DriverPostgres = config.DriverPostgres
+	DriverSQLite   = config.DriverSQLite
+	DriverMemory   = config.DriverMemory
+)
+
+// sqlitePragmas are set on every connection to a SQLite database. Foreign keys are enforced as they are by MySQL,
+// and writers wait for each other instead of failing while another connection holds the lock.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)"
+
+// dialectorFor returns the dialector for the database the settings describe, which must be valid.
+func dialectorFor(settings config.Database) (gorm.Dialector, error) {
+	switch settings.Driver {
+	case DriverMySQL:
+		return mysqlDialector(settings), nil
+	case DriverPostgres:
+		return postgresDialector(settings), nil
+	case DriverSQLite:
+		return sqlite.Open("file:" + settings.Path + "?" + sqlitePragmas + "&_pragma=journal_mode(WAL)"), nil
+	case DriverMemory:
+		return memoryDialector()
+	}
+	return nil, fmt.Errorf("database driver %q is not supported", settings.Driver)
+}
+
+// mysqlDialector constructs the Data Source Name (DSN) of the MySQL server the settings describe.
+func mysqlDialector(settings config.Database) gorm.Dialector {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", settings.Username, settings.Password,
+		net.JoinHostPort(settings.Hostname, strconv.Itoa(settings.Port)), settings.Name)
+	return mysql.Open(dsn)
+}
+
+// postgresDialector constructs the connection URL of the PostgreSQL server the settings describe. The sslmode of
+// the connection is left to the driver when the settings do not set it.
+func postgresDialector(settings config.Database) gorm.Dialector {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(settings.Username, settings.Password),
+		Host:   net.JoinHostPort(settings.Hostname, strconv.Itoa(settings.Port)),
+		Path:   "/" + settings.Name,
+	}
+	if settings.SSLMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {settings.SSLMode}}.Encode()
+	}
+	return postgres.Open(dsn.String())
+}
+
+// memoryDialector returns a dialector for a new, empty SQLite database that is kept in memory and lost when the
+// last connection to it closes. Every connection of the pool shares the database, through the memdb VFS.
+func memoryDialector() (gorm.Dialector, error) {
+	name := make([]byte, 8)
+	if _, err := rand.Read(name); err != nil {
+		return nil, err
+	}
+	return sqlite.Open("file:/modelhub-" + hex.EncodeToString(name) + "?vfs=memdb&" + sqlitePragmas), nil
+}
@@ -0,0 +1,140 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"fmt"
+	"net/http"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// summarizeModel reduces a model to the fields shown in a family graph.
+func summarizeModel(model apiTypes.CausalDecisionModel) apiTypes.ModelSummary {
+	return apiTypes.ModelSummary{
+		UUID:        model.Meta.UUID,
+		Name:        model.Meta.Name,
+		Summary:     model.Meta.Summary,
+		Version:     model.Meta.Version,
+		Draft:       model.Meta.Draft,
+		ParentUUID:  model.ParentUUID,
+		CreatedDate: model.Meta.CreatedDate,
+		UpdatedDate: model.Meta.UpdatedDate,
+	}
+}
+
+// getModelSummary loads a model with only its meta, without any of its diagrams.
+func (s *GormStore) getModelSummary(uuid string) (int, *apiTypes.CausalDecisionModel, error) {
+	var model apiTypes.CausalDecisionModel
+
+	if err := s.db.
+		InnerJoins("Meta", s.db.Where(&apiTypes.Meta{UUID: uuid})).
+		First(&model).Error; err != nil {
+		return http.StatusNotFound, nil, fmt.Errorf("model with uuid %s not found", uuid)
+	}
+
+	return http.StatusOK, &model, nil
+}
+
+// getChildrenOfModels loads the direct children of all of the given models in a single query,
+// grouped by the UUID of their parent.
+func (s *GormStore) getChildrenOfModels(uuids []string) (map[string][]apiTypes.CausalDecisionModel, error) {
+	var children []apiTypes.CausalDecisionModel
+
+	if err := s.db.
+		Joins("Meta").
+		Where("causal_decision_models.parent_uuid IN ?", uuids).
+		Order("causal_decision_models.id").
+		Find(&children).Error; err != nil {
+		return nil, err
+	}
+
+	byParent := map[string][]apiTypes.CausalDecisionModel{}
+	for _, child := range children {
+		byParent[child.ParentUUID] = append(byParent[child.ParentUUID], child)
+	}
+
+	return byParent, nil
+}
+
+// GetModelFamily returns the ancestors of a model, earliest first, and its descendants as a tree.
+// Descendants are loaded one generation at a time up to the given depth, and a depth below zero has no limit.
+// Every model is visited at most once, so parent UUIDs that form a cycle end the walk instead of looping forever.
+func (s *GormStore) GetModelFamily(uuid string, depth int) (int, *apiTypes.ModelFamily, error) {
+	status, model, err := s.getModelSummary(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	family := apiTypes.ModelFamily{
+		Model:       summarizeModel(*model),
+		Ancestors:   []apiTypes.ModelSummary{},
+		Descendants: []*apiTypes.FamilyNode{},
+	}
+
+	// Walk up the parents until we reach a root, a missing parent or a model we have already seen.
+	seen := map[string]bool{uuid: true}
+	for parentUUID := model.ParentUUID; parentUUID != ""; {
+		if seen[parentUUID] {
+			family.CycleDetected = true
+			break
+		}
+		seen[parentUUID] = true
+
+		_, parent, err := s.getModelSummary(parentUUID)
+		if err != nil {
+			break
+		}
+
+		family.Ancestors = append(family.Ancestors, summarizeModel(*parent))
+		parentUUID = parent.ParentUUID
+	}
+
+	// Reverse the ancestors so that the earliest ancestor is first.
+	for i, j := 0, len(family.Ancestors)-1; i < j; i, j = i+1, j-1 {
+		family.Ancestors[i], family.Ancestors[j] = family.Ancestors[j], family.Ancestors[i]
+	}
+
+	// Walk down the descendants breadth first, loading each generation with one query.
+	visited := map[string]bool{uuid: true}
+	nodes := map[string]*apiTypes.FamilyNode{}
+	generation := []string{uuid}
+	for level := 0; len(generation) > 0; level++ {
+		byParent, err := s.getChildrenOfModels(generation)
+		if err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+
+		if depth >= 0 && level >= depth {
+			if len(byParent) > 0 {
+				family.Truncated = true
+			}
+			break
+		}
+
+		var next []string
+		for _, parentUUID := range generation {
+			for _, child := range byParent[parentUUID] {
+				if visited[child.Meta.UUID] {
+					family.CycleDetected = true
+					continue
+				}
+				visited[child.Meta.UUID] = true
+
+				node := &apiTypes.FamilyNode{ModelSummary: summarizeModel(child)}
+				nodes[child.Meta.UUID] = node
+				if parent, ok := nodes[parentUUID]; ok {
+					parent.Children = append(parent.Children, node)
+				} else {
+					family.Descendants = append(family.Descendants, node)
+				}
+				next = append(next, child.Meta.UUID)
+			}
+		}
+		generation = next
+	}
+
+	return http.StatusOK, &family, nil
+}
@@ -0,0 +1,273 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"opendi/model-hub/api/apiTypes"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// generateUniqueMetaUUID keeps generating UUIDs until one is found that no meta in the database uses.
+func (s *GormStore) generateUniqueMetaUUID() (string, error) {
+	var count int64
+	for {
+		uuid, err := generateUUID()
+		if err != nil {
+			return "", fmt.Errorf("could not generate UUID: %s", err.Error())
+		}
+
+		// Ensure no other meta with the same UUID exists.
+		s.db.Model(&apiTypes.Meta{}).Where("uuid = ?", uuid).Count(&count)
+		if count == 0 {
+			return uuid, nil
+		}
+	}
+}
+
+// NewMetaUUID returns a random UUID that no meta in the database uses yet, for components built outside of the database package.
+func (s *GormStore) NewMetaUUID() (string, error) {
+	return s.generateUniqueMetaUUID()
+}
+
+// copyMeta returns a copy of a meta with a new UUID and no database IDs, so it is created as a new record.
+// The creator and updaters are kept, and will be matched to the existing users by email.
+func (s *GormStore) copyMeta(meta apiTypes.Meta, uuids map[string]string) (apiTypes.Meta, error) {
+	newUUID, err := s.generateUniqueMetaUUID()
+	if err != nil {
+		return meta, err
+	}
+	if meta.UUID != "" {
+		uuids[meta.UUID] = newUUID
+	}
+
+	meta.ID = 0
+	meta.UUID = newUUID
+	meta.CreatedAt = time.Time{}
+	meta.UpdatedAt = time.Time{}
+	meta.Updaters = append([]apiTypes.User{}, meta.Updaters...)
+	return meta, nil
+}
+
+// remapUUIDs replaces every string in a raw JSON value that is one of the remapped UUIDs.
+func remapUUIDs(raw json.RawMessage, uuids map[string]string) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	var remap func(value any) any
+	remap = func(value any) any {
+		switch v := value.(type) {
+		case string:
+			if newUUID, ok := uuids[v]; ok {
+				return newUUID
+			}
+		case []any:
+			for i := range v {
+				v[i] = remap(v[i])
+			}
+		case map[string]any:
+			for key := range v {
+				v[key] = remap(v[key])
+			}
+		}
+		return value
+	}
+
+	return json.Marshal(remap(value))
+}
+
+// deepCopyModel copies a model with new UUIDs for the model and all its diagrams, elements and dependencies.
+// Dependency sources and targets, and associated evaluation elements, are remapped to the new element UUIDs.
+// Also returns the map from the old UUIDs to the new UUIDs.
+func (s *GormStore) deepCopyModel(model apiTypes.CausalDecisionModel) (*apiTypes.CausalDecisionModel, map[string]string, error) {
+	uuids := map[string]string{}
+
+	fork := apiTypes.CausalDecisionModel{
+		Schema:     model.Schema,
+		ParentUUID: model.ParentUUID,
+		Diagrams:   []apiTypes.Diagram{},
+	}
+
+	var err error
+	if fork.Meta, err = s.copyMeta(model.Meta, uuids); err != nil {
+		return nil, nil, err
+	}
+
+	// First give every component a new UUID, so references between them can be remapped afterwards.
+	for _, diagram := range model.Diagrams {
+		newDiagram := apiTypes.Diagram{
+			Addons:       diagram.Addons,
+			Elements:     []apiTypes.DiaElement{},
+			Dependencies: []apiTypes.CausalDependency{},
+		}
+		if newDiagram.Meta, err = s.copyMeta(diagram.Meta, uuids); err != nil {
+			return nil, nil, err
+		}
+
+		for _, element := range diagram.Elements {
+			newElement := apiTypes.DiaElement{
+				CausalType:         element.CausalType,
+				DiagramType:        element.DiagramType,
+				Content:            element.Content,
+				AssociatedElements: element.AssociatedElements,
+			}
+			if newElement.Meta, err = s.copyMeta(element.Meta, uuids); err != nil {
+				return nil, nil, err
+			}
+			newDiagram.Elements = append(newDiagram.Elements, newElement)
+		}
+
+		for _, dependency := range diagram.Dependencies {
+			newDependency := apiTypes.CausalDependency{
+				Source: dependency.Source,
+				Target: dependency.Target,
+			}
+			if newDependency.Meta, err = s.copyMeta(dependency.Meta, uuids); err != nil {
+				return nil, nil, err
+			}
+			newDiagram.Dependencies = append(newDiagram.Dependencies, newDependency)
+		}
+
+		fork.Diagrams = append(fork.Diagrams, newDiagram)
+	}
+
+	// Remap the references to the old UUIDs.
+	for i := range fork.Diagrams {
+		diagram := &fork.Diagrams[i]
+		for j := range diagram.Elements {
+			element := &diagram.Elements[j]
+			if element.AssociatedElements, err = remapUUIDs(element.AssociatedElements, uuids); err != nil {
+				return nil, nil, fmt.Errorf("could not remap associated elements of element %s: %s", element.Meta.UUID, err.Error())
+			}
+		}
+		for j := range diagram.Dependencies {
+			dependency := &diagram.Dependencies[j]
+			if newUUID, ok := uuids[dependency.Source]; ok {
+				dependency.Source = newUUID
+			}
+			if newUUID, ok := uuids[dependency.Target]; ok {
+				dependency.Target = newUUID
+			}
+		}
+	}
+
+	return &fork, uuids, nil
+}
+
+// createForkMappings records which component of the parent each component of a fork was copied from.
+func createForkMappings(tx *gorm.DB, forkUUID string, uuids map[string]string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+
+	var mappings []apiTypes.ForkMapping
+	for parentComponentUUID, componentUUID := range uuids {
+		mappings = append(mappings, apiTypes.ForkMapping{
+			CDMUUID:             forkUUID,
+			ParentComponentUUID: parentComponentUUID,
+			ComponentUUID:       componentUUID,
+		})
+	}
+
+	if err := tx.Create(&mappings).Error; err != nil {
+		return fmt.Errorf("could not record fork mappings: %s", err.Error())
+	}
+	return nil
+}
+
+// getForkMappings returns the map from the UUIDs of components of the parent to the UUIDs of the components of the fork copied from them.
+func (s *GormStore) getForkMappings(forkUUID string) (map[string]string, error) {
+	var mappings []apiTypes.ForkMapping
+	if err := s.db.Where("cdm_uuid = ?", forkUUID).Find(&mappings).Error; err != nil {
+		return nil, err
+	}
+
+	uuids := map[string]string{}
+	for _, mapping := range mappings {
+		uuids[mapping.ParentComponentUUID] = mapping.ComponentUUID
+	}
+	return uuids, nil
+}
+
+// ForkModel deep copies the given version of a model into a new child model.
+// The fork has new UUIDs for itself and all its diagrams, elements and dependencies, has the forked
+// model as its parent, and records the version of the parent it was forked from.
+// If creatorEmail is empty the fork keeps the creator of the parent, otherwise the user with that email is the creator.
+// If name is not empty, the fork is given that name.
+func (s *GormStore) ForkModel(uuid string, version *int, creatorEmail string, name string) (int, *apiTypes.CausalDecisionModel, error) {
+	// Resolve the version to fork, defaulting to the latest.
+	forkedFromVersion := 0
+	if version != nil {
+		forkedFromVersion = *version
+	} else {
+		status, commit, err := s.GetLatestCommitForModelUUID(uuid)
+		if status == http.StatusOK {
+			forkedFromVersion = commit.Version
+		} else if status != http.StatusNotFound {
+			return status, nil, err
+		}
+	}
+
+	status, parent, err := s.GetVersionOfModel(uuid, forkedFromVersion)
+	if err != nil {
+		return status, nil, err
+	}
+
+	fork, uuids, err := s.deepCopyModel(*parent)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	syncedAtVersion := 0
+	fork.ParentUUID = uuid
+	fork.ForkedFromVersion = &forkedFromVersion
+	fork.SyncedAtVersion = &syncedAtVersion
+	if name != "" {
+		fork.Meta.Name = name
+	}
+
+	if creatorEmail != "" {
+		status, user, _ := s.GetUserByEmail(creatorEmail)
+		if status != http.StatusOK {
+			return http.StatusConflict, nil, fmt.Errorf("could not find creator: %s", creatorEmail)
+		}
+		fork.Meta.Creator = *user
+		fork.Meta.CreatorID = user.ID
+	}
+
+	// Create the fork and its mappings together, so a fork is never left without them.
+	transaction := s.db.Begin()
+	if transaction.Error != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
+	}
+
+	if err := insertModel(transaction, fork); err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, err
+	}
+
+	// Remember which component of the parent each component of the fork was copied from, so changes can be synced later.
+	delete(uuids, parent.Meta.UUID)
+	if err := createForkMappings(transaction, fork.Meta.UUID, uuids); err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, err
+	}
+
+	// Commit the transaction; error out if commit fails.
+	if err := transaction.Commit().Error; err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return s.GetModelByUUID(fork.Meta.UUID)
+}
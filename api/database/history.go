@@ -0,0 +1,640 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"opendi/model-hub/api/apiTypes"
+	jsonDiffHelpers "opendi/model-hub/api/jsondiffhelpers"
+	"time"
+
+	"github.com/wI2L/jsondiff"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// getModelHistory reconstructs every version of the model with the given UUID.
+// It returns the states of the model indexed by version (states[0] is the model as it was uploaded)
+// along with the commits that produced them, ordered by version (commits[i] produced states[i+1]).
+// Only one pass over the commit diffs is made, starting from the latest version and going backwards.
+func (s *GormStore) getModelHistory(uuid string) (int, []apiTypes.CausalDecisionModel, []apiTypes.Commit, error) {
+	status, latestVersionOfModel, err := s.GetModelByUUID(uuid)
+	if err != nil {
+		return status, nil, nil, err
+	}
+
+	status, commits, err := s.GetCommitsByModelUUID(uuid)
+	if status == http.StatusNotFound {
+		// No commits, so the model as it was uploaded is the only version.
+		return http.StatusOK, []apiTypes.CausalDecisionModel{*latestVersionOfModel}, []apiTypes.Commit{}, nil
+	}
+	if err != nil {
+		return status, nil, nil, err
+	}
+
+	// GetCommitsByModelUUID orders the commits by version descending, which is the order we apply them in.
+	states := make([]apiTypes.CausalDecisionModel, len(commits)+1)
+	states[len(commits)] = *latestVersionOfModel
+
+	currModelBytes, err := json.Marshal(latestVersionOfModel)
+	if err != nil {
+		return http.StatusInternalServerError, nil, nil, err
+	}
+
+	for i, commit := range commits {
+		if commit.Version != len(commits)-i {
+			return http.StatusInternalServerError, nil, nil, fmt.Errorf("commit history for model with uuid %s is missing version %d", uuid, len(commits)-i)
+		}
+
+		modified, err := jsonDiffHelpers.ApplyInvertedPatch(currModelBytes, []byte(commit.Diff))
+		if err != nil {
+			return http.StatusInternalServerError, nil, nil, err
+		}
+		currModelBytes = modified
+
+		if err := json.Unmarshal(currModelBytes, &states[commit.Version-1]); err != nil {
+			return http.StatusInternalServerError, nil, nil, err
+		}
+	}
+
+	// Reverse the commits so that the first commit is first.
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+
+	return http.StatusOK, states, commits, nil
+}
+
+// usernameCache maps user UUIDs to usernames, so each user is only looked up once.
+type usernameCache struct {
+	store     *GormStore
+	usernames map[string]string
+}
+
+func newUsernameCache(store *GormStore) usernameCache {
+	return usernameCache{store: store, usernames: map[string]string{}}
+}
+
+// lookup returns the username of the user with the given UUID, or an empty string if there is no such user.
+func (cache usernameCache) lookup(uuid string) string {
+	username, ok := cache.usernames[uuid]
+	if !ok {
+		if _, user, err := cache.store.GetUserByUUID(uuid); err == nil {
+			username = user.Username
+		}
+		cache.usernames[uuid] = username
+	}
+	return username
+}
+
+// blameComponent is a component of a model in a single version, along with the JSON used to decide if it changed.
+type blameComponent struct {
+	componentType string
+	name          string
+	diagramUUID   string
+	fingerprint   string
+}
+
+// flattenModelComponents returns the model, diagrams, elements and dependencies of a model keyed by their meta UUID.
+// The model and its diagrams are fingerprinted with only the UUIDs of their children, so that a change to an
+// element is only attributed to the element, while adding or removing an element is also attributed to its diagram.
+func flattenModelComponents(model apiTypes.CausalDecisionModel) (map[string]blameComponent, []string) {
+	components := map[string]blameComponent{}
+	var order []string
+
+	add := func(uuid string, component blameComponent, value any, children []string) {
+		if uuid == "" {
+			return
+		}
+		if _, ok := components[uuid]; !ok {
+			order = append(order, uuid)
+		}
+		fingerprint, _ := json.Marshal([]any{value, children})
+		component.fingerprint = string(fingerprint)
+		components[uuid] = component
+	}
+
+	modelOnly := model
+	modelOnly.Diagrams = nil
+	var diagramUUIDs []string
+	for _, diagram := range model.Diagrams {
+		diagramUUIDs = append(diagramUUIDs, diagram.Meta.UUID)
+	}
+	add(model.Meta.UUID, blameComponent{componentType: apiTypes.BlameTypeModel, name: model.Meta.Name}, modelOnly, diagramUUIDs)
+
+	for _, diagram := range model.Diagrams {
+		diagramOnly := diagram
+		diagramOnly.Elements = nil
+		diagramOnly.Dependencies = nil
+		var childUUIDs []string
+		for _, element := range diagram.Elements {
+			childUUIDs = append(childUUIDs, element.Meta.UUID)
+		}
+		for _, dependency := range diagram.Dependencies {
+			childUUIDs = append(childUUIDs, dependency.Meta.UUID)
+		}
+		add(diagram.Meta.UUID, blameComponent{componentType: apiTypes.BlameTypeDiagram, name: diagram.Meta.Name}, diagramOnly, childUUIDs)
+
+		for _, element := range diagram.Elements {
+			add(element.Meta.UUID, blameComponent{componentType: apiTypes.BlameTypeElement, name: element.Meta.Name, diagramUUID: diagram.Meta.UUID}, element, nil)
+		}
+		for _, dependency := range diagram.Dependencies {
+			add(dependency.Meta.UUID, blameComponent{componentType: apiTypes.BlameTypeDependency, name: dependency.Meta.Name, diagramUUID: diagram.Meta.UUID}, dependency, nil)
+		}
+	}
+
+	return components, order
+}
+
+// GetModelBlame walks the commit history of the model with the given UUID and attributes each
+// of its current components (by meta UUID) to the commit that introduced it and the commit that last changed it.
+// Components that were part of the model when it was uploaded are attributed to version 0 and the model's creator.
+func (s *GormStore) GetModelBlame(uuid string) (int, *apiTypes.ModelBlame, error) {
+	status, states, commits, err := s.getModelHistory(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	// Work out who made each version, looking each user up only once.
+	usernames := newUsernameCache(s)
+	entryForVersion := func(version int) apiTypes.BlameEntry {
+		if version == 0 {
+			// Reconstructed versions don't carry database timestamps, so the upload time comes from the stored model.
+			creator := states[0].Meta.Creator
+			return apiTypes.BlameEntry{
+				Version:  0,
+				UserUUID: creator.UUID,
+				Username: creator.Username,
+				Date:     states[len(states)-1].CreatedAt,
+			}
+		}
+
+		commit := commits[version-1]
+		return apiTypes.BlameEntry{
+			Version:  version,
+			UserUUID: commit.UserUUID,
+			Username: usernames.lookup(commit.UserUUID),
+			Date:     commit.CreatedAt,
+		}
+	}
+
+	introduced := map[string]int{}
+	lastChanged := map[string]int{}
+	var previous map[string]blameComponent
+	var current map[string]blameComponent
+	var order []string
+
+	for version, state := range states {
+		current, order = flattenModelComponents(state)
+		for componentUUID, component := range current {
+			before, existed := previous[componentUUID]
+			if !existed {
+				// Either the component is new, or it was removed and added back.
+				introduced[componentUUID] = version
+				lastChanged[componentUUID] = version
+			} else if before.fingerprint != component.fingerprint {
+				lastChanged[componentUUID] = version
+			}
+		}
+		previous = current
+	}
+
+	blame := apiTypes.ModelBlame{
+		CDMUUID:    uuid,
+		Version:    len(states) - 1,
+		Components: []apiTypes.ComponentBlame{},
+	}
+
+	// current holds the components of the latest version, in the order they appear in the model.
+	for _, componentUUID := range order {
+		component := current[componentUUID]
+		blame.Components = append(blame.Components, apiTypes.ComponentBlame{
+			UUID:        componentUUID,
+			Name:        component.name,
+			Type:        component.componentType,
+			DiagramUUID: component.diagramUUID,
+			Introduced:  entryForVersion(introduced[componentUUID]),
+			LastChanged: entryForVersion(lastChanged[componentUUID]),
+		})
+	}
+
+	return http.StatusOK, &blame, nil
+}
+
+// diffModelComponents compares two versions of a model component by component, returning the
+// components that were added, removed and changed between them.
+func diffModelComponents(before apiTypes.CausalDecisionModel, after apiTypes.CausalDecisionModel) ([]apiTypes.ComponentChange, []apiTypes.ComponentChange, []apiTypes.ComponentChange) {
+	beforeComponents, beforeOrder := flattenModelComponents(before)
+	afterComponents, afterOrder := flattenModelComponents(after)
+
+	change := func(uuid string, component blameComponent) apiTypes.ComponentChange {
+		return apiTypes.ComponentChange{
+			UUID:        uuid,
+			Name:        component.name,
+			Type:        component.componentType,
+			DiagramUUID: component.diagramUUID,
+		}
+	}
+
+	added := []apiTypes.ComponentChange{}
+	removed := []apiTypes.ComponentChange{}
+	changed := []apiTypes.ComponentChange{}
+
+	for _, uuid := range afterOrder {
+		component := afterComponents[uuid]
+		previous, existed := beforeComponents[uuid]
+		if !existed {
+			added = append(added, change(uuid, component))
+		} else if previous.fingerprint != component.fingerprint {
+			changed = append(changed, change(uuid, component))
+		}
+	}
+	for _, uuid := range beforeOrder {
+		if _, exists := afterComponents[uuid]; !exists {
+			removed = append(removed, change(uuid, beforeComponents[uuid]))
+		}
+	}
+
+	return added, removed, changed
+}
+
+// GetModelChangelog describes the changes made to a model between two versions.
+// There is an entry for each commit after fromVersion up to and including toVersion, along with the net
+// components added, removed and changed across the whole range.
+func (s *GormStore) GetModelChangelog(uuid string, fromVersion int, toVersion int) (int, *apiTypes.Changelog, error) {
+	if fromVersion < 0 || toVersion < fromVersion {
+		return http.StatusBadRequest, nil, fmt.Errorf("invalid range of versions: %d to %d", fromVersion, toVersion)
+	}
+
+	status, states, commits, err := s.getModelHistory(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+	if toVersion > len(commits) {
+		return http.StatusConflict, nil, fmt.Errorf("Version requested is greater than the latest version")
+	}
+
+	usernames := newUsernameCache(s)
+	changelog := apiTypes.Changelog{
+		CDMUUID:     uuid,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Entries:     []apiTypes.ChangelogEntry{},
+	}
+
+	for version := fromVersion + 1; version <= toVersion; version++ {
+		commit := commits[version-1]
+		added, removed, changed := diffModelComponents(states[version-1], states[version])
+		changelog.Entries = append(changelog.Entries, apiTypes.ChangelogEntry{
+			Version:  version,
+			Message:  commit.Message,
+			Tag:      commit.Tag,
+			UserUUID: commit.UserUUID,
+			Username: usernames.lookup(commit.UserUUID),
+			Date:     commit.CreatedAt,
+			Added:    added,
+			Removed:  removed,
+			Changed:  changed,
+		})
+	}
+
+	changelog.Added, changelog.Removed, changelog.Changed = diffModelComponents(states[fromVersion], states[toVersion])
+
+	return http.StatusOK, &changelog, nil
+}
+
+// TagCommit attaches a tag to the commit that produced the given version of a model.
+// Tags are unique within a model, and tagged versions are preserved when history is squashed or compacted.
+func (s *GormStore) TagCommit(uuid string, version int, tag string) (int, *apiTypes.Commit, error) {
+	if tag == "" {
+		return http.StatusBadRequest, nil, fmt.Errorf("tag must not be empty")
+	}
+
+	var commit apiTypes.Commit
+	if err := s.db.Where("cdm_uuid = ? AND version = ?", uuid, version).First(&commit).Error; err != nil {
+		return http.StatusNotFound, nil, fmt.Errorf("no commit found for version %d of model with UUID %s", version, uuid)
+	}
+
+	// Ensure no other commit of this model has the same tag.
+	var count int64
+	s.db.Model(&apiTypes.Commit{}).Where("cdm_uuid = ? AND tag = ? AND id <> ?", uuid, tag, commit.ID).Count(&count)
+	if count > 0 {
+		return http.StatusConflict, nil, fmt.Errorf("model with UUID %s already has a version tagged %s", uuid, tag)
+	}
+
+	commit.Tag = tag
+	if err := s.db.Save(&commit).Error; err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not tag commit: %s", err.Error())
+	}
+
+	return http.StatusOK, &commit, nil
+}
+
+// protectedVersions returns the versions of a model that squashing and compacting must keep, with the reason each is kept:
+// tagged versions, the versions its forks were forked or last synced from, and its version when it was last synced
+// with its parent. Forks compare against these versions, so they must still be reconstructable.
+func (s *GormStore) protectedVersions(uuid string, commits []apiTypes.Commit) (map[int]string, error) {
+	protected := map[int]string{}
+	for _, commit := range commits {
+		if commit.Tag != "" {
+			protected[commit.Version] = "tagged " + commit.Tag
+		}
+	}
+
+	var forks []apiTypes.CausalDecisionModel
+	if err := s.db.Where("parent_uuid = ? AND forked_from_version IS NOT NULL", uuid).Find(&forks).Error; err != nil {
+		return nil, err
+	}
+	for _, fork := range forks {
+		if _, ok := protected[*fork.ForkedFromVersion]; !ok {
+			protected[*fork.ForkedFromVersion] = "the fork point of a fork"
+		}
+	}
+
+	_, model, err := s.GetModelByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+	if model.SyncedAtVersion != nil {
+		if _, ok := protected[*model.SyncedAtVersion]; !ok {
+			protected[*model.SyncedAtVersion] = "the version last synced with the parent"
+		}
+	}
+
+	return protected, nil
+}
+
+// lockModel locks the row of the model with the given UUID until the transaction ends, so that updates to the model
+// wait for its history to be rewritten instead of being renumbered or lost.
+func lockModel(tx *gorm.DB, uuid string) (int, error) {
+	var model apiTypes.CausalDecisionModel
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("meta_id = (?)", tx.Model(&apiTypes.Meta{}).Select("id").Where("uuid = ?", uuid)).
+		First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound, fmt.Errorf("model with UUID %s not found", uuid)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("could not lock model: %s", err.Error())
+	}
+	return http.StatusOK, nil
+}
+
+// SquashCommits replaces the commits that produced versions from through to of a model with a single commit.
+// The diff of the new commit is recomputed from the state of the model before version from to the state at version to,
+// and the commits after the range are renumbered so the versions of the model stay contiguous.
+// The squashed commit keeps the author, time and tag of the last commit in the range. Intermediate protected
+// versions, such as tagged versions and fork points, can't be squashed away, since their state would be lost.
+// Fork points after the range are renumbered along with the commits.
+// Returns the number of commits removed from the history.
+func (s *GormStore) SquashCommits(uuid string, from int, to int) (int, int, error) {
+	if from < 1 || to <= from {
+		return http.StatusBadRequest, 0, fmt.Errorf("invalid range of versions to squash: %d to %d", from, to)
+	}
+
+	// Begin transaction. The history is read in it, with the model locked, so it can't change before it is rewritten.
+	transaction := s.db.Begin()
+	if transaction.Error != nil {
+		return http.StatusInternalServerError, 0, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
+	}
+
+	if status, err := lockModel(transaction, uuid); err != nil {
+		transaction.Rollback()
+		return status, 0, err
+	}
+
+	store := NewGormStore(transaction)
+	status, states, commits, err := store.getModelHistory(uuid)
+	if err != nil {
+		transaction.Rollback()
+		return status, 0, err
+	}
+	if to > len(commits) {
+		transaction.Rollback()
+		return http.StatusConflict, 0, fmt.Errorf("Version requested is greater than the latest version")
+	}
+
+	protected, err := store.protectedVersions(uuid, commits)
+	if err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, 0, err
+	}
+
+	_, _, removed, status, err := store.squash(uuid, states, commits, protected, from, to)
+	if err != nil {
+		transaction.Rollback()
+		return status, 0, err
+	}
+
+	// Commit the transaction; error out if commit fails.
+	if err := transaction.Commit().Error; err != nil {
+		return http.StatusInternalServerError, 0, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return http.StatusOK, removed, nil
+}
+
+// squash replaces the commits that produced versions from through to of a model with a single commit, in the
+// transaction of the store. It takes the history of the model as getModelHistory reconstructs it and returns the
+// history as it is after the squash, so several ranges can be squashed with a single reconstruction, latest first.
+// Returns the number of commits removed from the history.
+func (s *GormStore) squash(uuid string, states []apiTypes.CausalDecisionModel, commits []apiTypes.Commit, protected map[int]string, from int, to int) ([]apiTypes.CausalDecisionModel, []apiTypes.Commit, int, int, error) {
+	for version := from; version < to; version++ {
+		if reason, ok := protected[version]; ok {
+			return nil, nil, 0, http.StatusConflict, fmt.Errorf("version %d is %s and can't be squashed", version, reason)
+		}
+	}
+
+	beforeBytes, err := json.Marshal(states[from-1])
+	if err != nil {
+		return nil, nil, 0, http.StatusInternalServerError, err
+	}
+	afterBytes, err := json.Marshal(states[to])
+	if err != nil {
+		return nil, nil, 0, http.StatusInternalServerError, err
+	}
+
+	//NOTE the RFC 6902 spec for JSON diffs  will not work with any JSON keys that are of value "-"
+	diff, err := jsondiff.CompareJSON(beforeBytes, afterBytes, jsondiff.Invertible())
+	if err != nil {
+		return nil, nil, 0, http.StatusInternalServerError, err
+	}
+
+	first := commits[from-1]
+	last := commits[to-1]
+	var squashedIDs []int
+	for _, commit := range commits[from-1 : to] {
+		squashedIDs = append(squashedIDs, commit.ID)
+	}
+
+	if err := s.db.Delete(&apiTypes.Commit{}, squashedIDs).Error; err != nil {
+		return nil, nil, 0, http.StatusInternalServerError, fmt.Errorf("could not delete squashed commits: %s", err.Error())
+	}
+
+	// If the changes in the range cancel each other out, the commits are simply dropped,
+	// unless the last one is protected, since its version must still be there.
+	_, lastProtected := protected[to]
+	removed := to - from + 1
+	newParentCommitID := first.ParentCommitID
+	var kept []apiTypes.Commit
+	if diff.String() != "" || lastProtected {
+		if diff == nil {
+			diff = jsondiff.Patch{}
+		}
+
+		// Marshal the diff to JSON, so we can convert it to a string to store in the database.
+		jsonData, err := json.Marshal(diff)
+		if err != nil {
+			return nil, nil, 0, http.StatusInternalServerError, err
+		}
+
+		squashed := apiTypes.Commit{
+			ParentCommitID: first.ParentCommitID,
+			Diff:           string(jsonData),
+			UserUUID:       last.UserUUID,
+			CDMUUID:        uuid,
+			CreatedAt:      last.CreatedAt,
+			Version:        from,
+			Tag:            last.Tag,
+		}
+		if err := s.db.Create(&squashed).Error; err != nil {
+			return nil, nil, 0, http.StatusInternalServerError, fmt.Errorf("could not create squashed commit: %s", err.Error())
+		}
+
+		removed--
+		newParentCommitID = fmt.Sprintf("%d", squashed.ID)
+		kept = append(kept, squashed)
+	}
+
+	// Point the commit after the range at the squashed commit and renumber the commits after it.
+	if to < len(commits) {
+		if err := s.db.Model(&apiTypes.Commit{}).Where("id = ?", commits[to].ID).Update("parent_commit_id", newParentCommitID).Error; err != nil {
+			return nil, nil, 0, http.StatusInternalServerError, fmt.Errorf("could not update parent commit: %s", err.Error())
+		}
+	}
+	if err := s.db.Model(&apiTypes.Commit{}).Where("cdm_uuid = ? AND version > ?", uuid, to).Update("version", gorm.Expr("version - ?", removed)).Error; err != nil {
+		return nil, nil, 0, http.StatusInternalServerError, fmt.Errorf("could not renumber commits: %s", err.Error())
+	}
+
+	// Renumber the fork points that refer to versions of the model from the end of the range on.
+	if err := s.db.Model(&apiTypes.CausalDecisionModel{}).Where("parent_uuid = ? AND forked_from_version >= ?", uuid, to).Update("forked_from_version", gorm.Expr("forked_from_version - ?", removed)).Error; err != nil {
+		return nil, nil, 0, http.StatusInternalServerError, fmt.Errorf("could not renumber fork points: %s", err.Error())
+	}
+	if err := s.db.Model(&apiTypes.CausalDecisionModel{}).Where("id = ? AND synced_at_version >= ?", states[len(commits)].ID, to).Update("synced_at_version", gorm.Expr("synced_at_version - ?", removed)).Error; err != nil {
+		return nil, nil, 0, http.StatusInternalServerError, fmt.Errorf("could not renumber fork points: %s", err.Error())
+	}
+
+	// Rewrite the history the same way: the squashed commit, if any, produces the state at the end of the range.
+	after := append([]apiTypes.Commit{}, commits[to:]...)
+	if len(after) > 0 {
+		after[0].ParentCommitID = newParentCommitID
+	}
+	for i := range after {
+		after[i].Version -= removed
+	}
+	commits = append(append(append([]apiTypes.Commit{}, commits[:from-1]...), kept...), after...)
+	states = append(append([]apiTypes.CausalDecisionModel{}, states[:from]...), states[to+1-len(kept):]...)
+
+	return states, commits, removed, http.StatusOK, nil
+}
+
+// CompactModelHistory squashes the commits of a model made before the given time into snapshots.
+// The old commits are split into runs ending at each protected version, and each run is squashed into
+// a single commit, so every tagged version, fork point and the latest old version can still be reconstructed.
+// Returns the number of commits removed from the history.
+func (s *GormStore) CompactModelHistory(uuid string, olderThan time.Time) (int, int, error) {
+	// Begin transaction. The history is read once in it, with the model locked, and every run is squashed in it.
+	transaction := s.db.Begin()
+	if transaction.Error != nil {
+		return http.StatusInternalServerError, 0, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
+	}
+
+	if status, err := lockModel(transaction, uuid); err != nil {
+		transaction.Rollback()
+		return status, 0, err
+	}
+
+	store := NewGormStore(transaction)
+	status, states, commits, err := store.getModelHistory(uuid)
+	if err != nil {
+		transaction.Rollback()
+		return status, 0, err
+	}
+
+	protected, err := store.protectedVersions(uuid, commits)
+	if err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, 0, err
+	}
+
+	type versionRange struct{ from, to int }
+	var runs []versionRange
+	start, lastOld := 0, 0
+	for _, commit := range commits {
+		if !commit.CreatedAt.Before(olderThan) {
+			break
+		}
+		lastOld = commit.Version
+		if start == 0 {
+			start = commit.Version
+		}
+		if _, ok := protected[commit.Version]; ok {
+			runs = append(runs, versionRange{start, commit.Version})
+			start = 0
+		}
+	}
+	if start != 0 {
+		// The latest old commit ends the final run.
+		runs = append(runs, versionRange{start, lastOld})
+	}
+
+	// Squash from the latest run backwards so the versions of the earlier runs don't shift.
+	removed := 0
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].to <= runs[i].from {
+			continue
+		}
+		var removedInRun int
+		states, commits, removedInRun, status, err = store.squash(uuid, states, commits, protected, runs[i].from, runs[i].to)
+		if err != nil {
+			transaction.Rollback()
+			return status, 0, err
+		}
+		removed += removedInRun
+	}
+
+	// Commit the transaction; error out if commit fails.
+	if err := transaction.Commit().Error; err != nil {
+		return http.StatusInternalServerError, 0, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return http.StatusOK, removed, nil
+}
+
+// CompactAllModelHistory applies CompactModelHistory to every model with commits made before the given time.
+// This is the retention policy for model history.
+func (s *GormStore) CompactAllModelHistory(olderThan time.Time) (int, []apiTypes.CompactionResult, error) {
+	var uuids []string
+	if err := s.db.Model(&apiTypes.Commit{}).
+		Where("created_at < ?", olderThan).
+		Distinct().
+		Pluck("cdm_uuid", &uuids).Error; err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	results := []apiTypes.CompactionResult{}
+	for _, uuid := range uuids {
+		status, removed, err := s.CompactModelHistory(uuid, olderThan)
+		if err != nil {
+			return status, results, fmt.Errorf("could not compact history of model with UUID %s: %s", uuid, err.Error())
+		}
+		results = append(results, apiTypes.CompactionResult{CDMUUID: uuid, RemovedCommits: removed})
+	}
+
+	return http.StatusOK, results, nil
+}
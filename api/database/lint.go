@@ -0,0 +1,56 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"net/http"
+
+	"opendi/model-hub/api/apiTypes"
+
+	"gorm.io/gorm"
+)
+
+// GetLintConfig returns the lint rule severities an organization has overridden, keyed by rule ID.
+func (s *GormStore) GetLintConfig(organization string) (int, map[string]string, error) {
+	var configs []apiTypes.LintRuleConfig
+	if err := s.db.Where("organization = ?", organization).Find(&configs).Error; err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	config := map[string]string{}
+	for _, ruleConfig := range configs {
+		config[ruleConfig.Rule] = ruleConfig.Severity
+	}
+
+	return http.StatusOK, config, nil
+}
+
+// SetLintConfig replaces the lint rule severities an organization has overridden.
+// Rules left out of the new configuration go back to their default severity.
+func (s *GormStore) SetLintConfig(organization string, config map[string]string) (int, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization = ?", organization).Delete(&apiTypes.LintRuleConfig{}).Error; err != nil {
+			return err
+		}
+
+		for rule, severity := range config {
+			ruleConfig := apiTypes.LintRuleConfig{
+				Organization: organization,
+				Rule:         rule,
+				Severity:     severity,
+			}
+			if err := tx.Create(&ruleConfig).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
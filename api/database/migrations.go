@@ -0,0 +1,270 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"fmt"
+	"opendi/model-hub/api/database/schema"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a step of the database schema. Applying it moves the schema from the previous version to Version,
+// and reverting it moves the schema back.
+type Migration struct {
+	Version int
+	Name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// schemaMigration records a migration that has been applied to the database. The version of the schema is the
+// highest version recorded.
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migrations are the steps of the schema, in the order they are applied. A migration that has been released must
+// never change, as databases have already been migrated with it: schema changes need a new migration at the end.
+// Tables are created from the frozen structs of the schema package, never from apiTypes.
+//
+// Migrations that create tables leave the tables that already exist alone, so databases created before migrations
+// were introduced are adopted by migrating them up: migration 5 adds the columns their tables lack.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create models, users and commits",
+		up: func(tx *gorm.DB) error {
+			return createTables(tx, &schema.User{}, &schema.Meta{}, &schema.CausalDecisionModel{}, &schema.Diagram{},
+				&schema.DiaElement{}, &schema.CausalDependency{}, &schema.Commit{})
+		},
+		down: func(tx *gorm.DB) error {
+			return dropTables(tx, &schema.User{}, &schema.Meta{}, &schema.CausalDecisionModel{}, &schema.Diagram{},
+				&schema.DiaElement{}, &schema.CausalDependency{}, &schema.Commit{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create fork mappings",
+		up: func(tx *gorm.DB) error {
+			return createTables(tx, &schema.ForkMapping{})
+		},
+		down: func(tx *gorm.DB) error {
+			return dropTables(tx, &schema.ForkMapping{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create lint rule configs",
+		up: func(tx *gorm.DB) error {
+			return createTables(tx, &schema.LintRuleConfig{})
+		},
+		down: func(tx *gorm.DB) error {
+			return dropTables(tx, &schema.LintRuleConfig{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create search index",
+		up: func(tx *gorm.DB) error {
+			return NewGormStore(tx).createSearchIndex()
+		},
+		down: func(tx *gorm.DB) error {
+			return NewGormStore(tx).dropSearchIndex()
+		},
+	},
+	{
+		Version: 5,
+		Name:    "adopt tables created before migrations",
+		up: func(tx *gorm.DB) error {
+			return addMissingColumns(tx, adoptedColumns)
+		},
+		down: func(tx *gorm.DB) error {
+			// The columns belong to the tables of migration 1, which drops them when it is reverted.
+			return nil
+		},
+	},
+}
+
+// column names a column of the table of a schema struct by the name of its field.
+type column struct {
+	model any
+	field string
+}
+
+// adoptedColumns are the columns of the tables of migration 1 that the tables created before migrations were
+// introduced don't have. Their indexes are the same, so adopting them adds no index.
+var adoptedColumns = []column{
+	{&schema.CausalDecisionModel{}, "ForkedFromVersion"},
+	{&schema.CausalDecisionModel{}, "SyncedAtVersion"},
+	{&schema.Commit{}, "Tag"},
+	{&schema.Commit{}, "Message"},
+}
+
+// addMissingColumns adds each of the given columns that its table doesn't have yet.
+func addMissingColumns(tx *gorm.DB, columns []column) error {
+	for _, c := range columns {
+		if tx.Migrator().HasColumn(c.model, c.field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(c.model, c.field); err != nil {
+			return fmt.Errorf("could not add column %s: %s", c.field, err.Error())
+		}
+	}
+	return nil
+}
+
+// Migrations returns the steps of the schema, in the order they are applied.
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+// LatestSchemaVersion returns the version of the schema this build of the hub expects.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// withJoinTables returns the given models followed by the join tables of their many to many associations.
+func withJoinTables(tx *gorm.DB, models ...any) ([]any, error) {
+	tables := append([]any(nil), models...)
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		for _, relationship := range stmt.Schema.Relationships.Many2Many {
+			tables = append(tables, reflect.New(relationship.JoinTable.ModelType).Interface())
+		}
+	}
+	return tables, nil
+}
+
+// createTables creates the tables of the given models and their join tables. Tables that exist are left as they
+// are, so migrating never alters the columns of a table that holds data: changing a table is left to a migration
+// of its own.
+func createTables(tx *gorm.DB, models ...any) error {
+	tables, err := withJoinTables(tx, models...)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if tx.Migrator().HasTable(table) {
+			continue
+		}
+		if err := tx.Migrator().CreateTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropTables drops the tables of the given models and their join tables.
+func dropTables(tx *gorm.DB, models ...any) error {
+	tables, err := withJoinTables(tx, models...)
+	if err != nil {
+		return err
+	}
+	// Join tables reference the models, so they go first.
+	for i := len(tables) - 1; i >= len(models); i-- {
+		if err := tx.Migrator().DropTable(tables[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Migrator().DropTable(models...)
+}
+
+// SchemaVersion returns the version of the schema of the database, 0 if it has never been migrated.
+func (s *GormStore) SchemaVersion() (int, error) {
+	if !s.db.Migrator().HasTable(&schemaMigration{}) {
+		return 0, nil
+	}
+	var version *int
+	if err := s.db.Model(&schemaMigration{}).Select("MAX(version)").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("could not read schema version: %s", err.Error())
+	}
+	if version == nil {
+		return 0, nil
+	}
+	return *version, nil
+}
+
+// Migrate applies or reverts migrations until the schema is at the target version, 0 reverting all of them.
+// Every migration runs in its own transaction with the record of it, although MySQL commits schema changes as
+// they are made. Returns the migrations that were applied or reverted, in the order they ran.
+func (s *GormStore) Migrate(target int) ([]Migration, error) {
+	if target < 0 || target > LatestSchemaVersion() {
+		return nil, fmt.Errorf("schema version %d does not exist, expected 0 to %d", target, LatestSchemaVersion())
+	}
+	if !s.db.Migrator().HasTable(&schemaMigration{}) {
+		if err := s.db.Migrator().CreateTable(&schemaMigration{}); err != nil {
+			return nil, fmt.Errorf("could not create schema version table: %s", err.Error())
+		}
+	}
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > LatestSchemaVersion() {
+		return nil, fmt.Errorf("schema version %d is newer than version %d of this build", version, LatestSchemaVersion())
+	}
+
+	var ran []Migration
+	for _, migration := range migrations {
+		if migration.Version <= version || migration.Version > target {
+			continue
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("could not apply migration %d (%s): %s", migration.Version, migration.Name, err.Error())
+		}
+		ran = append(ran, migration)
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version > version || migration.Version <= target {
+			continue
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("could not revert migration %d (%s): %s", migration.Version, migration.Name, err.Error())
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// CheckSchema returns an error unless the schema of the database is at the version this build expects, so the hub
+// never runs against a database that has not been migrated, or that a newer build has migrated.
+func (s *GormStore) CheckSchema() error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	switch latest := LatestSchemaVersion(); {
+	case version < latest:
+		return fmt.Errorf("database schema is at version %d, expected %d: run the migrate command to migrate it", version, latest)
+	case version > latest:
+		return fmt.Errorf("database schema is at version %d, which is newer than version %d of this build", version, latest)
+	}
+	return nil
+}
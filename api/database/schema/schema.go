@@ -0,0 +1,122 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package schema freezes the tables the migrations create, as they were when each migration was released.
+// Migrations must not build tables from the apiTypes structs, which change with the API: a migration applied to a
+// new database would then create tables no released migration ever did.
+//
+// The structs keep the names of the apiTypes structs they were copied from, so GORM derives the same table, column,
+// join table and constraint names. They must never change: a migration that changes a table makes the change itself,
+// such as adding a column through the migrator.
+package schema
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Tables created by migration 1.
+
+type CausalDecisionModel struct {
+	ID                int `gorm:"primaryKey"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Schema            string
+	MetaID            int
+	Meta              Meta
+	ParentUUID        string
+	ParentID          *int
+	Parent            *CausalDecisionModel
+	Diagrams          []Diagram `gorm:"many2many:cdm_diagrams"`
+	ForkedFromVersion *int
+	SyncedAtVersion   *int
+}
+
+type Meta struct {
+	ID            int `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UUID          string `gorm:"unique"`
+	Name          string
+	Summary       string
+	Documentation json.RawMessage
+	Version       string
+	Draft         bool
+	CreatorID     int
+	Creator       User
+	CreatedDate   string
+	Updaters      []User `gorm:"many2many:meta_updaters"`
+	UpdatedDate   string
+}
+
+type Diagram struct {
+	ID           int `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	MetaID       int
+	Meta         Meta
+	Elements     []DiaElement       `gorm:"many2many:diagram_elements"`
+	Dependencies []CausalDependency `gorm:"many2many:diagram_dependencies"`
+	Addons       json.RawMessage
+}
+
+type DiaElement struct {
+	ID                 int `gorm:"primaryKey"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	MetaID             int
+	Meta               Meta
+	CausalType         string
+	DiagramType        string
+	Content            json.RawMessage
+	AssociatedElements json.RawMessage
+}
+
+type CausalDependency struct {
+	ID        int `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	MetaID    int
+	Meta      Meta
+	Source    string
+	Target    string
+}
+
+type User struct {
+	ID       int `gorm:"primaryKey"`
+	UUID     string
+	Username string
+	Email    string `gorm:"unique"`
+	Password string
+}
+
+type Commit struct {
+	ID             int `gorm:"primaryKey"`
+	ParentCommitID string
+	Diff           string
+	UserUUID       string
+	CDMUUID        string
+	CreatedAt      time.Time
+	Version        int
+	Tag            string
+	Message        string
+}
+
+// Tables created by migration 2.
+
+type ForkMapping struct {
+	ID                  int    `gorm:"primaryKey"`
+	CDMUUID             string `gorm:"index"`
+	ParentComponentUUID string
+	ComponentUUID       string
+}
+
+// Tables created by migration 3.
+
+type LintRuleConfig struct {
+	ID           int    `gorm:"primaryKey"`
+	Organization string `gorm:"index"`
+	Rule         string
+	Severity     string
+}
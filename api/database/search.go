@@ -0,0 +1,94 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"opendi/model-hub/api/apiTypes"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// searchIndex is the full-text index over the name and summary of models.
+const searchIndex = "idx_name_summary"
+
+// searchDocument is the PostgreSQL text search document of a meta. It uses the simple configuration, which like
+// MySQL full-text search matches words as they are written, without stemming.
+const searchDocument = "to_tsvector('simple', coalesce(meta.name, '') || ' ' || coalesce(meta.summary, ''))"
+
+// likeEscaper escapes the wildcards of LIKE patterns, with the escape character the patterns declare.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// createSearchIndex creates the full-text index SearchModelsByName uses, on databases that support one.
+func (s *GormStore) createSearchIndex() error {
+	switch s.db.Dialector.Name() {
+	case DriverMySQL:
+		if s.db.Migrator().HasIndex(&apiTypes.Meta{}, searchIndex) {
+			return nil
+		}
+		return s.db.Exec("CREATE FULLTEXT INDEX " + searchIndex + " ON meta (name, summary)").Error
+	case DriverPostgres:
+		return s.db.Exec("CREATE INDEX IF NOT EXISTS " + searchIndex + " ON meta USING GIN (" + searchDocument + ")").Error
+	}
+	return nil
+}
+
+// dropSearchIndex drops the full-text index createSearchIndex creates.
+func (s *GormStore) dropSearchIndex() error {
+	switch s.db.Dialector.Name() {
+	case DriverMySQL:
+		if !s.db.Migrator().HasIndex(&apiTypes.Meta{}, searchIndex) {
+			return nil
+		}
+		return s.db.Exec("DROP INDEX " + searchIndex + " ON meta").Error
+	case DriverPostgres:
+		return s.db.Exec("DROP INDEX IF EXISTS " + searchIndex).Error
+	}
+	return nil
+}
+
+// matchName restricts a query joined with meta to the models whose name or summary matches any word of the search
+// text, most relevant first. MySQL and PostgreSQL match words with their full-text index. Other databases match
+// the models whose name or summary contains any word of the text, ignoring case.
+func (s *GormStore) matchName(query *gorm.DB, name string) *gorm.DB {
+	if s.db.Dialector.Name() == DriverMySQL {
+		return query.Where("MATCH(meta.name, meta.summary) AGAINST(? IN NATURAL LANGUAGE MODE)", name)
+	}
+
+	terms := strings.Fields(strings.ToLower(name))
+	if len(terms) == 0 {
+		return query.Where("1 = 0")
+	}
+
+	if s.db.Dialector.Name() == DriverPostgres {
+		// Or together a query for each word, as plainto_tsquery alone would need all of them.
+		queries := make([]string, len(terms))
+		args := make([]any, len(terms))
+		for i, term := range terms {
+			queries[i] = "plainto_tsquery('simple', ?)"
+			args[i] = term
+		}
+		tsquery := "(" + strings.Join(queries, " || ") + ")"
+		return query.
+			Where(searchDocument+" @@ "+tsquery, args...).
+			Order(clause.OrderBy{Expression: clause.Expr{SQL: "ts_rank(" + searchDocument + ", " + tsquery + ") DESC", Vars: args}})
+	}
+
+	conditions := make([]string, len(terms))
+	args := make([]any, 0, 2*len(terms))
+	for i, term := range terms {
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		conditions[i] = "LOWER(meta.name) LIKE ? ESCAPE '!' OR LOWER(meta.summary) LIKE ? ESCAPE '!'"
+		args = append(args, pattern, pattern)
+	}
+	return query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// matchUsername restricts a query joined with users to the models whose creator's username contains the given text,
+// ignoring case.
+func matchUsername(query *gorm.DB, username string) *gorm.DB {
+	return query.Where("LOWER(users.username) LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(strings.ToLower(username))+"%")
+}
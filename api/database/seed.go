@@ -0,0 +1,31 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"opendi/model-hub/api/apiTypes"
+)
+
+// SeedModel creates a fixture model unless a model with its UUID already exists, so seeding can be repeated.
+// Unlike CreateModelGivenEmail, the model keeps its UUID, and its creator and updaters are created if they don't exist.
+// Returns whether the model was created.
+func (s *GormStore) SeedModel(model *apiTypes.CausalDecisionModel) (int, bool, error) {
+	if model.Meta.UUID == "" {
+		return http.StatusBadRequest, false, fmt.Errorf("fixture has no uuid")
+	}
+
+	var count int64
+	if err := s.db.Model(&apiTypes.Meta{}).Where("uuid = ?", model.Meta.UUID).Count(&count).Error; err != nil {
+		return http.StatusInternalServerError, false, err
+	}
+	if count > 0 {
+		return http.StatusOK, false, nil
+	}
+
+	status, err := s.CreateModel(model)
+	return status, err == nil, err
+}
@@ -0,0 +1,99 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"opendi/model-hub/api/apiTypes"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ModelStore keeps models and their history. Like the rest of the package, its methods return the HTTP status
+// that describes their outcome along with any error.
+type ModelStore interface {
+	GetAllModels() (int, []apiTypes.CausalDecisionModel, error)
+	GetModelByUUID(uuid string) (int, *apiTypes.CausalDecisionModel, error)
+	GetModelByUUIDAsOf(uuid string, asOf time.Time) (int, *apiTypes.CausalDecisionModel, error)
+	GetVersionOfModel(uuid string, version int) (int, *apiTypes.CausalDecisionModel, error)
+	SearchModelsByName(name string) (int, []apiTypes.CausalDecisionModel, error)
+	SearchModelsByUser(username string) (int, []apiTypes.CausalDecisionModel, error)
+
+	// NewMetaUUID returns a UUID no model or component has yet.
+	NewMetaUUID() (string, error)
+	CreateModelGivenEmail(uploadedModel *apiTypes.CausalDecisionModel) (int, error)
+	CreateModelsInBulk(models []apiTypes.CausalDecisionModel, results []apiTypes.BulkImportResult, options BulkImportOptions)
+	UpdateModelAndCreateCommitWithMessage(uploadedModel *apiTypes.CausalDecisionModel, oldModel *apiTypes.CausalDecisionModel, message string) (*apiTypes.CausalDecisionModel, int, error)
+	SeedModel(model *apiTypes.CausalDecisionModel) (int, bool, error)
+
+	GetModelLineage(uuid string) (int, []apiTypes.CausalDecisionModel, error)
+	GetModelLineageAsOf(uuid string, asOf time.Time) (int, []apiTypes.CausalDecisionModel, error)
+	GetModelChildren(uuid string) (int, []apiTypes.CausalDecisionModel, error)
+	GetModelChildrenAsOf(uuid string, asOf time.Time) (int, []apiTypes.CausalDecisionModel, error)
+	GetModelFamily(uuid string, depth int) (int, *apiTypes.ModelFamily, error)
+	ForkModel(uuid string, version *int, creatorEmail string, name string) (int, *apiTypes.CausalDecisionModel, error)
+	GetUpstreamStatus(uuid string) (int, *apiTypes.UpstreamStatus, error)
+	SyncWithParent(uuid string) (int, *apiTypes.SyncResult, error)
+
+	GetModelBlame(uuid string) (int, *apiTypes.ModelBlame, error)
+	GetModelChangelog(uuid string, fromVersion int, toVersion int) (int, *apiTypes.Changelog, error)
+	ExportModelArchive(uuid string) (int, *apiTypes.ModelArchive, error)
+	ImportModelArchive(archive *apiTypes.ModelArchive) (int, *apiTypes.CausalDecisionModel, error)
+}
+
+// CommitStore keeps the commits that record how models changed.
+type CommitStore interface {
+	GetAllCommits() (int, []apiTypes.Commit, error)
+	GetLatestCommitForModelUUID(uuid string) (int, *apiTypes.Commit, error)
+	GetCommitsByModelUUID(uuid string) (int, []apiTypes.Commit, error)
+	TagCommit(uuid string, version int, tag string) (int, *apiTypes.Commit, error)
+	SquashCommits(uuid string, from int, to int) (int, int, error)
+	CompactModelHistory(uuid string, olderThan time.Time) (int, int, error)
+	CompactAllModelHistory(olderThan time.Time) (int, []apiTypes.CompactionResult, error)
+}
+
+// UserStore keeps the users of the hub.
+type UserStore interface {
+	// UserLogin checks the password of a user, first creating the user if there is none with the email and
+	// register is true.
+	UserLogin(email string, password string, register bool) (int, *apiTypes.User, error)
+}
+
+// LintStore keeps the lint rule configuration of each organization.
+type LintStore interface {
+	GetLintConfig(organization string) (int, map[string]string, error)
+	SetLintConfig(organization string, config map[string]string) (int, error)
+}
+
+// GormStore keeps everything in a database through GORM. It implements every store interface of the package.
+type GormStore struct {
+	db *gorm.DB
+}
+
+var (
+	_ ModelStore  = (*GormStore)(nil)
+	_ CommitStore = (*GormStore)(nil)
+	_ UserStore   = (*GormStore)(nil)
+	_ LintStore   = (*GormStore)(nil)
+)
+
+// NewGormStore returns a store backed by the given database, which must already have the expected tables.
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// DB returns the database the store is backed by.
+func (s *GormStore) DB() *gorm.DB {
+	return s.db
+}
+
+// Close closes the connection to the database.
+func (s *GormStore) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
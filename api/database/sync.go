@@ -0,0 +1,399 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"opendi/model-hub/api/apiTypes"
+
+	"gorm.io/gorm"
+)
+
+// GetUpstreamStatus compares a forked model with its parent, counting the commits each has made since the fork point.
+// The fork point moves forward every time the fork is synced with its parent.
+func (s *GormStore) GetUpstreamStatus(uuid string) (int, *apiTypes.UpstreamStatus, error) {
+	status, model, err := s.GetModelByUUID(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+	if model.ParentUUID == "" {
+		return http.StatusBadRequest, nil, fmt.Errorf("model with uuid %s has no parent", uuid)
+	}
+	if model.ForkedFromVersion == nil {
+		return http.StatusBadRequest, nil, fmt.Errorf("model with uuid %s was not forked from its parent, so it has no fork point", uuid)
+	}
+
+	parentVersion, err := s.latestVersion(model.ParentUUID)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	modelVersion, err := s.latestVersion(uuid)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	syncedAtVersion := 0
+	if model.SyncedAtVersion != nil {
+		syncedAtVersion = *model.SyncedAtVersion
+	}
+
+	return http.StatusOK, &apiTypes.UpstreamStatus{
+		CDMUUID:           uuid,
+		ParentUUID:        model.ParentUUID,
+		ForkedFromVersion: *model.ForkedFromVersion,
+		ParentVersion:     parentVersion,
+		Ahead:             modelVersion - syncedAtVersion,
+		Behind:            parentVersion - *model.ForkedFromVersion,
+	}, nil
+}
+
+// latestVersion returns the latest commit version of a model, or 0 if it has no commits.
+func (s *GormStore) latestVersion(uuid string) (int, error) {
+	status, commit, err := s.GetLatestCommitForModelUUID(uuid)
+	if status == http.StatusNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return commit.Version, nil
+}
+
+// syncItem is a diagram, element or dependency of a model, along with the JSON used to decide if it changed.
+// Diagrams are stored without their elements and dependencies, which are separate items.
+type syncItem struct {
+	componentType string
+	diagramUUID   string
+	fingerprint   string
+	diagram       apiTypes.Diagram
+	element       apiTypes.DiaElement
+	dependency    apiTypes.CausalDependency
+}
+
+// flattenDiagrams returns the diagrams, elements and dependencies of a model keyed by their meta UUID, in the order they appear.
+func flattenDiagrams(diagrams []apiTypes.Diagram) (map[string]syncItem, []string) {
+	items := map[string]syncItem{}
+	var order []string
+
+	add := func(uuid string, item syncItem, value any) {
+		if _, ok := items[uuid]; !ok {
+			order = append(order, uuid)
+		}
+		fingerprint, _ := json.Marshal([]any{item.diagramUUID, value})
+		item.fingerprint = string(fingerprint)
+		items[uuid] = item
+	}
+
+	for _, diagram := range diagrams {
+		diagramOnly := diagram
+		diagramOnly.Elements = nil
+		diagramOnly.Dependencies = nil
+		add(diagram.Meta.UUID, syncItem{componentType: apiTypes.BlameTypeDiagram, diagram: diagramOnly}, diagramOnly)
+
+		for _, element := range diagram.Elements {
+			add(element.Meta.UUID, syncItem{componentType: apiTypes.BlameTypeElement, diagramUUID: diagram.Meta.UUID, element: element}, element)
+		}
+		for _, dependency := range diagram.Dependencies {
+			add(dependency.Meta.UUID, syncItem{componentType: apiTypes.BlameTypeDependency, diagramUUID: diagram.Meta.UUID, dependency: dependency}, dependency)
+		}
+	}
+
+	return items, order
+}
+
+// remapDiagrams replaces the UUIDs in a list of diagrams using the given map, including dependency sources and targets.
+func remapDiagrams(diagrams []apiTypes.Diagram, uuids map[string]string) ([]apiTypes.Diagram, error) {
+	diagramBytes, err := json.Marshal(diagrams)
+	if err != nil {
+		return nil, err
+	}
+	remapped, err := remapUUIDs(diagramBytes, uuids)
+	if err != nil {
+		return nil, err
+	}
+	var result []apiTypes.Diagram
+	if err := json.Unmarshal(remapped, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// mergeDiagrams performs a three way merge of the diagrams of a fork with the changes made to its parent.
+// All three versions must use the UUIDs of the parent. A component changed only by the parent takes the parent's
+// version, a component changed only by the fork keeps the fork's version, and a component changed differently by
+// both is a conflict that keeps the fork's version.
+func mergeDiagrams(base []apiTypes.Diagram, theirs []apiTypes.Diagram, ours []apiTypes.Diagram) ([]apiTypes.Diagram, []apiTypes.ComponentChange, []apiTypes.SyncConflict) {
+	baseItems, _ := flattenDiagrams(base)
+	theirItems, theirOrder := flattenDiagrams(theirs)
+	ourItems, ourOrder := flattenDiagrams(ours)
+
+	applied := []apiTypes.ComponentChange{}
+	conflicts := []apiTypes.SyncConflict{}
+
+	// Decide the merged version of every component, in the order of the fork followed by new components of the parent.
+	merged := map[string]syncItem{}
+	var order []string
+	seen := map[string]bool{}
+	for _, uuid := range append(append([]string{}, ourOrder...), theirOrder...) {
+		if seen[uuid] {
+			continue
+		}
+		seen[uuid] = true
+
+		baseItem, inBase := baseItems[uuid]
+		theirItem, inTheirs := theirItems[uuid]
+		ourItem, inOurs := ourItems[uuid]
+
+		parentChanged := inBase != inTheirs || baseItem.fingerprint != theirItem.fingerprint
+		forkChanged := inBase != inOurs || baseItem.fingerprint != ourItem.fingerprint
+		sameChange := inTheirs == inOurs && theirItem.fingerprint == ourItem.fingerprint
+
+		switch {
+		case !parentChanged || sameChange:
+			if inOurs {
+				merged[uuid] = ourItem
+				order = append(order, uuid)
+			}
+		case !forkChanged:
+			// Take the parent's change, which may add, change or remove the component.
+			item := theirItem
+			if !inTheirs {
+				item = baseItem
+			}
+			applied = append(applied, apiTypes.ComponentChange{UUID: uuid, Name: itemName(item), Type: item.componentType, DiagramUUID: item.diagramUUID})
+			if inTheirs {
+				merged[uuid] = theirItem
+				order = append(order, uuid)
+			}
+		default:
+			item := ourItem
+			if !inOurs {
+				item = theirItem
+			}
+			reason := "changed in both the model and its parent"
+			if !inOurs {
+				reason = "removed from the model but changed in its parent"
+			} else if !inTheirs {
+				reason = "changed in the model but removed from its parent"
+			}
+			conflicts = append(conflicts, apiTypes.SyncConflict{ParentComponentUUID: uuid, Type: item.componentType, Reason: reason})
+			if inOurs {
+				merged[uuid] = ourItem
+				order = append(order, uuid)
+			}
+		}
+	}
+
+	// Rebuild the diagrams from the merged components.
+	var diagrams []apiTypes.Diagram
+	diagramIndex := map[string]int{}
+	for _, uuid := range order {
+		if item := merged[uuid]; item.componentType == apiTypes.BlameTypeDiagram {
+			diagram := item.diagram
+			diagram.Elements = []apiTypes.DiaElement{}
+			diagram.Dependencies = []apiTypes.CausalDependency{}
+			diagramIndex[uuid] = len(diagrams)
+			diagrams = append(diagrams, diagram)
+		}
+	}
+	for _, uuid := range order {
+		item := merged[uuid]
+		if item.componentType == apiTypes.BlameTypeDiagram {
+			continue
+		}
+		i, ok := diagramIndex[item.diagramUUID]
+		if !ok {
+			conflicts = append(conflicts, apiTypes.SyncConflict{ParentComponentUUID: uuid, Type: item.componentType, Reason: "its diagram was removed from the model"})
+			continue
+		}
+		if item.componentType == apiTypes.BlameTypeElement {
+			diagrams[i].Elements = append(diagrams[i].Elements, item.element)
+		} else {
+			diagrams[i].Dependencies = append(diagrams[i].Dependencies, item.dependency)
+		}
+	}
+
+	return diagrams, applied, conflicts
+}
+
+// itemName returns the name of the component held by a sync item.
+func itemName(item syncItem) string {
+	switch item.componentType {
+	case apiTypes.BlameTypeDiagram:
+		return item.diagram.Meta.Name
+	case apiTypes.BlameTypeElement:
+		return item.element.Meta.Name
+	default:
+		return item.dependency.Meta.Name
+	}
+}
+
+// saveDiagramContents creates or updates a diagram along with its elements and dependencies,
+// replacing the elements and dependencies associated with it.
+// UpdateModel leaves the contents of existing diagrams alone, so syncing uses this to change them.
+func saveDiagramContents(tx *gorm.DB, diagram *apiTypes.Diagram) error {
+	if err := matchUUIDsToID(tx, diagram); err != nil {
+		return err
+	}
+
+	if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(diagram).Error; err != nil {
+		return fmt.Errorf("could not save diagram %s: %s", diagram.Meta.UUID, err.Error())
+	}
+	if err := tx.Model(diagram).Association("Elements").Replace(diagram.Elements); err != nil {
+		return fmt.Errorf("could not replace elements of diagram %s: %s", diagram.Meta.UUID, err.Error())
+	}
+	if err := tx.Model(diagram).Association("Dependencies").Replace(diagram.Dependencies); err != nil {
+		return fmt.Errorf("could not replace dependencies of diagram %s: %s", diagram.Meta.UUID, err.Error())
+	}
+	return nil
+}
+
+// SyncWithParent pulls the commits made to the parent of a fork since the fork point into the fork.
+// Changes to diagrams, elements and dependencies that the fork has not also changed are applied, and
+// components changed by both are reported as conflicts by their UUID in the fork and left as they are in the fork.
+// The fork point is moved to the latest version of the parent, and the changes are recorded as a commit on the fork.
+func (s *GormStore) SyncWithParent(uuid string) (int, *apiTypes.SyncResult, error) {
+	status, upstream, err := s.GetUpstreamStatus(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	result := apiTypes.SyncResult{
+		FromParentVersion: upstream.ForkedFromVersion,
+		ToParentVersion:   upstream.ParentVersion,
+		Applied:           []apiTypes.ComponentChange{},
+		Conflicts:         []apiTypes.SyncConflict{},
+	}
+	if upstream.Behind <= 0 {
+		// Already up to date with the parent.
+		return http.StatusOK, &result, nil
+	}
+
+	status, base, err := s.GetVersionOfModel(upstream.ParentUUID, upstream.ForkedFromVersion)
+	if err != nil {
+		return status, nil, err
+	}
+	status, theirs, err := s.GetModelByUUID(upstream.ParentUUID)
+	if err != nil {
+		return status, nil, err
+	}
+	status, ours, err := s.GetModelByUUID(uuid)
+	if err != nil {
+		return status, nil, err
+	}
+
+	// Translate the fork into the UUIDs of the parent so the three versions can be compared.
+	// Components without a mapping were not copied from the parent, and keep their UUIDs.
+	parentToFork, err := s.getForkMappings(uuid)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	forkToParent := map[string]string{}
+	for parentUUID, forkUUID := range parentToFork {
+		forkToParent[forkUUID] = parentUUID
+	}
+	oursInParentSpace, err := remapDiagrams(ours.Diagrams, forkToParent)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	mergedDiagrams, applied, conflicts := mergeDiagrams(base.Diagrams, theirs.Diagrams, oursInParentSpace)
+
+	// Components new to the fork get new UUIDs, which are recorded as mappings.
+	ourItems, _ := flattenDiagrams(oursInParentSpace)
+	_, mergedOrder := flattenDiagrams(mergedDiagrams)
+	newMappings := map[string]string{}
+	for _, parentUUID := range mergedOrder {
+		if _, inOurs := ourItems[parentUUID]; inOurs {
+			continue
+		}
+		forkUUID, err := s.generateUniqueMetaUUID()
+		if err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+		parentToFork[parentUUID] = forkUUID
+		newMappings[parentUUID] = forkUUID
+	}
+
+	mergedDiagrams, err = remapDiagrams(mergedDiagrams, parentToFork)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	// Report the applied changes and conflicts with the UUIDs of the fork.
+	for i := range applied {
+		if forkUUID, ok := parentToFork[applied[i].UUID]; ok {
+			applied[i].UUID = forkUUID
+		}
+		if forkUUID, ok := parentToFork[applied[i].DiagramUUID]; ok {
+			applied[i].DiagramUUID = forkUUID
+		}
+	}
+	for i := range conflicts {
+		conflicts[i].UUID = conflicts[i].ParentComponentUUID
+		if forkUUID, ok := parentToFork[conflicts[i].ParentComponentUUID]; ok {
+			conflicts[i].UUID = forkUUID
+		}
+	}
+	result.Applied = applied
+	result.Conflicts = conflicts
+
+	// Save the merged diagrams, the new mappings, the moved fork point and the commit recording the sync together,
+	// so a failed sync leaves the fork as it was.
+	transaction := s.db.Begin()
+	if transaction.Error != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not begin transaction: %s", transaction.Error.Error())
+	}
+	store := NewGormStore(transaction)
+
+	// Save the contents of the merged diagrams, since UpdateModel doesn't change existing diagrams.
+	for i := range mergedDiagrams {
+		if err := saveDiagramContents(transaction, &mergedDiagrams[i]); err != nil {
+			transaction.Rollback()
+			return http.StatusInternalServerError, nil, err
+		}
+	}
+	if err := createForkMappings(transaction, uuid, newMappings); err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, err
+	}
+
+	// Move the fork point and record the sync as a commit on the fork.
+	modelVersion, err := store.latestVersion(uuid)
+	if err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, err
+	}
+	syncedAtVersion := modelVersion + 1
+	forkedFromVersion := upstream.ParentVersion
+
+	updatedModel := *ours
+	updatedModel.Diagrams = mergedDiagrams
+
+	// The update keeps the fork point and sync version stored for the model, so move them first.
+	if err := transaction.Model(&apiTypes.CausalDecisionModel{}).
+		Where("meta_id = ?", ours.Meta.ID).
+		Updates(map[string]any{"forked_from_version": forkedFromVersion, "synced_at_version": syncedAtVersion}).Error; err != nil {
+		transaction.Rollback()
+		return http.StatusInternalServerError, nil, err
+	}
+
+	message := fmt.Sprintf("Sync with version %d of parent %s", upstream.ParentVersion, upstream.ParentUUID)
+	changedModel, status, err := store.updateModelAndCreateCommit(&updatedModel, ours, message)
+	if err != nil {
+		transaction.Rollback()
+		return status, nil, err
+	}
+
+	// Commit the transaction; error out if commit fails.
+	if err := transaction.Commit().Error; err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	result.Model = changedModel
+	return http.StatusOK, &result, nil
+}
@@ -0,0 +1,68 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package dmn converts between DMN 1.x decision requirements graphs and causal decision models.
+//
+// Each DRG element becomes an element of a single diagram, with its DMN kind kept as its diagram type:
+// decisions are levers, business knowledge models are intermediates, and input data and knowledge sources are externals.
+// Information, knowledge and authority requirements become dependencies from the required element to the one requiring it.
+// The DMN id and, if the file has DMNDI, the position of each element are kept in its content.
+package dmn
+
+import (
+	"strings"
+)
+
+// DMN kinds of DRG elements, used as the diagram type of the elements they are imported as.
+const (
+	KindDecision               = "decision"
+	KindInputData              = "inputData"
+	KindKnowledgeSource        = "knowledgeSource"
+	KindBusinessKnowledgeModel = "businessKnowledgeModel"
+)
+
+// DMN kinds of requirements, used as the name of the dependencies they are imported as.
+const (
+	RequirementInformation = "informationRequirement"
+	RequirementKnowledge   = "knowledgeRequirement"
+	RequirementAuthority   = "authorityRequirement"
+)
+
+// causalTypes maps the kind of a DRG element to the causal type it is imported as. Decisions that no other
+// decision requires are imported as outcomes instead.
+var causalTypes = map[string]string{
+	KindDecision:               "Lever",
+	KindInputData:              "External",
+	KindKnowledgeSource:        "External",
+	KindBusinessKnowledgeModel: "Intermediate",
+}
+
+// kindOf returns the DMN kind an element is exported as. Elements imported from DMN keep their kind,
+// and other elements are decisions unless they are external to the model.
+func kindOf(diagramType string, causalType string) string {
+	if _, ok := causalTypes[diagramType]; ok {
+		return diagramType
+	}
+	if strings.EqualFold(causalType, "External") {
+		return KindInputData
+	}
+	return KindDecision
+}
+
+// content is what the content of an imported element holds.
+type content struct {
+	DMNID    string    `json:"dmnId,omitempty"`
+	Position *position `json:"position,omitempty"`
+}
+
+type position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Default size of the shapes of exported elements.
+const (
+	shapeWidth  = 150.0
+	shapeHeight = 60.0
+)
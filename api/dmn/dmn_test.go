@@ -0,0 +1,199 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package dmn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// sequentialUUIDs returns a UUID generator counting up from uuid-0.
+func sequentialUUIDs() func() (string, error) {
+	next := 0
+	return func() (string, error) {
+		uuid := fmt.Sprintf("uuid-%d", next)
+		next++
+		return uuid, nil
+	}
+}
+
+// testCreator is the user the test file is imported as.
+var testCreator = apiTypes.User{UUID: "creator-uuid", Username: "creator", Email: "creator@example.com"}
+
+func importTestFile(t *testing.T) *apiTypes.CausalDecisionModel {
+	file, err := os.Open("../test_files/dmnModel.dmn")
+	if err != nil {
+		t.Fatalf("Error opening DMN file: %s", err)
+	}
+	defer file.Close()
+
+	model, err := Import(file, testCreator, sequentialUUIDs())
+	if err != nil {
+		t.Fatalf("Error importing DMN: %s", err)
+	}
+	return model
+}
+
+func TestImport(t *testing.T) {
+	model := importTestFile(t)
+
+	if model.Meta.Name != "Loan Approval" || model.Meta.Summary != "Decides whether to approve a loan application." {
+		t.Errorf("Expected the model to be named and summarized after the definitions, got %q and %q", model.Meta.Name, model.Meta.Summary)
+	}
+	if len(model.Diagrams) != 1 {
+		t.Fatalf("Expected a single diagram, got %d", len(model.Diagrams))
+	}
+
+	diagram := model.Diagrams[0]
+	metas := []apiTypes.Meta{model.Meta, diagram.Meta}
+	for _, element := range diagram.Elements {
+		metas = append(metas, element.Meta)
+	}
+	for _, dependency := range diagram.Dependencies {
+		metas = append(metas, dependency.Meta)
+	}
+	for _, meta := range metas {
+		if meta.Creator.UUID != testCreator.UUID {
+			t.Errorf("Expected %s to be created by the importing user, got %q", meta.Name, meta.Creator.UUID)
+		}
+	}
+
+	elements := map[string]apiTypes.DiaElement{}
+	names := map[string]string{}
+	for _, element := range diagram.Elements {
+		elements[element.Meta.Name] = element
+		names[element.Meta.UUID] = element.Meta.Name
+	}
+
+	expected := map[string][2]string{
+		"Approve Loan":   {"Outcome", KindDecision},
+		"Risk Score":     {"Lever", KindDecision},
+		"Scorecard":      {"Intermediate", KindBusinessKnowledgeModel},
+		"Lending Policy": {"External", KindKnowledgeSource},
+		"Applicant":      {"External", KindInputData},
+	}
+	if len(elements) != len(expected) {
+		t.Fatalf("Expected %d elements, got %d", len(expected), len(elements))
+	}
+	for name, types := range expected {
+		element := elements[name]
+		if element.CausalType != types[0] || element.DiagramType != types[1] {
+			t.Errorf("Expected %s to be a %s %s, got a %s %s", name, types[0], types[1], element.CausalType, element.DiagramType)
+		}
+	}
+
+	var c content
+	json.Unmarshal(elements["Approve Loan"].Content, &c)
+	if c.DMNID != "approval" || c.Position == nil || c.Position.X != 190 || c.Position.Y != 60 {
+		t.Errorf("Expected the DMN id and the center of the shape in the content, got %s", elements["Approve Loan"].Content)
+	}
+
+	// The requirement on an element of another file is left out.
+	dependencies := map[string]string{}
+	for _, dependency := range diagram.Dependencies {
+		dependencies[names[dependency.Source]+" -> "+names[dependency.Target]] = dependency.Meta.Name
+	}
+	expectedDependencies := map[string]string{
+		"Risk Score -> Approve Loan":     RequirementInformation,
+		"Applicant -> Approve Loan":      RequirementInformation,
+		"Lending Policy -> Approve Loan": RequirementAuthority,
+		"Applicant -> Risk Score":        RequirementInformation,
+		"Scorecard -> Risk Score":        RequirementKnowledge,
+	}
+	if len(dependencies) != len(expectedDependencies) {
+		t.Errorf("Expected %d dependencies, got %v", len(expectedDependencies), dependencies)
+	}
+	for edge, kind := range expectedDependencies {
+		if dependencies[edge] != kind {
+			t.Errorf("Expected %s to be an %s, got %q", edge, kind, dependencies[edge])
+		}
+	}
+
+	if _, err := Import(strings.NewReader("<definitions><decision id=\"a\"/><inputData id=\"a\"/></definitions>"), testCreator, sequentialUUIDs()); err == nil {
+		t.Errorf("Expected an error for repeated ids")
+	}
+	if _, err := Import(strings.NewReader("not xml"), testCreator, sequentialUUIDs()); err == nil {
+		t.Errorf("Expected an error for a file that isn't DMN")
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	model := importTestFile(t)
+
+	exported, err := Export(*model)
+	if err != nil {
+		t.Fatalf("Error exporting DMN: %s", err)
+	}
+
+	for _, expected := range []string{
+		`<decision id="approval" name="Approve Loan">`,
+		`<requiredKnowledge href="#scorecard"></requiredKnowledge>`,
+		`<requiredAuthority href="#policy"></requiredAuthority>`,
+		`<dmndi:DMNShape id="risk_di" dmnElementRef="risk">`,
+		`<dc:Bounds x="115" y="210" width="150" height="60"></dc:Bounds>`,
+	} {
+		if !strings.Contains(string(exported), expected) {
+			t.Errorf("Expected the export to contain %s, got:\n%s", expected, exported)
+		}
+	}
+
+	reimported, err := Import(bytes.NewReader(exported), testCreator, sequentialUUIDs())
+	if err != nil {
+		t.Fatalf("Error importing exported DMN: %s", err)
+	}
+	if len(reimported.Diagrams[0].Elements) != 5 || len(reimported.Diagrams[0].Dependencies) != 5 {
+		t.Errorf("Expected the export to import back with 5 elements and 5 dependencies, got %d and %d",
+			len(reimported.Diagrams[0].Elements), len(reimported.Diagrams[0].Dependencies))
+	}
+}
+
+func TestExportNonDMNModel(t *testing.T) {
+	model := apiTypes.CausalDecisionModel{
+		Meta: apiTypes.Meta{UUID: "model", Name: "Model"},
+		Diagrams: []apiTypes.Diagram{{
+			Meta: apiTypes.Meta{UUID: "diagram"},
+			Elements: []apiTypes.DiaElement{
+				{Meta: apiTypes.Meta{UUID: "lever"}, CausalType: "Lever"},
+				{Meta: apiTypes.Meta{UUID: "market"}, CausalType: "External"},
+				{Meta: apiTypes.Meta{UUID: "outcome"}, CausalType: "Outcome"},
+			},
+			Dependencies: []apiTypes.CausalDependency{
+				{Meta: apiTypes.Meta{UUID: "d1"}, Source: "lever", Target: "outcome"},
+				{Meta: apiTypes.Meta{UUID: "d2"}, Source: "market", Target: "outcome"},
+				{Meta: apiTypes.Meta{UUID: "d3"}, Source: "outcome", Target: "market"},
+			},
+		}},
+	}
+
+	exported, err := Export(model)
+	if err != nil {
+		t.Fatalf("Error exporting DMN: %s", err)
+	}
+
+	for _, expected := range []string{
+		`<decision id="_lever" name="lever">`,
+		`<inputData id="_market" name="market">`,
+		`<requiredDecision href="#_lever"></requiredDecision>`,
+		`<requiredInput href="#_market"></requiredInput>`,
+	} {
+		if !strings.Contains(string(exported), expected) {
+			t.Errorf("Expected the export to contain %s, got:\n%s", expected, exported)
+		}
+	}
+
+	// Input data can't require anything in DMN.
+	if strings.Contains(string(exported), "#_outcome") {
+		t.Errorf("Expected the dependency of input data on a decision to be left out, got:\n%s", exported)
+	}
+	if strings.Contains(string(exported), "<dmndi:DMNDI>") {
+		t.Errorf("Expected no DMNDI without positions, got:\n%s", exported)
+	}
+}
@@ -0,0 +1,206 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package dmn
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// Namespaces of the DMN 1.3 files written by Export.
+const (
+	namespaceDMN   = "https://www.omg.org/spec/DMN/20191111/MODEL/"
+	namespaceDMNDI = "https://www.omg.org/spec/DMN/20191111/DMNDI/"
+	namespaceDC    = "http://www.omg.org/spec/DMN/20180521/DC/"
+)
+
+// The structs below write DMN with fixed prefixes for DMNDI, since encoding/xml can't choose prefixes itself.
+
+type outReference struct {
+	Href string `xml:"href,attr"`
+}
+
+type outRequirement struct {
+	ID                string        `xml:"id,attr"`
+	RequiredDecision  *outReference `xml:"requiredDecision,omitempty"`
+	RequiredInput     *outReference `xml:"requiredInput,omitempty"`
+	RequiredKnowledge *outReference `xml:"requiredKnowledge,omitempty"`
+	RequiredAuthority *outReference `xml:"requiredAuthority,omitempty"`
+}
+
+type outElement struct {
+	XMLName                 xml.Name
+	ID                      string           `xml:"id,attr"`
+	Name                    string           `xml:"name,attr"`
+	Description             string           `xml:"description,omitempty"`
+	InformationRequirements []outRequirement `xml:"informationRequirement"`
+	KnowledgeRequirements   []outRequirement `xml:"knowledgeRequirement"`
+	AuthorityRequirements   []outRequirement `xml:"authorityRequirement"`
+}
+
+type outBounds struct {
+	X      float64 `xml:"x,attr"`
+	Y      float64 `xml:"y,attr"`
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+}
+
+type outShape struct {
+	ID         string    `xml:"id,attr"`
+	ElementRef string    `xml:"dmnElementRef,attr"`
+	Bounds     outBounds `xml:"dc:Bounds"`
+}
+
+type outDiagram struct {
+	ID     string     `xml:"id,attr"`
+	Name   string     `xml:"name,attr,omitempty"`
+	Shapes []outShape `xml:"dmndi:DMNShape"`
+}
+
+type outDMNDI struct {
+	Diagrams []outDiagram `xml:"dmndi:DMNDiagram"`
+}
+
+type outDefinitions struct {
+	XMLName     xml.Name     `xml:"definitions"`
+	XMLNS       string       `xml:"xmlns,attr"`
+	XMLNSDMNDI  string       `xml:"xmlns:dmndi,attr"`
+	XMLNSDC     string       `xml:"xmlns:dc,attr"`
+	ID          string       `xml:"id,attr"`
+	Name        string       `xml:"name,attr"`
+	Namespace   string       `xml:"namespace,attr"`
+	Description string       `xml:"description,omitempty"`
+	Elements    []outElement `xml:",any"`
+	DMNDI       *outDMNDI    `xml:"dmndi:DMNDI,omitempty"`
+}
+
+// ncName matches the ids DMN allows.
+var ncName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Export writes a model as a DMN 1.3 file. The elements of every diagram go into one decision requirements graph,
+// and each diagram gets its own DMNDI diagram for the elements that have a position.
+// Dependencies DMN has no requirement for, such as a decision required by input data, are left out.
+func Export(model apiTypes.CausalDecisionModel) ([]byte, error) {
+	defs := outDefinitions{
+		XMLNS:       namespaceDMN,
+		XMLNSDMNDI:  namespaceDMNDI,
+		XMLNSDC:     namespaceDC,
+		ID:          "_" + model.Meta.UUID,
+		Name:        model.Meta.Name,
+		Namespace:   "urn:opendi:model:" + model.Meta.UUID,
+		Description: model.Meta.Summary,
+	}
+
+	// Give every element a DMN id, keeping the one it was imported with where it is still unique.
+	ids := map[string]string{}
+	kinds := map[string]string{}
+	used := map[string]bool{}
+	index := map[string]int{}
+	var dmndi outDMNDI
+	for d, diagram := range model.Diagrams {
+		diagramDI := outDiagram{ID: "_" + diagram.Meta.UUID, Name: diagram.Meta.Name}
+
+		for _, element := range diagram.Elements {
+			if _, ok := ids[element.Meta.UUID]; ok {
+				continue
+			}
+
+			var c content
+			json.Unmarshal(element.Content, &c)
+
+			id := c.DMNID
+			if id == "" || used[id] || !ncName.MatchString(id) {
+				id = "_" + element.Meta.UUID
+			}
+			used[id] = true
+			ids[element.Meta.UUID] = id
+
+			// DMN elements need a name, so unnamed elements are named by their UUID.
+			name := element.Meta.Name
+			if name == "" {
+				name = element.Meta.UUID
+			}
+
+			kind := kindOf(element.DiagramType, element.CausalType)
+			kinds[element.Meta.UUID] = kind
+			index[element.Meta.UUID] = len(defs.Elements)
+			defs.Elements = append(defs.Elements, outElement{
+				XMLName:     xml.Name{Local: kind},
+				ID:          id,
+				Name:        name,
+				Description: element.Meta.Summary,
+			})
+
+			if c.Position != nil {
+				diagramDI.Shapes = append(diagramDI.Shapes, outShape{
+					ID:         id + "_di",
+					ElementRef: id,
+					Bounds: outBounds{
+						X:      c.Position.X - shapeWidth/2,
+						Y:      c.Position.Y - shapeHeight/2,
+						Width:  shapeWidth,
+						Height: shapeHeight,
+					},
+				})
+			}
+		}
+
+		if len(diagramDI.Shapes) > 0 {
+			if diagram.Meta.UUID == "" {
+				diagramDI.ID = fmt.Sprintf("_diagram%d", d)
+			}
+			dmndi.Diagrams = append(dmndi.Diagrams, diagramDI)
+		}
+	}
+
+	for _, diagram := range model.Diagrams {
+		for _, dependency := range diagram.Dependencies {
+			sourceID, sourceOK := ids[dependency.Source]
+			_, targetOK := ids[dependency.Target]
+			if !sourceOK || !targetOK {
+				continue
+			}
+
+			target := &defs.Elements[index[dependency.Target]]
+			req := outRequirement{ID: "_" + dependency.Meta.UUID}
+			ref := &outReference{Href: "#" + sourceID}
+
+			switch source, kind := kinds[dependency.Source], kinds[dependency.Target]; {
+			case source == KindBusinessKnowledgeModel && (kind == KindDecision || kind == KindBusinessKnowledgeModel):
+				req.RequiredKnowledge = ref
+				target.KnowledgeRequirements = append(target.KnowledgeRequirements, req)
+			case source == KindKnowledgeSource && kind != KindInputData:
+				req.RequiredAuthority = ref
+				target.AuthorityRequirements = append(target.AuthorityRequirements, req)
+			case kind == KindDecision && source == KindDecision:
+				req.RequiredDecision = ref
+				target.InformationRequirements = append(target.InformationRequirements, req)
+			case kind == KindDecision && source == KindInputData:
+				req.RequiredInput = ref
+				target.InformationRequirements = append(target.InformationRequirements, req)
+			case kind == KindKnowledgeSource && source == KindDecision:
+				req.RequiredDecision = ref
+				target.AuthorityRequirements = append(target.AuthorityRequirements, req)
+			case kind == KindKnowledgeSource && source == KindInputData:
+				req.RequiredInput = ref
+				target.AuthorityRequirements = append(target.AuthorityRequirements, req)
+			}
+		}
+	}
+
+	if len(dmndi.Diagrams) > 0 {
+		defs.DMNDI = &dmndi
+	}
+
+	body, err := xml.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
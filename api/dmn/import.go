@@ -0,0 +1,208 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package dmn
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// The structs below decode DMN by local name, so they read every 1.x version regardless of its namespace.
+
+type reference struct {
+	Href string `xml:"href,attr"`
+}
+
+type requirement struct {
+	RequiredDecision  *reference `xml:"requiredDecision"`
+	RequiredInput     *reference `xml:"requiredInput"`
+	RequiredKnowledge *reference `xml:"requiredKnowledge"`
+	RequiredAuthority *reference `xml:"requiredAuthority"`
+}
+
+// href returns the id the requirement refers to.
+// References into other files can't be resolved, so they are returned as is and left out later.
+func (r requirement) href() string {
+	for _, ref := range []*reference{r.RequiredDecision, r.RequiredInput, r.RequiredKnowledge, r.RequiredAuthority} {
+		if ref != nil {
+			if strings.HasPrefix(ref.Href, "#") {
+				return ref.Href[1:]
+			}
+			return ref.Href
+		}
+	}
+	return ""
+}
+
+type drgElement struct {
+	ID                      string        `xml:"id,attr"`
+	Name                    string        `xml:"name,attr"`
+	Description             string        `xml:"description"`
+	InformationRequirements []requirement `xml:"informationRequirement"`
+	KnowledgeRequirements   []requirement `xml:"knowledgeRequirement"`
+	AuthorityRequirements   []requirement `xml:"authorityRequirement"`
+}
+
+type bounds struct {
+	X      float64 `xml:"x,attr"`
+	Y      float64 `xml:"y,attr"`
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+}
+
+type shape struct {
+	ElementRef string  `xml:"dmnElementRef,attr"`
+	Bounds     *bounds `xml:"Bounds"`
+}
+
+type definitions struct {
+	XMLName                 xml.Name     `xml:"definitions"`
+	ID                      string       `xml:"id,attr"`
+	Name                    string       `xml:"name,attr"`
+	Description             string       `xml:"description"`
+	Decisions               []drgElement `xml:"decision"`
+	BusinessKnowledgeModels []drgElement `xml:"businessKnowledgeModel"`
+	KnowledgeSources        []drgElement `xml:"knowledgeSource"`
+	InputData               []drgElement `xml:"inputData"`
+	Shapes                  []shape      `xml:"DMNDI>DMNDiagram>DMNShape"`
+}
+
+// Import reads a DMN file into a model with a single diagram, created by creator along with every component in it.
+// newUUID gives the UUIDs of the model, its diagram, elements and dependencies, since DMN ids are only unique within their file.
+func Import(r io.Reader, creator apiTypes.User, newUUID func() (string, error)) (*apiTypes.CausalDecisionModel, error) {
+	var defs definitions
+	if err := xml.NewDecoder(r).Decode(&defs); err != nil {
+		return nil, fmt.Errorf("error parsing DMN: %v", err)
+	}
+
+	name := defs.Name
+	if name == "" {
+		name = defs.ID
+	}
+
+	modelUUID, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	diagramUUID, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	diagram := apiTypes.Diagram{
+		Meta: apiTypes.Meta{UUID: diagramUUID, Name: name, Creator: creator},
+	}
+
+	positions := map[string]*position{}
+	for _, s := range defs.Shapes {
+		if s.Bounds != nil {
+			positions[s.ElementRef] = &position{
+				X: s.Bounds.X + s.Bounds.Width/2,
+				Y: s.Bounds.Y + s.Bounds.Height/2,
+			}
+		}
+	}
+
+	// Decisions no other decision requires are what the model decides, so they are its outcomes.
+	required := map[string]bool{}
+	for _, d := range defs.Decisions {
+		for _, req := range append(append([]requirement{}, d.InformationRequirements...), d.AuthorityRequirements...) {
+			if req.RequiredDecision != nil {
+				required[req.href()] = true
+			}
+		}
+	}
+
+	// Create the elements first, so that requirements can refer to elements defined after them.
+	uuids := map[string]string{}
+	kinds := []struct {
+		kind     string
+		elements []drgElement
+	}{
+		{KindDecision, defs.Decisions},
+		{KindBusinessKnowledgeModel, defs.BusinessKnowledgeModels},
+		{KindKnowledgeSource, defs.KnowledgeSources},
+		{KindInputData, defs.InputData},
+	}
+	for _, k := range kinds {
+		for _, e := range k.elements {
+			if _, ok := uuids[e.ID]; ok || e.ID == "" {
+				return nil, fmt.Errorf("DMN elements need a unique id, got %q more than once", e.ID)
+			}
+
+			uuid, err := newUUID()
+			if err != nil {
+				return nil, err
+			}
+			uuids[e.ID] = uuid
+
+			raw, err := json.Marshal(content{DMNID: e.ID, Position: positions[e.ID]})
+			if err != nil {
+				return nil, err
+			}
+
+			elementName := e.Name
+			if elementName == "" {
+				elementName = e.ID
+			}
+			causalType := causalTypes[k.kind]
+			if k.kind == KindDecision && !required[e.ID] {
+				causalType = "Outcome"
+			}
+			diagram.Elements = append(diagram.Elements, apiTypes.DiaElement{
+				Meta:        apiTypes.Meta{UUID: uuid, Name: elementName, Summary: strings.TrimSpace(e.Description), Creator: creator},
+				CausalType:  causalType,
+				DiagramType: k.kind,
+				Content:     raw,
+			})
+		}
+	}
+
+	for _, k := range kinds {
+		for _, e := range k.elements {
+			requirements := []struct {
+				kind         string
+				requirements []requirement
+			}{
+				{RequirementInformation, e.InformationRequirements},
+				{RequirementKnowledge, e.KnowledgeRequirements},
+				{RequirementAuthority, e.AuthorityRequirements},
+			}
+			for _, r := range requirements {
+				for _, req := range r.requirements {
+					source, ok := uuids[req.href()]
+					if !ok {
+						continue
+					}
+
+					uuid, err := newUUID()
+					if err != nil {
+						return nil, err
+					}
+					diagram.Dependencies = append(diagram.Dependencies, apiTypes.CausalDependency{
+						Meta:   apiTypes.Meta{UUID: uuid, Name: r.kind, Creator: creator},
+						Source: source,
+						Target: uuids[e.ID],
+					})
+				}
+			}
+		}
+	}
+
+	return &apiTypes.CausalDecisionModel{
+		Meta: apiTypes.Meta{
+			UUID:    modelUUID,
+			Name:    name,
+			Summary: strings.TrimSpace(defs.Description),
+			Creator: creator,
+		},
+		Diagrams: []apiTypes.Diagram{diagram},
+	}, nil
+}
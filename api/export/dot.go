@@ -0,0 +1,64 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package export
+
+import (
+	"fmt"
+	"strings"
+)
+
+// quoteDOT quotes a string as a DOT ID.
+func quoteDOT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func renderDOT(clusters []cluster, title string, grouped bool) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph %s {\n", quoteDOT(title))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [style=filled];\n")
+
+	for _, c := range clusters {
+		indent := "  "
+		if grouped {
+			fmt.Fprintf(&b, "  subgraph %s {\n", quoteDOT("cluster_"+c.ID))
+			fmt.Fprintf(&b, "    label=%s;\n", quoteDOT(c.Label))
+			indent = "    "
+		}
+
+		for _, n := range c.Nodes {
+			s := styleOf(n.CausalType)
+			attributes := []string{
+				"label=" + quoteDOT(n.Label),
+				"shape=" + s.Shape,
+				"fillcolor=" + quoteDOT(s.Fill),
+				"id=" + quoteDOT(n.UUID),
+			}
+			if n.CausalType != "" || n.DiaType != "" {
+				attributes = append(attributes, "class="+quoteDOT(strings.TrimSpace(n.CausalType+" "+n.DiaType)))
+			}
+			fmt.Fprintf(&b, "%s%s [%s];\n", indent, n.ID, strings.Join(attributes, ", "))
+		}
+
+		for _, e := range c.Edges {
+			attributes := []string{"id=" + quoteDOT(e.UUID)}
+			if e.Label != "" {
+				attributes = append([]string{"label=" + quoteDOT(e.Label)}, attributes...)
+			}
+			fmt.Fprintf(&b, "%s%s -> %s [%s];\n", indent, e.From, e.To, strings.Join(attributes, ", "))
+		}
+
+		if grouped {
+			b.WriteString("  }\n")
+		}
+	}
+
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,189 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package export renders diagrams as Graphviz DOT, Mermaid and GraphML, so model structure can be pasted into documents and graph tools.
+// Elements become nodes labelled by their name and styled by their causal type, and dependencies become edges.
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// Format is a format diagrams can be exported in.
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatMermaid Format = "mermaid"
+	FormatGraphML Format = "graphml"
+)
+
+// ParseFormat returns the export format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatDOT:
+		return FormatDOT, nil
+	case FormatMermaid:
+		return FormatMermaid, nil
+	case FormatGraphML:
+		return FormatGraphML, nil
+	}
+	return "", fmt.Errorf("unknown export format %s, expected dot, mermaid or graphml", name)
+}
+
+// ContentType returns the media type of exports in this format.
+func (format Format) ContentType() string {
+	switch format {
+	case FormatDOT:
+		return "text/vnd.graphviz; charset=utf-8"
+	case FormatGraphML:
+		return "application/graphml+xml; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Extension returns the file extension of exports in this format.
+func (format Format) Extension() string {
+	switch format {
+	case FormatDOT:
+		return "dot"
+	case FormatGraphML:
+		return "graphml"
+	}
+	return "mmd"
+}
+
+// node is an element as it is drawn.
+type node struct {
+	ID         string
+	UUID       string
+	Label      string
+	CausalType string
+	DiaType    string
+}
+
+// edge is a dependency as it is drawn.
+type edge struct {
+	ID    string
+	UUID  string
+	From  string
+	To    string
+	Label string
+}
+
+// cluster is a diagram as it is drawn.
+type cluster struct {
+	ID    string
+	UUID  string
+	Label string
+	Nodes []node
+	Edges []edge
+}
+
+// label names a component by its name if it has one, and its UUID otherwise.
+func label(meta apiTypes.Meta) string {
+	if meta.Name != "" {
+		return meta.Name
+	}
+	return meta.UUID
+}
+
+// newCluster lays out a diagram for drawing. Node and edge IDs are prefixed so that the diagrams of a model don't clash,
+// and dependencies whose source or target isn't an element of the diagram are left out.
+func newCluster(diagram apiTypes.Diagram, prefix string) cluster {
+	c := cluster{
+		ID:    prefix,
+		UUID:  diagram.Meta.UUID,
+		Label: label(diagram.Meta),
+	}
+
+	ids := map[string]string{}
+	for _, element := range diagram.Elements {
+		if _, ok := ids[element.Meta.UUID]; ok {
+			continue
+		}
+		id := fmt.Sprintf("%sn%d", prefix, len(c.Nodes))
+		ids[element.Meta.UUID] = id
+		c.Nodes = append(c.Nodes, node{
+			ID:         id,
+			UUID:       element.Meta.UUID,
+			Label:      label(element.Meta),
+			CausalType: element.CausalType,
+			DiaType:    element.DiagramType,
+		})
+	}
+
+	for _, dependency := range diagram.Dependencies {
+		from, fromOK := ids[dependency.Source]
+		to, toOK := ids[dependency.Target]
+		if !fromOK || !toOK {
+			continue
+		}
+		c.Edges = append(c.Edges, edge{
+			ID:    fmt.Sprintf("%se%d", prefix, len(c.Edges)),
+			UUID:  dependency.Meta.UUID,
+			From:  from,
+			To:    to,
+			Label: dependency.Meta.Name,
+		})
+	}
+
+	return c
+}
+
+// style is how elements of a causal type are drawn.
+type style struct {
+	Shape string
+	Fill  string
+}
+
+var styles = map[string]style{
+	"lever":        {Shape: "box", Fill: "#cfe2ff"},
+	"outcome":      {Shape: "doubleoctagon", Fill: "#d1e7dd"},
+	"intermediate": {Shape: "ellipse", Fill: "#fff3cd"},
+	"external":     {Shape: "diamond", Fill: "#e2e3e5"},
+}
+
+var defaultStyle = style{Shape: "ellipse", Fill: "#ffffff"}
+
+// FillColor returns the color elements of a causal type are filled with.
+func FillColor(causalType string) string {
+	return styleOf(causalType).Fill
+}
+
+func styleOf(causalType string) style {
+	if s, ok := styles[strings.ToLower(causalType)]; ok {
+		return s
+	}
+	return defaultStyle
+}
+
+// Diagram exports a single diagram.
+func Diagram(diagram apiTypes.Diagram, format Format) ([]byte, error) {
+	return render([]cluster{newCluster(diagram, "")}, label(diagram.Meta), false, format)
+}
+
+// Model exports every diagram of a model together, with each diagram drawn as its own group.
+func Model(model apiTypes.CausalDecisionModel, format Format) ([]byte, error) {
+	clusters := make([]cluster, 0, len(model.Diagrams))
+	for i, diagram := range model.Diagrams {
+		clusters = append(clusters, newCluster(diagram, fmt.Sprintf("d%d", i)))
+	}
+	return render(clusters, label(model.Meta), true, format)
+}
+
+func render(clusters []cluster, title string, grouped bool, format Format) ([]byte, error) {
+	switch format {
+	case FormatDOT:
+		return renderDOT(clusters, title, grouped), nil
+	case FormatMermaid:
+		return renderMermaid(clusters, title, grouped), nil
+	case FormatGraphML:
+		return renderGraphML(clusters, title)
+	}
+	return nil, fmt.Errorf("unknown export format %s", format)
+}
@@ -0,0 +1,118 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package export
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+func testDiagram(uuid string) apiTypes.Diagram {
+	return apiTypes.Diagram{
+		Meta: apiTypes.Meta{UUID: uuid, Name: "Pricing"},
+		Elements: []apiTypes.DiaElement{
+			{Meta: apiTypes.Meta{UUID: uuid + "-lever", Name: `Set "price"`}, CausalType: "Lever", DiagramType: "box"},
+			{Meta: apiTypes.Meta{UUID: uuid + "-outcome", Name: "Revenue"}, CausalType: "Outcome"},
+		},
+		Dependencies: []apiTypes.CausalDependency{
+			{Meta: apiTypes.Meta{UUID: uuid + "-dependency", Name: "drives"}, Source: uuid + "-lever", Target: uuid + "-outcome"},
+			{Meta: apiTypes.Meta{UUID: uuid + "-dangling"}, Source: uuid + "-lever", Target: "missing"},
+		},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"dot", "Mermaid", "GRAPHML"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("Expected %s to be a format, got %s", name, err)
+		}
+	}
+	if _, err := ParseFormat("png"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+func TestDiagramDOT(t *testing.T) {
+	body, err := Diagram(testDiagram("d"), FormatDOT)
+	if err != nil {
+		t.Fatalf("Error exporting diagram: %s", err)
+	}
+
+	expected := `digraph "Pricing" {
+  rankdir=LR;
+  node [style=filled];
+  n0 [label="Set \"price\"", shape=box, fillcolor="#cfe2ff", id="d-lever", class="Lever box"];
+  n1 [label="Revenue", shape=doubleoctagon, fillcolor="#d1e7dd", id="d-outcome", class="Outcome"];
+  n0 -> n1 [label="drives", id="d-dependency"];
+}
+`
+	if string(body) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestDiagramMermaid(t *testing.T) {
+	body, err := Diagram(testDiagram("d"), FormatMermaid)
+	if err != nil {
+		t.Fatalf("Error exporting diagram: %s", err)
+	}
+
+	expected := `---
+title: "Pricing"
+---
+flowchart LR
+  n0["Set #quot;price#quot;"]:::lever
+  n1[["Revenue"]]:::outcome
+  n0 -->|"drives"| n1
+  classDef lever fill:#cfe2ff
+  classDef outcome fill:#d1e7dd
+`
+	if string(body) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestModelExport(t *testing.T) {
+	model := apiTypes.CausalDecisionModel{
+		Meta:     apiTypes.Meta{UUID: "model", Name: "Model"},
+		Diagrams: []apiTypes.Diagram{testDiagram("a"), testDiagram("b")},
+	}
+
+	body, err := Model(model, FormatDOT)
+	if err != nil {
+		t.Fatalf("Error exporting model: %s", err)
+	}
+	for _, expected := range []string{`subgraph "cluster_d0"`, `subgraph "cluster_d1"`, "d0n0 -> d0n1", "d1n0 -> d1n1"} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected the DOT export to contain %s, got:\n%s", expected, body)
+		}
+	}
+
+	body, err = Model(model, FormatMermaid)
+	if err != nil {
+		t.Fatalf("Error exporting model: %s", err)
+	}
+	if strings.Count(string(body), "subgraph") != 2 || strings.Count(string(body), "  end\n") != 2 {
+		t.Errorf("Expected a Mermaid subgraph per diagram, got:\n%s", body)
+	}
+
+	body, err = Model(model, FormatGraphML)
+	if err != nil {
+		t.Fatalf("Error exporting model: %s", err)
+	}
+	var doc graphML
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Expected the GraphML export to be valid XML: %s", err)
+	}
+	if len(doc.Graphs) != 2 || len(doc.Graphs[0].Nodes) != 2 || len(doc.Graphs[0].Edges) != 1 {
+		t.Errorf("Expected 2 graphs of 2 nodes and 1 edge, got:\n%s", body)
+	}
+	if doc.Graphs[1].Edges[0].Source != "d1n0" || doc.Graphs[1].Edges[0].Target != "d1n1" {
+		t.Errorf("Expected the edges of the second diagram to use its own node IDs")
+	}
+}
@@ -0,0 +1,117 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type graphML struct {
+	XMLName xml.Name       `xml:"graphml"`
+	XMLNS   string         `xml:"xmlns,attr"`
+	Desc    string         `xml:"desc,omitempty"`
+	Keys    []graphMLKey   `xml:"key"`
+	Graphs  []graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Data        []graphMLData `xml:"data"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+// graphMLKeys declares the attributes exported for graphs, nodes and edges.
+var graphMLKeys = []graphMLKey{
+	{ID: "graph_uuid", For: "graph", AttrName: "uuid", AttrType: "string"},
+	{ID: "graph_name", For: "graph", AttrName: "name", AttrType: "string"},
+	{ID: "node_uuid", For: "node", AttrName: "uuid", AttrType: "string"},
+	{ID: "node_name", For: "node", AttrName: "name", AttrType: "string"},
+	{ID: "node_causal_type", For: "node", AttrName: "causalType", AttrType: "string"},
+	{ID: "node_dia_type", For: "node", AttrName: "diaType", AttrType: "string"},
+	{ID: "node_fill", For: "node", AttrName: "fill", AttrType: "string"},
+	{ID: "edge_uuid", For: "edge", AttrName: "uuid", AttrType: "string"},
+	{ID: "edge_name", For: "edge", AttrName: "name", AttrType: "string"},
+}
+
+// data leaves out attributes without a value.
+func data(pairs ...string) []graphMLData {
+	var d []graphMLData
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			d = append(d, graphMLData{Key: pairs[i], Value: pairs[i+1]})
+		}
+	}
+	return d
+}
+
+// renderGraphML writes each diagram as its own graph in a single GraphML document.
+func renderGraphML(clusters []cluster, title string) ([]byte, error) {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Desc:  title,
+		Keys:  graphMLKeys,
+	}
+
+	for i, c := range clusters {
+		// A single diagram isn't prefixed, but its graph still needs an ID.
+		id := c.ID
+		if id == "" {
+			id = fmt.Sprintf("d%d", i)
+		}
+		graph := graphMLGraph{
+			ID:          id,
+			EdgeDefault: "directed",
+			Data:        data("graph_uuid", c.UUID, "graph_name", c.Label),
+		}
+		for _, n := range c.Nodes {
+			graph.Nodes = append(graph.Nodes, graphMLNode{
+				ID:   n.ID,
+				Data: data("node_uuid", n.UUID, "node_name", n.Label, "node_causal_type", n.CausalType, "node_dia_type", n.DiaType, "node_fill", styleOf(n.CausalType).Fill),
+			})
+		}
+		for _, e := range c.Edges {
+			graph.Edges = append(graph.Edges, graphMLEdge{
+				ID:     e.ID,
+				Source: e.From,
+				Target: e.To,
+				Data:   data("edge_uuid", e.UUID, "edge_name", e.Label),
+			})
+		}
+		doc.Graphs = append(doc.Graphs, graph)
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
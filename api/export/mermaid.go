@@ -0,0 +1,87 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// quoteMermaid quotes a string as a Mermaid label, escaping the characters Mermaid would otherwise parse.
+func quoteMermaid(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", " ")
+	return `"` + s + `"`
+}
+
+// mermaidShapes wraps a label in the brackets of the Mermaid shape closest to the Graphviz shape of each style.
+var mermaidShapes = map[string][2]string{
+	"box":           {"[", "]"},
+	"doubleoctagon": {"[[", "]]"},
+	"ellipse":       {"(", ")"},
+	"diamond":       {"{", "}"},
+}
+
+// mermaidClass returns the class name of a causal type, which must be a plain identifier.
+func mermaidClass(causalType string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(causalType) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "element"
+	}
+	return b.String()
+}
+
+func renderMermaid(clusters []cluster, title string, grouped bool) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "---\ntitle: %s\n---\n", quoteMermaid(title))
+	b.WriteString("flowchart LR\n")
+
+	classes := map[string]string{}
+	for _, c := range clusters {
+		indent := "  "
+		if grouped {
+			fmt.Fprintf(&b, "  subgraph %s[%s]\n", c.ID, quoteMermaid(c.Label))
+			indent = "    "
+		}
+
+		for _, n := range c.Nodes {
+			s := styleOf(n.CausalType)
+			brackets := mermaidShapes[s.Shape]
+			class := mermaidClass(n.CausalType)
+			classes[class] = s.Fill
+			fmt.Fprintf(&b, "%s%s%s%s%s:::%s\n", indent, n.ID, brackets[0], quoteMermaid(n.Label), brackets[1], class)
+		}
+
+		for _, e := range c.Edges {
+			if e.Label != "" {
+				fmt.Fprintf(&b, "%s%s -->|%s| %s\n", indent, e.From, quoteMermaid(e.Label), e.To)
+			} else {
+				fmt.Fprintf(&b, "%s%s --> %s\n", indent, e.From, e.To)
+			}
+		}
+
+		if grouped {
+			b.WriteString("  end\n")
+		}
+	}
+
+	names := make([]string, 0, len(classes))
+	for class := range classes {
+		names = append(names, class)
+	}
+	sort.Strings(names)
+	for _, class := range names {
+		fmt.Fprintf(&b, "  classDef %s fill:%s\n", class, classes[class])
+	}
+
+	return []byte(b.String())
+}
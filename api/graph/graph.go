@@ -0,0 +1,315 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package graph treats a diagram as a directed graph, with its elements as nodes and its dependencies as edges,
+// and answers structural questions about it.
+package graph
+
+import (
+	"errors"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// ErrCycle is returned when a topological order is asked for a graph that has a cycle.
+var ErrCycle = errors.New("the dependencies of the diagram form a cycle")
+
+// ErrPathBudget is returned when the search for paths between two nodes visits more than MaxPathVisits nodes.
+var ErrPathBudget = errors.New("there are too many paths between the elements to search them all")
+
+// MaxPathVisits bounds the number of nodes the search for paths visits, as the number of paths can grow
+// exponentially with the size of a diagram.
+var MaxPathVisits = 100000
+
+// Graph is a directed graph of the elements of a diagram, identified by their meta UUIDs.
+// Nodes and edges keep the order they have in the diagram, so every query gives the same answer for the same diagram.
+type Graph struct {
+	nodes []string
+	index map[string]int
+	out   map[string][]string
+	in    map[string][]string
+}
+
+// New builds the graph of a diagram. Dependencies whose source or target isn't an element of the diagram are left out,
+// as are repeated dependencies between the same pair of elements.
+func New(diagram apiTypes.Diagram) *Graph {
+	g := &Graph{
+		index: map[string]int{},
+		out:   map[string][]string{},
+		in:    map[string][]string{},
+	}
+
+	for _, element := range diagram.Elements {
+		if _, ok := g.index[element.Meta.UUID]; ok {
+			continue
+		}
+		g.index[element.Meta.UUID] = len(g.nodes)
+		g.nodes = append(g.nodes, element.Meta.UUID)
+	}
+
+	seen := map[[2]string]bool{}
+	for _, dependency := range diagram.Dependencies {
+		edge := [2]string{dependency.Source, dependency.Target}
+		if !g.HasNode(edge[0]) || !g.HasNode(edge[1]) || seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		g.out[edge[0]] = append(g.out[edge[0]], edge[1])
+		g.in[edge[1]] = append(g.in[edge[1]], edge[0])
+	}
+
+	return g
+}
+
+// Nodes returns every node of the graph in diagram order.
+func (g *Graph) Nodes() []string {
+	return append([]string{}, g.nodes...)
+}
+
+// HasNode reports whether the graph has an element with the given UUID.
+func (g *Graph) HasNode(uuid string) bool {
+	_, ok := g.index[uuid]
+	return ok
+}
+
+// Successors returns the targets of the dependencies leaving a node.
+func (g *Graph) Successors(uuid string) []string {
+	return append([]string{}, g.out[uuid]...)
+}
+
+// Predecessors returns the sources of the dependencies entering a node.
+func (g *Graph) Predecessors(uuid string) []string {
+	return append([]string{}, g.in[uuid]...)
+}
+
+// TopologicalOrder orders the nodes so that every dependency goes from an earlier node to a later one.
+// Nodes that could go in either order keep their diagram order. It returns ErrCycle if there is no such order.
+func (g *Graph) TopologicalOrder() ([]string, error) {
+	inDegree := map[string]int{}
+	for _, node := range g.nodes {
+		inDegree[node] = len(g.in[node])
+	}
+
+	// Kahn's algorithm, always taking the earliest ready node in diagram order.
+	ready := make([]bool, len(g.nodes))
+	for i, node := range g.nodes {
+		ready[i] = inDegree[node] == 0
+	}
+
+	order := make([]string, 0, len(g.nodes))
+	for len(order) < len(g.nodes) {
+		next := -1
+		for i, isReady := range ready {
+			if isReady {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return nil, ErrCycle
+		}
+
+		ready[next] = false
+		node := g.nodes[next]
+		order = append(order, node)
+		for _, successor := range g.out[node] {
+			inDegree[successor]--
+			if inDegree[successor] == 0 {
+				ready[g.index[successor]] = true
+			}
+		}
+	}
+
+	return order, nil
+}
+
+// reachable returns the nodes reachable from a node by following the given edges, not counting the node itself
+// unless it is on a cycle, in diagram order.
+func (g *Graph) reachable(uuid string, edges map[string][]string) []string {
+	visited := map[string]bool{}
+	queue := []string{uuid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[current] {
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	nodes := []string{}
+	for _, node := range g.nodes {
+		if visited[node] {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Downstream returns every node that can be reached from a node by following dependencies, in diagram order.
+func (g *Graph) Downstream(uuid string) []string {
+	return g.reachable(uuid, g.out)
+}
+
+// Upstream returns every node a node can be reached from by following dependencies, in diagram order.
+func (g *Graph) Upstream(uuid string) []string {
+	return g.reachable(uuid, g.in)
+}
+
+// Paths returns the paths of dependencies from one node to another that don't visit any node twice,
+// each starting with from and ending with to. At most limit paths are returned, and a limit below one returns them all.
+// The search only walks nodes that are both downstream of from and upstream of to, and gives up with ErrPathBudget,
+// along with the paths found so far, after visiting MaxPathVisits nodes.
+func (g *Graph) Paths(from string, to string, limit int) ([][]string, error) {
+	paths := [][]string{}
+	if !g.HasNode(from) || !g.HasNode(to) {
+		return paths, nil
+	}
+
+	// Only nodes between from and to can be on a path from one to the other.
+	between := map[string]bool{from: true, to: true}
+	upstream := map[string]bool{}
+	for _, node := range g.Upstream(to) {
+		upstream[node] = true
+	}
+	for _, node := range g.Downstream(from) {
+		if upstream[node] {
+			between[node] = true
+		}
+	}
+
+	onPath := map[string]bool{}
+	visits := 0
+	var path []string
+	var err error
+	var walk func(node string) bool
+	walk = func(node string) bool {
+		visits++
+		if visits > MaxPathVisits {
+			err = ErrPathBudget
+			return true
+		}
+
+		path = append(path, node)
+		onPath[node] = true
+		defer func() {
+			path = path[:len(path)-1]
+			onPath[node] = false
+		}()
+
+		if node == to {
+			paths = append(paths, append([]string{}, path...))
+			return limit > 0 && len(paths) >= limit
+		}
+
+		for _, next := range g.out[node] {
+			if between[next] && !onPath[next] && walk(next) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(from)
+
+	return paths, err
+}
+
+// StronglyConnectedComponents splits the nodes into groups in which every node can reach every other.
+// Components are ordered by their first node in diagram order, and the nodes of each keep diagram order.
+func (g *Graph) StronglyConnectedComponents() [][]string {
+	// Tarjan's algorithm.
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var components [][]string
+
+	var connect func(node string)
+	connect = func(node string) {
+		index[node] = len(index)
+		lowLink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range g.out[node] {
+			if _, visited := index[next]; !visited {
+				connect(next)
+				lowLink[node] = min(lowLink[node], lowLink[next])
+			} else if onStack[next] {
+				lowLink[node] = min(lowLink[node], index[next])
+			}
+		}
+
+		if lowLink[node] == index[node] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			components = append(components, g.sortByDiagramOrder(component))
+		}
+	}
+
+	for _, node := range g.nodes {
+		if _, visited := index[node]; !visited {
+			connect(node)
+		}
+	}
+
+	// Order the components by their first node.
+	sorted := make([][]string, 0, len(components))
+	first := map[string][]string{}
+	for _, component := range components {
+		first[component[0]] = component
+	}
+	for _, node := range g.nodes {
+		if component, ok := first[node]; ok {
+			sorted = append(sorted, component)
+		}
+	}
+	return sorted
+}
+
+// Cycles returns the strongly connected components that contain a cycle: those with more than one node,
+// and single nodes with a dependency on themselves.
+func (g *Graph) Cycles() [][]string {
+	var cycles [][]string
+	for _, component := range g.StronglyConnectedComponents() {
+		if len(component) > 1 || g.hasEdge(component[0], component[0]) {
+			cycles = append(cycles, component)
+		}
+	}
+	return cycles
+}
+
+func (g *Graph) hasEdge(from string, to string) bool {
+	for _, next := range g.out[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Graph) sortByDiagramOrder(nodes []string) []string {
+	inSet := map[string]bool{}
+	for _, node := range nodes {
+		inSet[node] = true
+	}
+
+	sorted := make([]string, 0, len(nodes))
+	for _, node := range g.nodes {
+		if inSet[node] {
+			sorted = append(sorted, node)
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,140 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// diagram builds a diagram with the given elements and a dependency for each pair of UUIDs in edges.
+func diagram(elements []string, edges ...[2]string) apiTypes.Diagram {
+	var d apiTypes.Diagram
+	for _, uuid := range elements {
+		d.Elements = append(d.Elements, apiTypes.DiaElement{Meta: apiTypes.Meta{UUID: uuid}})
+	}
+	for _, edge := range edges {
+		d.Dependencies = append(d.Dependencies, apiTypes.CausalDependency{Source: edge[0], Target: edge[1]})
+	}
+	return d
+}
+
+// lever -> a -> outcome, lever -> b -> outcome, and a dangling dependency to an element that isn't in the diagram.
+func leverDiagram() apiTypes.Diagram {
+	return diagram(
+		[]string{"outcome", "b", "a", "lever", "unrelated"},
+		[2]string{"lever", "a"},
+		[2]string{"lever", "b"},
+		[2]string{"a", "outcome"},
+		[2]string{"b", "outcome"},
+		[2]string{"a", "missing"},
+	)
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	order, err := New(leverDiagram()).TopologicalOrder()
+	if err != nil {
+		t.Fatalf("Error ordering graph: %s", err)
+	}
+
+	expected := []string{"lever", "b", "a", "outcome", "unrelated"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+
+	cyclic := diagram([]string{"a", "b"}, [2]string{"a", "b"}, [2]string{"b", "a"})
+	if _, err := New(cyclic).TopologicalOrder(); !errors.Is(err, ErrCycle) {
+		t.Errorf("Expected ErrCycle, got %v", err)
+	}
+}
+
+func TestUpstreamAndDownstream(t *testing.T) {
+	g := New(leverDiagram())
+
+	if downstream := g.Downstream("lever"); !reflect.DeepEqual(downstream, []string{"outcome", "b", "a"}) {
+		t.Errorf("Expected everything but unrelated to be downstream of lever, got %v", downstream)
+	}
+	if upstream := g.Upstream("a"); !reflect.DeepEqual(upstream, []string{"lever"}) {
+		t.Errorf("Expected lever to be upstream of a, got %v", upstream)
+	}
+	if upstream := g.Upstream("unrelated"); len(upstream) != 0 {
+		t.Errorf("Expected nothing upstream of unrelated, got %v", upstream)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	g := New(leverDiagram())
+
+	paths, err := g.Paths("lever", "outcome", 0)
+	expected := [][]string{{"lever", "a", "outcome"}, {"lever", "b", "outcome"}}
+	if err != nil || !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v (%v)", expected, paths, err)
+	}
+
+	if paths, _ := g.Paths("lever", "outcome", 1); len(paths) != 1 {
+		t.Errorf("Expected the limit to stop after 1 path, got %d", len(paths))
+	}
+	if paths, _ := g.Paths("outcome", "lever", 0); len(paths) != 0 {
+		t.Errorf("Expected no paths against the dependencies, got %v", paths)
+	}
+}
+
+func TestPathsBudget(t *testing.T) {
+	// A chain of diamonds has 2^n paths from its start to its end, and a long tail, walked first, that leads nowhere.
+	nodes := []string{"n0"}
+	var edges [][2]string
+	for i := 0; i < 1000; i++ {
+		nodes = append(nodes, fmt.Sprintf("tail%d", i))
+		edges = append(edges, [2]string{"n0", fmt.Sprintf("tail%d", i)})
+	}
+	for i := 1; i <= 20; i++ {
+		previous, left, right, next := fmt.Sprintf("n%d", i-1), fmt.Sprintf("l%d", i), fmt.Sprintf("r%d", i), fmt.Sprintf("n%d", i)
+		nodes = append(nodes, left, right, next)
+		edges = append(edges, [2]string{previous, left}, [2]string{previous, right}, [2]string{left, next}, [2]string{right, next})
+	}
+	g := New(diagram(nodes, edges...))
+
+	// Pruning the tail keeps the search for a few paths cheap.
+	defer func(visits int) { MaxPathVisits = visits }(MaxPathVisits)
+	MaxPathVisits = 100
+	if paths, err := g.Paths("n0", "n20", 2); err != nil || len(paths) != 2 {
+		t.Errorf("Expected 2 paths within the budget, got %d (%v)", len(paths), err)
+	}
+
+	paths, err := g.Paths("n0", "n20", 0)
+	if !errors.Is(err, ErrPathBudget) {
+		t.Errorf("Expected the search for every path to run out of budget, got %v", err)
+	}
+	if len(paths) == 0 {
+		t.Errorf("Expected the paths found before running out of budget")
+	}
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	g := New(diagram(
+		[]string{"a", "b", "c", "d", "e"},
+		[2]string{"a", "b"},
+		[2]string{"b", "c"},
+		[2]string{"c", "a"},
+		[2]string{"c", "d"},
+		[2]string{"e", "e"},
+	))
+
+	components := g.StronglyConnectedComponents()
+	expected := [][]string{{"a", "b", "c"}, {"d"}, {"e"}}
+	if !reflect.DeepEqual(components, expected) {
+		t.Errorf("Expected components %v, got %v", expected, components)
+	}
+
+	cycles := g.Cycles()
+	expected = [][]string{{"a", "b", "c"}, {"e"}}
+	if !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Expected cycles %v, got %v", expected, cycles)
+	}
+}
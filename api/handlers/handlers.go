@@ -5,96 +5,588 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/bundle"
+	"opendi/model-hub/api/codec"
 	"opendi/model-hub/api/database"
-	jsonDiffHelpers "opendi/model-hub/api/jsondiffhelpers"
-	"strconv" //for applying patches generated with jsondiff
+	"opendi/model-hub/api/dmn"
+	"opendi/model-hub/api/export"
+	"opendi/model-hub/api/graph"
+	"opendi/model-hub/api/lint"
+	"opendi/model-hub/api/thumbnail"
+	"opendi/model-hub/api/validation"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
-//note - we technically don't need these structs for now. However, they could be useful in the future.
-
 // ModelHandler struct for handling model requests
 type ModelHandler struct {
+	models      database.ModelStore
+	commits     database.CommitStore
+	lintConfigs database.LintStore
+	// thumbnails caches the thumbnail of each model until a new commit is made to it.
+	thumbnails *thumbnail.Cache
 }
 
 // CommitHandler struct for handling commit requests
 type CommitHandler struct {
+	commits database.CommitStore
+	models  database.ModelStore
+	// thumbnails is the cache of the model handler, whose thumbnails are dropped when the history of a model is rewritten.
+	thumbnails *thumbnail.Cache
 }
 
 // AuthHandler struct for handling user login/auth requests
 type AuthHandler struct {
+	users           database.UserStore
+	registerOnLogin bool
+}
+
+// LintHandler struct for handling lint rule configuration requests
+type LintHandler struct {
+	lintConfigs database.LintStore
+}
+
+// method for getting an instance of ModelHandler. Models are kept in models, and the commits store is used to
+// know the latest version of a model, while lintConfigs holds the lint rules of each organization. Thumbnails are
+// cached in thumbnails.
+func NewModelHandler(models database.ModelStore, commits database.CommitStore, lintConfigs database.LintStore, thumbnails *thumbnail.Cache) (*ModelHandler, error) {
+
+	return &ModelHandler{models: models, commits: commits, lintConfigs: lintConfigs, thumbnails: thumbnails}, nil
+}
+
+// method for getting an instance of CommitHandler. The models store is used to find who owns a model before its
+// history is rewritten, and thumbnails is the cache of the model handler.
+func NewCommitHandler(commits database.CommitStore, models database.ModelStore, thumbnails *thumbnail.Cache) (*CommitHandler, error) {
+
+	return &CommitHandler{commits: commits, models: models, thumbnails: thumbnails}, nil
 }
 
-// method for getting an instance of ModelHandler
-func NewModelHandler() (*ModelHandler, error) {
+// method for getting an instance of AuthHandler. When registerOnLogin is true, logging in with an email no user
+// has creates the user.
+func NewAuthHandler(users database.UserStore, registerOnLogin bool) (*AuthHandler, error) {
+	return &AuthHandler{users: users, registerOnLogin: registerOnLogin}, nil
+}
 
-	return &ModelHandler{}, nil
+func NewLintHandler(lintConfigs database.LintStore) (*LintHandler, error) {
+	return &LintHandler{lintConfigs: lintConfigs}, nil
 }
 
-// method for getting an instance of CommitHandler
-func NewCommitHandler() (*CommitHandler, error) {
+// userKey is the key of the authenticated user in the context of a request.
+const userKey = "user"
+
+// RequireUser authenticates a request with HTTP basic auth, the email and password of a user, and makes the user
+// available to the handlers after it. Requests without valid credentials are rejected.
+func (h *AuthHandler) RequireUser(c *gin.Context) {
+	email, password, ok := c.Request.BasicAuth()
+	if !ok {
+		c.Header("WWW-Authenticate", `Basic realm="modelhub"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"Error": "the email and password of a user are required"})
+		return
+	}
+
+	status, user, err := h.users.UserLogin(email, password, false)
+	if err != nil {
+		c.AbortWithStatusJSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	c.Set(userKey, *user)
+	c.Next()
+}
 
-	return &CommitHandler{}, nil
+// authenticatedUser returns the user RequireUser authenticated the request as.
+func authenticatedUser(c *gin.Context) apiTypes.User {
+	return c.MustGet(userKey).(apiTypes.User)
 }
 
-func NewAuthHandler() (*AuthHandler, error) {
-	return &AuthHandler{}, nil
+// requireOwner checks that the authenticated user created the model with the given UUID, as only the owner of a
+// model may rewrite its history. Responds with an error and returns false otherwise.
+func (h *CommitHandler) requireOwner(c *gin.Context, uuid string) bool {
+	status, model, err := h.models.GetModelByUUID(uuid)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return false
+	}
+	if !strings.EqualFold(model.Meta.Creator.Email, authenticatedUser(c).Email) {
+		c.JSON(http.StatusForbidden, gin.H{"Error": "only the creator of a model can rewrite its history"})
+		return false
+	}
+	return true
 }
 
 // GetModels godoc
 // @Summary      Get all models
 // @Description  gets all models
 // @Tags         models
-// @Produce      json
+// @Produce      json,yaml
 // @Success      200
 // @Failure      500
 // @Router       /v0/models/ [get]
 func (h *ModelHandler) GetModels(c *gin.Context) {
 	var models []apiTypes.CausalDecisionModel
-	status, models, err := database.GetAllModels()
+	status, models, err := h.models.GetAllModels()
 	if models == nil {
 		c.JSON(status, gin.H{"Error": err.Error()})
 	}
 
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.IndentedJSON(status, models)
+	respond(c, status, models)
+}
+
+// yamlMIMETypes are the media types models can be sent and received as YAML with.
+var yamlMIMETypes = []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"}
+
+// isYAML reports whether a media type, without parameters, is one of yamlMIMETypes.
+func isYAML(mime string) bool {
+	for _, yamlMIME := range yamlMIMETypes {
+		if strings.EqualFold(mime, yamlMIME) {
+			return true
+		}
+	}
+	return false
+}
+
+// respond writes a successful response in the format the Accept header of the request asks for: YAML for any of
+// yamlMIMETypes, compact JSON for application/json and indented JSON otherwise, so browsers and tools that
+// accept anything keep getting readable output.
+func respond(c *gin.Context, status int, obj any) {
+	c.Header("Vary", "Accept")
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		mime := strings.TrimSpace(strings.Split(accepted, ";")[0])
+		if isYAML(mime) {
+			body, err := json.Marshal(obj)
+			if err == nil {
+				body, err = codec.JSONToYAML(body)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+				return
+			}
+			c.Data(status, "application/yaml; charset=utf-8", body)
+			return
+		}
+		if strings.EqualFold(mime, binding.MIMEJSON) {
+			c.JSON(status, obj)
+			return
+		}
+	}
+	c.IndentedJSON(status, obj)
+}
+
+// readModelBody reads the body of the request as JSON, converting it first if its Content-Type is YAML.
+// If that fails, it writes the error response and returns false.
+func readModelBody(c *gin.Context) ([]byte, bool) {
+	raw, err := c.GetRawData()
+	if err == nil && isYAML(c.ContentType()) {
+		raw, err = codec.YAMLToJSON(raw)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return nil, false
+	}
+	return raw, true
+}
+
+// bindModel validates the JSON or YAML body of the request against the bundled CDM JSON schema named by
+// its $schema field and binds it to the given model. If either fails, it writes the error
+// response, listing each schema violation with a JSON pointer, and returns false.
+func bindModel(c *gin.Context, model *apiTypes.CausalDecisionModel) bool {
+	raw, ok := readModelBody(c)
+	if !ok || !checkSchema(c, raw) {
+		return false
+	}
+
+	if err := binding.JSON.BindBody(raw, model); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+// checkSchema validates the raw JSON of a model against the schema named by its $schema field, writing the error
+// response, listing each schema violation with a JSON pointer, and returning false if it doesn't match.
+func checkSchema(c *gin.Context, raw []byte) bool {
+	issues, err := validation.ValidateSchema(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return false
+	}
+	if len(issues) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"Error":  "model does not match its schema",
+			"Issues": issues,
+		})
+		return false
+	}
+
+	return true
+}
+
+// checkReferences validates the references between the components of a model, writing the error
+// response and returning false if any of them don't resolve.
+func checkReferences(c *gin.Context, model *apiTypes.CausalDecisionModel) bool {
+	if issues := validation.ValidateReferences(model); len(issues) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"Error":  "model has unresolved or duplicate references",
+			"Issues": issues,
+		})
+		return false
+	}
+
+	return true
+}
+
+// ValidateModel godoc
+// @Summary      Validate a model without saving it
+// @Description  checks a model against its schema and the references between its components, and reports every issue found
+// @Tags         models
+// @Accept       json,yaml
+// @Produce      json,yaml
+// @Param        model  body  apiTypes.CausalDecisionModel  true  "Causal Decision Model Payload"
+// @Success      200 {object} apiTypes.ValidationReport "Validation report"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Router       /v0/models/validate [post]
+func (h *ModelHandler) ValidateModel(c *gin.Context) {
+	raw, ok := readModelBody(c)
+	if !ok {
+		return
+	}
+
+	issues, err := validation.ValidateSchema(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	// References can only be checked once the model binds, which a model breaking its schema may not.
+	var model apiTypes.CausalDecisionModel
+	if err := binding.JSON.BindBody(raw, &model); err != nil {
+		if len(issues) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+			return
+		}
+	} else {
+		issues = append(issues, validation.ValidateReferences(&model)...)
+	}
+
+	respond(c, http.StatusOK, apiTypes.ValidationReport{
+		Valid:  len(issues) == 0,
+		Issues: issues,
+	})
 }
 
 // UploadModel godoc
 // @Summary      Upload a new model
 // @Description  Given a body of a model with a creator with an email that corresponds to a user in the database, creates the model.
+// @Description  A DMN 1.x file sent as application/xml is imported instead, with its creator given by the email query parameter.
 // @Tags         models
-// @Accept       json
-// @Produce      json
+// @Accept       json,yaml,xml
+// @Produce      json,yaml
 // @Param        model  body  apiTypes.CausalDecisionModel  true  "Causal Decision Model Payload"
+// @Param        email query string false "Email of the creator, required when importing DMN"
 // @Success      201 {object} apiTypes.CausalDecisionModel "Created model"
 // @Failure      400 {object} gin.H "Bad Request"
 // @Failure      409 {object} gin.H "Conflict: Model with same UUID already exists"
+// @Failure      422 {object} gin.H "Model does not match its schema"
 // @Failure      500 {object} gin.H "Internal Server Error"
 // @Router       /v0/models/ [post]
 func (h *ModelHandler) UploadModel(c *gin.Context) {
+	// DMN files are imported rather than bound.
+	if contentType := c.ContentType(); contentType == "application/xml" || contentType == "text/xml" {
+		h.uploadDMN(c)
+		return
+	}
+
 	var uploadedModel apiTypes.CausalDecisionModel
 
-	// Bind the JSON payload to the uploaded model struct
-	if err := c.ShouldBindJSON(&uploadedModel); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	// Bind the JSON payload to the uploaded model struct and check it against its schema
+	if !bindModel(c, &uploadedModel) || !checkReferences(c, &uploadedModel) {
 		return
 	}
 
 	// Call the encapsulated CreateModel method from the database package
-	if status, err := database.CreateModelGivenEmail(&uploadedModel); err != nil {
+	if status, err := h.models.CreateModelGivenEmail(&uploadedModel); err != nil {
 		// Return error based on the CreateModel function response
 		c.JSON(status, gin.H{"Error": err.Error()})
 		return
 	}
 
 	// Return a successful response if model creation is successful
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.JSON(http.StatusCreated, uploadedModel)
+	respond(c, http.StatusCreated, uploadedModel)
+}
+
+// uploadDMN imports a DMN file from the request body and creates a model from it.
+func (h *ModelHandler) uploadDMN(c *gin.Context) {
+	email := c.Query("email")
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "the email of the creator is required to import DMN"})
+		return
+	}
+	if c.Request.Body == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "cannot read nil body"})
+		return
+	}
+
+	model, err := dmn.Import(c.Request.Body, apiTypes.User{Email: email}, h.models.NewMetaUUID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	model.Schema = validation.DefaultSchemaID
+
+	// The imported model is checked like an uploaded one before it is saved.
+	raw, err := json.Marshal(model)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	if !checkSchema(c, raw) || !checkReferences(c, model) {
+		return
+	}
+
+	if status, err := h.models.CreateModelGivenEmail(model); err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	respond(c, http.StatusCreated, model)
+}
+
+// ndjsonMIMETypes are the media types of newline delimited JSON streams of models.
+var ndjsonMIMETypes = []string{"application/x-ndjson", "application/ndjson", "application/jsonl"}
+
+// bulkItems splits the body of a bulk import into the JSON of each model: the lines of an NDJSON stream, or
+// the elements of a JSON array otherwise. If the body can't be split, it writes the error response and returns false.
+func bulkItems(c *gin.Context) ([]json.RawMessage, bool) {
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return nil, false
+	}
+
+	var items []json.RawMessage
+	if slices.Contains(ndjsonMIMETypes, c.ContentType()) {
+		// A line that isn't JSON only fails its own model.
+		for _, line := range bytes.Split(raw, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				items = append(items, line)
+			}
+		}
+	} else if err := json.Unmarshal(raw, &items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "body must be a JSON array of models or an NDJSON stream: " + err.Error()})
+		return nil, false
+	}
+
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "no models to import"})
+		return nil, false
+	}
+	return items, true
+}
+
+// BulkImportModels godoc
+// @Summary      Import many models at once
+// @Description  creates every model of a JSON array or NDJSON stream and reports the outcome of each. Each model is checked like an upload, and a parentUUID naming the uuid of another model in the batch is pointed at the UUID that model is created with.
+// @Description  In an atomic import, every model is created in a single transaction, so none are created if any of them fail.
+// @Tags         models
+// @Accept       json,application/x-ndjson
+// @Produce      json
+// @Param        models body []apiTypes.CausalDecisionModel true "Models to import"
+// @Param        atomic query bool false "Create either every model or none of them"
+// @Param        provisionCreators query bool false "Create creators that don't have an account yet instead of rejecting their models"
+// @Success      201 {object} apiTypes.BulkImport "Every model was created"
+// @Success      207 {object} apiTypes.BulkImport "Some models were created"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Failure      422 {object} apiTypes.BulkImport "No models were created"
+// @Router       /v0/models/bulk [post]
+func (h *ModelHandler) BulkImportModels(c *gin.Context) {
+	var options database.BulkImportOptions
+	for name, option := range map[string]*bool{"atomic": &options.Atomic, "provisionCreators": &options.ProvisionCreators} {
+		value, err := strconv.ParseBool(c.DefaultQuery(name, "false"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": fmt.Sprintf("%s must be true or false", name)})
+			return
+		}
+		*option = value
+	}
+
+	items, ok := bulkItems(c)
+	if !ok {
+		return
+	}
+
+	models := make([]apiTypes.CausalDecisionModel, len(items))
+	results := make([]apiTypes.BulkImportResult, len(items))
+	for i, item := range items {
+		issues, err := validation.ValidateSchema(item)
+		if err != nil {
+			results[i] = apiTypes.BulkImportResult{Status: http.StatusBadRequest, Error: err.Error()}
+			continue
+		}
+		if len(issues) > 0 {
+			results[i] = apiTypes.BulkImportResult{Status: http.StatusUnprocessableEntity, Error: "model does not match its schema", Issues: issues}
+			// Keep what can be read of the model, so its children in the batch know it failed.
+			json.Unmarshal(item, &models[i])
+			continue
+		}
+		if err := binding.JSON.BindBody(item, &models[i]); err != nil {
+			results[i] = apiTypes.BulkImportResult{Status: http.StatusBadRequest, Error: err.Error()}
+			continue
+		}
+		if issues := validation.ValidateReferences(&models[i]); len(issues) > 0 {
+			results[i] = apiTypes.BulkImportResult{Status: http.StatusUnprocessableEntity, Error: "model has unresolved or duplicate references", Issues: issues}
+		}
+	}
+
+	h.models.CreateModelsInBulk(models, results, options)
+
+	report := apiTypes.BulkImport{Atomic: options.Atomic, Results: results}
+	for _, result := range results {
+		if result.Error == "" {
+			report.Created++
+		} else {
+			report.Failed++
+		}
+	}
+
+	status := http.StatusMultiStatus
+	if report.Failed == 0 {
+		status = http.StatusCreated
+	} else if report.Created == 0 {
+		status = http.StatusUnprocessableEntity
+	}
+
+	c.IndentedJSON(status, report)
+}
+
+// ExportDMN godoc
+// @Summary      Export a model as DMN
+// @Description  writes the diagrams of a model as a DMN 1.3 decision requirements graph
+// @Tags         models
+// @Produce      xml
+// @Param        uuid path string true "Model UUID"
+// @Success      200 {string} string "DMN file"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/dmn [get]
+func (h *ModelHandler) ExportDMN(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	status, model, err := h.models.GetModelByUUID(uuid)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	body, err := dmn.Export(*model)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", uuid+".dmn"))
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
+
+// ExportModelBundle godoc
+// @Summary      Export a model bundle
+// @Description  archives a model with its full commit history, tags, lineage and the users that worked on it, along with a manifest of checksums, so it can be imported into another hub instance
+// @Tags         models
+// @Produce      application/gzip,application/zip
+// @Param        uuid path string true "Model UUID"
+// @Param        format query string false "tar.gz or zip, tar.gz by default"
+// @Success      200 {file} file "Model bundle"
+// @Failure      400 {object} gin.H "Bad Request: Unknown format"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/bundle [get]
+func (h *ModelHandler) ExportModelBundle(c *gin.Context) {
+	format, err := bundle.ParseFormat(c.DefaultQuery("format", string(bundle.FormatTarGz)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	uuid := c.Param("uuid")
+	status, archive, err := h.models.ExportModelArchive(uuid)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	var body bytes.Buffer
+	if err := bundle.New(*archive, time.Now()).Write(&body, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", uuid+"."+format.Extension()))
+	c.Data(http.StatusOK, format.ContentType(), body.Bytes())
+}
+
+// ImportModelBundle godoc
+// @Summary      Import a model bundle
+// @Description  creates a model from a bundle exported by another hub instance, keeping its UUIDs and version numbers. Users are matched by email and created if they don't exist. The hub doesn't store attachments, so any in the bundle are reported as skipped.
+// @Tags         models
+// @Accept       application/gzip,application/zip
+// @Produce      json
+// @Param        bundle body string true "Model bundle"
+// @Success      201 {object} apiTypes.BundleImport "Imported model"
+// @Failure      400 {object} gin.H "Bad Request: Invalid bundle"
+// @Failure      409 {object} gin.H "Conflict: Model already exists"
+// @Failure      422 {object} gin.H "Model does not match its schema, or its history does not apply"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/bundle [post]
+func (h *ModelHandler) ImportModelBundle(c *gin.Context) {
+	if c.Request.Body == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "cannot read nil body"})
+		return
+	}
+
+	read, err := bundle.Read(http.MaxBytesReader(c.Writer, c.Request.Body, bundle.MaxExtractedSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	// The model of the bundle is checked like an uploaded one, as another hub instance may not have checked it.
+	raw, err := json.Marshal(read.Archive.Model)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	if !checkSchema(c, raw) || !checkReferences(c, &read.Archive.Model) {
+		return
+	}
+
+	status, model, err := h.models.ImportModelArchive(&read.Archive)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	skipped := []string{}
+	for name := range read.Attachments {
+		skipped = append(skipped, name)
+	}
+	sort.Strings(skipped)
+
+	c.IndentedJSON(http.StatusCreated, apiTypes.BundleImport{
+		Model:              model,
+		Commits:            len(read.Archive.Commits),
+		SkippedAttachments: skipped,
+	})
 }
 
 // GetModelByUUID godoc
@@ -102,16 +594,30 @@ func (h *ModelHandler) UploadModel(c *gin.Context) {
 // @Description  gets models using its uuid
 // @Tags         models
 // @Accept       json
-// @Produce      json
+// @Produce      json,yaml
 // @Param        uuid path string true "Model UUID"
+// @Param        asOf query string false "RFC 3339 timestamp to reconstruct the model at"
 // @Success      200
+// @Failure      400 {object} gin.H "Bad Request"
 // @Failure      404 {object} gin.H "Model not found"
 // @Router       /v0/models/{uuid} [get]
 func (h *ModelHandler) GetModelByUUID(c *gin.Context) {
 	uuid := c.Param("uuid")
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
 
-	// Call the encapsulated GetModelByUUID function from the database package
-	status, model, err := database.GetModelByUUID(uuid)
+	var status int
+	var model *apiTypes.CausalDecisionModel
+	if asOf != nil {
+		// Reconstruct the model as it was at the requested time
+		status, model, err = h.models.GetModelByUUIDAsOf(uuid, *asOf)
+	} else {
+		// Call the encapsulated GetModelByUUID function from the database package
+		status, model, err = h.models.GetModelByUUID(uuid)
+	}
 	if err != nil {
 		// If error, return an appropriate response based on the error
 		c.JSON(status, gin.H{"Error": err.Error()})
@@ -119,32 +625,32 @@ func (h *ModelHandler) GetModelByUUID(c *gin.Context) {
 	}
 
 	// Return the model if found
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.IndentedJSON(status, model)
+	respond(c, status, model)
 }
 
 // putModel godoc
 // @Summary      Update model
 // @Description  Updates a causal decision model along with its metadata in a single transaction.
 // @Tags         models
-// @Accept       json
-// @Produce      json
+// @Accept       json,yaml
+// @Produce      json,yaml
 // @Param        model  body  apiTypes.CausalDecisionModel  true  "Causal Decision Model Payload"
+// @Param        message query string false "Message describing the change, recorded on the commit"
 // @Success      201 {object} apiTypes.CausalDecisionModel "Updated model"
 // @Failure      400 {object} gin.H "Bad Request"
+// @Failure      422 {object} gin.H "Model does not match its schema"
 // @Failure      500 {object} gin.H "Internal Server Error"
 // @Router       /v0/models/ [put]
 func (h *ModelHandler) PutModel(c *gin.Context) {
 
 	var uploadedModel apiTypes.CausalDecisionModel
 
-	// Bind the JSON payload to the uploaded model struct
-	if err := c.ShouldBindJSON(&uploadedModel); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+	// Bind the JSON payload to the uploaded model struct and check it against its schema
+	if !bindModel(c, &uploadedModel) {
 		return
 	}
 	//if we can't find the model with the given UUID, return error.
-	status, oldmodel, err := database.GetModelByUUID(uploadedModel.Meta.UUID)
+	status, oldmodel, err := h.models.GetModelByUUID(uploadedModel.Meta.UUID)
 
 	if err != nil {
 		// Return error based on the UpdateModel function response
@@ -152,15 +658,18 @@ func (h *ModelHandler) PutModel(c *gin.Context) {
 		return
 	}
 
-	changedModel, status, err := database.UpdateModelAndCreateCommit(&uploadedModel, oldmodel)
+	if !checkReferences(c, &uploadedModel) {
+		return
+	}
+
+	changedModel, status, err := h.models.UpdateModelAndCreateCommitWithMessage(&uploadedModel, oldmodel, c.Query("message"))
 	if err != nil {
 		// Return error based on the UpdateModel function response
 		c.JSON(status, gin.H{"Error": err.Error()})
 		return
 	}
 	// Return a successful response if model put is
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.IndentedJSON(http.StatusCreated, changedModel)
+	respond(c, http.StatusCreated, changedModel)
 }
 
 // GetCommits godoc
@@ -174,12 +683,11 @@ func (h *ModelHandler) PutModel(c *gin.Context) {
 func (h *CommitHandler) GetCommits(c *gin.Context) {
 	//TODO remove this API. No real need for it.
 	var models []apiTypes.Commit
-	status, models, err := database.GetAllCommits()
+	status, models, err := h.commits.GetAllCommits()
 	if models == nil {
 		c.JSON(status, gin.H{"Error": err.Error()})
 	}
 
-	c.Header("Access-Control-Allow-Origin", "*")
 	c.IndentedJSON(status, models)
 }
 
@@ -187,7 +695,7 @@ func (h *CommitHandler) GetLatestCommitByModelUUID(c *gin.Context) {
 	uuid := c.Param("uuid")
 
 	// Call the encapsulated GetModelByUUID function from the database package
-	status, commit, err := database.GetLatestCommitForModelUUID(uuid)
+	status, commit, err := h.commits.GetLatestCommitForModelUUID(uuid)
 	if err != nil {
 		// If error, return an appropriate response based on the error
 		c.JSON(status, gin.H{"Error": err.Error()})
@@ -195,7 +703,6 @@ func (h *CommitHandler) GetLatestCommitByModelUUID(c *gin.Context) {
 	}
 
 	// Return the commit if found
-	c.Header("Access-Control-Allow-Origin", "*")
 	c.IndentedJSON(status, commit)
 }
 
@@ -206,7 +713,7 @@ func (h *CommitHandler) GetLatestCommitByModelUUID(c *gin.Context) {
 // @Description  gets models using its uuid
 // @Tags         models
 // @Accept       json
-// @Produce      json
+// @Produce      json,yaml
 // @Param        uuid path string true "Model UUID"
 // @Param        version path string true "Model Version"
 // @Success      200
@@ -221,88 +728,228 @@ func (h *ModelHandler) GetVersionOfModel(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
 		return
 	}
-	//get latest version of model.
-	_, latestVersionOfModel, err := database.GetModelByUUID(uuid)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"Error": err.Error()})
-		return
-	}
-	//get latest commit for model UUID.
-	status, commit, err := database.GetLatestCommitForModelUUID(uuid)
-	if version == 0 && status == http.StatusNotFound {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.IndentedJSON(http.StatusOK, latestVersionOfModel)
-		return
-	}
+
+	// Reconstruct the model from the latest version and the commit diffs.
+	status, model, err := h.models.GetVersionOfModel(uuid, version)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-		return
-	}
-	//if the version requested is the latest version of the model, just return.
-	if version == commit.Version {
-		c.JSON(http.StatusOK, latestVersionOfModel)
-		return
-	}
-	//if the version requested is greater than the latest version of the model, return error.
-	if version > commit.Version {
-		c.JSON(http.StatusConflict, gin.H{"Error": "Version requested is greater than the latest version"})
-		return
-	}
-	if version < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"Error": "Version requested is less than 0"})
+		c.JSON(status, gin.H{"Error": err.Error()})
 		return
 	}
 
-	currVersion := commit.Version
-	currCommit := commit
+	respond(c, http.StatusOK, model)
+
+}
 
-	currModelBytes, err := json.Marshal(latestVersionOfModel)
+// GetModelBlame godoc
+// @Summary      Get blame for a model
+// @Description  attributes each diagram, element and causal dependency of a model to the commits that introduced and last changed it
+// @Tags         models
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Success      200 {object} apiTypes.ModelBlame "Blame for the model"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/blame [get]
+func (h *ModelHandler) GetModelBlame(c *gin.Context) {
+	uuid := c.Param("uuid")
 
+	status, blame, err := h.models.GetModelBlame(uuid)
 	if err != nil {
-		// Return error based on the UpdateModel function response
-		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		c.JSON(status, gin.H{"Error": err.Error()})
 		return
 	}
 
-	// loop through the commits until we reach the version we want.
-	// we need to apply the diff to the model in reverse order, so we start with the latest commit and go backwards.
-	for {
-		diff := []byte(currCommit.Diff)
-		modified, err := jsonDiffHelpers.ApplyInvertedPatch(currModelBytes, diff)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
-			return
+	c.IndentedJSON(status, blame)
+}
+
+// GetModelChangelog godoc
+// @Summary      Get changelog between two versions of a model
+// @Description  lists the commit messages, authors and the diagrams, elements and dependencies added, removed and changed between two versions of a model
+// @Tags         models
+// @Produce      json
+// @Produce      text/markdown
+// @Param        uuid path string true "Model UUID"
+// @Param        from query string true "Version to start from"
+// @Param        to query string true "Version to end at"
+// @Param        format query string false "json (default) or markdown"
+// @Success      200 {object} apiTypes.Changelog "Changelog"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      409 {object} gin.H "Conflict: Version out of range"
+// @Router       /v0/models/{uuid}/changelog [get]
+func (h *ModelHandler) GetModelChangelog(c *gin.Context) {
+	uuid := c.Param("uuid")
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	status, changelog, err := h.models.GetModelChangelog(uuid, from, to)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.IndentedJSON(status, changelog)
+	case "markdown", "md":
+		c.Data(status, "text/markdown; charset=utf-8", []byte(changelogMarkdown(changelog)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "format must be json or markdown"})
+	}
+}
+
+// changelogMarkdown renders a changelog as human readable release notes.
+func changelogMarkdown(changelog *apiTypes.Changelog) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# Changelog for %s: version %d to %d\n", changelog.CDMUUID, changelog.FromVersion, changelog.ToVersion)
+
+	writeChanges := func(heading string, changes []apiTypes.ComponentChange) {
+		if len(changes) == 0 {
+			return
 		}
+		fmt.Fprintf(&sb, "\n%s\n\n", heading)
+		for _, change := range changes {
+			name := change.Name
+			if name == "" {
+				name = change.UUID
+			}
+			fmt.Fprintf(&sb, "- %s **%s** (`%s`)\n", change.Type, name, change.UUID)
+		}
+	}
 
-		//reset variables for next iteration of applying patches
-		currModelBytes = modified
-		currVersion--
-		parentIdStr := currCommit.ParentCommitID
+	sb.WriteString("\n## Summary\n")
+	if len(changelog.Added) == 0 && len(changelog.Removed) == 0 && len(changelog.Changed) == 0 {
+		sb.WriteString("\nNo changes.\n")
+	}
+	writeChanges("### Added", changelog.Added)
+	writeChanges("### Removed", changelog.Removed)
+	writeChanges("### Changed", changelog.Changed)
 
-		//if we reach the version we want, return the model
-		if currVersion <= version {
-			break
+	for _, entry := range changelog.Entries {
+		fmt.Fprintf(&sb, "\n## Version %d", entry.Version)
+		if entry.Tag != "" {
+			fmt.Fprintf(&sb, " (%s)", entry.Tag)
 		}
+		author := entry.Username
+		if author == "" {
+			author = entry.UserUUID
+		}
+		fmt.Fprintf(&sb, "\n\n%s by %s\n", entry.Date.Format("2006-01-02 15:04"), author)
+		if entry.Message != "" {
+			fmt.Fprintf(&sb, "\n%s\n", entry.Message)
+		}
+		writeChanges("#### Added", entry.Added)
+		writeChanges("#### Removed", entry.Removed)
+		writeChanges("#### Changed", entry.Changed)
+	}
+
+	return sb.String()
+}
+
+// ForkModel godoc
+// @Summary      Fork a model
+// @Description  deep copies a model into a new child model with new UUIDs for the model, diagrams, elements and dependencies, recording the parent version it was forked from
+// @Tags         models
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        version query string false "Version of the model to fork, defaults to the latest"
+// @Param        email query string false "Email of the creator of the fork, defaults to the creator of the model"
+// @Param        name query string false "Name of the fork, defaults to the name of the model"
+// @Success      201 {object} apiTypes.CausalDecisionModel "Forked model"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      409 {object} gin.H "Conflict: Creator not found or version out of range"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/fork [post]
+func (h *ModelHandler) ForkModel(c *gin.Context) {
+	uuid := c.Param("uuid")
 
-		if parentIdStr == "" {
-			c.JSON(http.StatusInternalServerError, "No parent ID") //if we encounter a null parent id, return error.
+	var version *int
+	if strVersion, ok := c.GetQuery("version"); ok {
+		parsedVersion, err := strconv.Atoi(strVersion)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
 			return
 		}
+		version = &parsedVersion
+	}
+
+	status, fork, err := h.models.ForkModel(uuid, version, c.Query("email"), c.Query("name"))
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
 
-		parentId, _ := strconv.ParseInt(parentIdStr, 10, 64)
+	c.IndentedJSON(http.StatusCreated, fork)
+}
 
-		_, currCommit, _ = database.GetCommitByID(int(parentId))
+// GetUpstreamStatus godoc
+// @Summary      Compare a forked model with its parent
+// @Description  counts the commits the model is ahead of and behind its parent since the fork point
+// @Tags         models
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Success      200 {object} apiTypes.UpstreamStatus "Upstream status"
+// @Failure      400 {object} gin.H "Bad Request: Model was not forked"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/upstream [get]
+func (h *ModelHandler) GetUpstreamStatus(c *gin.Context) {
+	uuid := c.Param("uuid")
 
+	status, upstream, err := h.models.GetUpstreamStatus(uuid)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
 	}
 
-	finalModel := apiTypes.CausalDecisionModel{}
-	if err := json.Unmarshal(currModelBytes, &finalModel); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+	c.IndentedJSON(status, upstream)
+}
+
+// SyncWithParent godoc
+// @Summary      Pull parent changes into a forked model
+// @Description  applies the changes made to the parent since the fork point to diagrams, elements and dependencies the model has not also changed, and reports the rest as conflicts
+// @Tags         models
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Success      200 {object} apiTypes.SyncResult "Applied changes and conflicts"
+// @Failure      400 {object} gin.H "Bad Request: Model was not forked"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/upstream/sync [post]
+func (h *ModelHandler) SyncWithParent(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	status, result, err := h.models.SyncWithParent(uuid)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
 		return
 	}
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.IndentedJSON(http.StatusOK, finalModel)
 
+	c.IndentedJSON(status, result)
+}
+
+// parseAsOf reads the optional asOf query parameter as an RFC 3339 timestamp.
+// Returns nil if the parameter was not given.
+func parseAsOf(c *gin.Context) (*time.Time, error) {
+	strAsOf, ok := c.GetQuery("asOf")
+	if !ok {
+		return nil, nil
+	}
+	asOf, err := time.Parse(time.RFC3339, strAsOf)
+	if err != nil {
+		return nil, fmt.Errorf("asOf must be an RFC 3339 timestamp: %s", err.Error())
+	}
+	return &asOf, nil
 }
 
 /* //ERIC - we only needed this for testing.
@@ -351,11 +998,12 @@ func (h *CommitHandler) UploadCommit(c *gin.Context) {
 // @Failure      500 {object} gin.H "Internal Server Error"
 // @Router       /login [put]
 func (h *AuthHandler) UserLogin(c *gin.Context) {
-	//For now, whenever a user logs in, even if the user doesn't exist we just create a new user and log them in.
+	//Unless registration on login is turned off, whenever a user logs in, even if the user doesn't exist we just
+	//create a new user and log them in.
 	email := c.Query("email")
 	pass := c.Query("password")
 
-	status, user, err := database.UserLogin(email, pass)
+	status, user, err := h.users.UserLogin(email, pass, h.registerOnLogin)
 
 	if err != nil {
 		c.JSON(status, gin.H{"Error": err.Error()})
@@ -364,7 +1012,6 @@ func (h *AuthHandler) UserLogin(c *gin.Context) {
 	user.Password = "secret"
 
 	// Return the user
-	c.Header("Access-Control-Allow-Origin", "*")
 	c.IndentedJSON(status, user)
 }
 
@@ -375,18 +1022,31 @@ func (h *AuthHandler) UserLogin(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        uuid path string true "Model UUID"
+// @Param        asOf query string false "RFC 3339 timestamp to reconstruct the lineage at"
 // @Success      200
+// @Failure      400 {object} gin.H "Bad Request"
 // @Failure      404 {object} gin.H "Model not found"
 // @Router       /v0/models/lineage/{uuid} [get]
 
 func (h *ModelHandler) GetModelLineage(c *gin.Context) {
 	uuid := c.Param("uuid")
-	status, lineage, err := database.GetModelLineage(uuid)
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	var status int
+	var lineage []apiTypes.CausalDecisionModel
+	if asOf != nil {
+		status, lineage, err = h.models.GetModelLineageAsOf(uuid, *asOf)
+	} else {
+		status, lineage, err = h.models.GetModelLineage(uuid)
+	}
 	if err != nil {
 		c.JSON(status, gin.H{"Error": err.Error()})
 		return
 	}
-	c.Header("Access-Control-Allow-Origin", "*")
 	c.IndentedJSON(status, lineage)
 }
 
@@ -397,26 +1057,419 @@ func (h *ModelHandler) GetModelLineage(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        uuid path string true "Model UUID"
+// @Param        asOf query string false "RFC 3339 timestamp to reconstruct the children at"
 // @Success      200
+// @Failure      400 {object} gin.H "Bad Request"
 // @Failure      404 {object} gin.H "Model not found"
 // @Router       /v0/models/children/{uuid} [get]
 func (h *ModelHandler) GetModelChildren(c *gin.Context) {
 	uuid := c.Param("uuid")
-	status, children, err := database.GetModelChildren(uuid)
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	var status int
+	var children []apiTypes.CausalDecisionModel
+	if asOf != nil {
+		status, children, err = h.models.GetModelChildrenAsOf(uuid, *asOf)
+	} else {
+		status, children, err = h.models.GetModelChildren(uuid)
+	}
 	if err != nil {
 		c.JSON(status, gin.H{"Error": err.Error()})
 		return
 	}
-	c.Header("Access-Control-Allow-Origin", "*")
 	c.IndentedJSON(status, children)
 }
 
+// GetModelFamily godoc
+// @Summary      Get model family
+// @Description  gets the ancestors of a model and the tree of its descendants as lightweight summaries
+// @Tags         models
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        depth query int false "How many generations of descendants to include, all of them if omitted"
+// @Success      200 {object} apiTypes.ModelFamily "Model family"
+// @Failure      400 {object} gin.H "Bad Request: Invalid depth"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/family [get]
+func (h *ModelHandler) GetModelFamily(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	depth := -1
+	if strDepth := c.Query("depth"); strDepth != "" {
+		parsedDepth, err := strconv.Atoi(strDepth)
+		if err != nil || parsedDepth < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": "depth must be a non-negative integer"})
+			return
+		}
+		depth = parsedDepth
+	}
+
+	status, family, err := h.models.GetModelFamily(uuid, depth)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(status, family)
+}
+
+// LintModel godoc
+// @Summary      Lint a model
+// @Description  runs the lint rules over a model and reports the issues found, using the rule configuration of the organization that created it unless another organization is given
+// @Tags         models
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        organization query string false "Organization whose rule configuration to use"
+// @Success      200 {object} apiTypes.LintReport "Lint report"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/lint [get]
+func (h *ModelHandler) LintModel(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	status, model, err := h.models.GetModelByUUID(uuid)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	organization := strings.ToLower(c.Query("organization"))
+	if organization == "" {
+		organization = organizationOf(model.Meta.Creator.Email)
+	}
+
+	status, config, err := lintConfigOf(h.lintConfigs, organization)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	issues := lint.Lint(model, config)
+	counts := map[string]int{}
+	for _, issue := range issues {
+		counts[issue.Severity]++
+	}
+
+	c.IndentedJSON(http.StatusOK, apiTypes.LintReport{
+		CDMUUID:      uuid,
+		Organization: organization,
+		Counts:       counts,
+		Issues:       issues,
+	})
+}
+
+// diagramGraph builds the graph of the diagram named in the request. If the model or diagram
+// doesn't exist, it writes the error response and returns nil.
+func (h *ModelHandler) diagramGraph(c *gin.Context) *graph.Graph {
+	status, model, err := h.models.GetModelByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return nil
+	}
+
+	diagramUUID := c.Param("diagramUUID")
+	for _, diagram := range model.Diagrams {
+		if diagram.Meta.UUID == diagramUUID {
+			return graph.New(diagram)
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"Error": fmt.Sprintf("diagram with uuid %s not found in this model", diagramUUID)})
+	return nil
+}
+
+// GetTopologicalOrder godoc
+// @Summary      Get the topological order of a diagram
+// @Description  orders the elements of a diagram so that every dependency goes from an earlier element to a later one
+// @Tags         graph
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        diagramUUID path string true "Diagram UUID"
+// @Success      200 {object} []string "Element UUIDs in topological order"
+// @Failure      404 {object} gin.H "Model or diagram not found"
+// @Failure      409 {object} gin.H "Conflict: The dependencies form a cycle"
+// @Router       /v0/models/{uuid}/diagrams/{diagramUUID}/graph/topological-order [get]
+func (h *ModelHandler) GetTopologicalOrder(c *gin.Context) {
+	g := h.diagramGraph(c)
+	if g == nil {
+		return
+	}
+
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"Error": err.Error(), "Cycles": g.Cycles()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, order)
+}
+
+// elementNeighbourhood answers the upstream and downstream queries, which only differ in the direction they search.
+func (h *ModelHandler) elementNeighbourhood(c *gin.Context, upstream bool) {
+	g := h.diagramGraph(c)
+	if g == nil {
+		return
+	}
+
+	elementUUID := c.Param("elementUUID")
+	if !g.HasNode(elementUUID) {
+		c.JSON(http.StatusNotFound, gin.H{"Error": fmt.Sprintf("element with uuid %s not found in this diagram", elementUUID)})
+		return
+	}
+
+	var elements []string
+	if upstream {
+		elements = g.Upstream(elementUUID)
+	} else {
+		elements = g.Downstream(elementUUID)
+	}
+
+	c.IndentedJSON(http.StatusOK, elements)
+}
+
+// GetUpstreamElements godoc
+// @Summary      Get the upstream elements of an element
+// @Description  gets every element of the diagram that the element can be reached from by following dependencies
+// @Tags         graph
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        diagramUUID path string true "Diagram UUID"
+// @Param        elementUUID path string true "Element UUID"
+// @Success      200 {object} []string "Upstream element UUIDs"
+// @Failure      404 {object} gin.H "Model, diagram or element not found"
+// @Router       /v0/models/{uuid}/diagrams/{diagramUUID}/graph/elements/{elementUUID}/upstream [get]
+func (h *ModelHandler) GetUpstreamElements(c *gin.Context) {
+	h.elementNeighbourhood(c, true)
+}
+
+// GetDownstreamElements godoc
+// @Summary      Get the downstream elements of an element
+// @Description  gets every element of the diagram that can be reached from the element by following dependencies
+// @Tags         graph
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        diagramUUID path string true "Diagram UUID"
+// @Param        elementUUID path string true "Element UUID"
+// @Success      200 {object} []string "Downstream element UUIDs"
+// @Failure      404 {object} gin.H "Model, diagram or element not found"
+// @Router       /v0/models/{uuid}/diagrams/{diagramUUID}/graph/elements/{elementUUID}/downstream [get]
+func (h *ModelHandler) GetDownstreamElements(c *gin.Context) {
+	h.elementNeighbourhood(c, false)
+}
+
+// GetElementPaths godoc
+// @Summary      Get the paths between two elements
+// @Description  gets the paths of dependencies from one element to another, such as from a lever to an outcome, that don't visit any element twice
+// @Tags         graph
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        diagramUUID path string true "Diagram UUID"
+// @Param        from query string true "UUID of the element the paths start at"
+// @Param        to query string true "UUID of the element the paths end at"
+// @Param        limit query int false "Maximum number of paths to return, 100 by default"
+// @Success      200 {object} [][]string "Paths as lists of element UUIDs"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Failure      404 {object} gin.H "Model, diagram or element not found"
+// @Failure      422 {object} gin.H "Unprocessable Entity: Too many paths to search"
+// @Router       /v0/models/{uuid}/diagrams/{diagramUUID}/graph/paths [get]
+func (h *ModelHandler) GetElementPaths(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "from and to are required"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "limit must be a positive integer"})
+		return
+	}
+
+	g := h.diagramGraph(c)
+	if g == nil {
+		return
+	}
+
+	for _, uuid := range []string{from, to} {
+		if !g.HasNode(uuid) {
+			c.JSON(http.StatusNotFound, gin.H{"Error": fmt.Sprintf("element with uuid %s not found in this diagram", uuid)})
+			return
+		}
+	}
+
+	paths, err := g.Paths(from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"Error": err.Error() + "; ask for fewer with limit"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, paths)
+}
+
+// GetStronglyConnectedComponents godoc
+// @Summary      Get the strongly connected components of a diagram
+// @Description  splits the elements of a diagram into groups in which every element can reach every other by following dependencies
+// @Tags         graph
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        diagramUUID path string true "Diagram UUID"
+// @Success      200 {object} [][]string "Components as lists of element UUIDs"
+// @Failure      404 {object} gin.H "Model or diagram not found"
+// @Router       /v0/models/{uuid}/diagrams/{diagramUUID}/graph/components [get]
+func (h *ModelHandler) GetStronglyConnectedComponents(c *gin.Context) {
+	g := h.diagramGraph(c)
+	if g == nil {
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, g.StronglyConnectedComponents())
+}
+
+// writeExport sends an exported diagram or model, named after the UUID it was exported from.
+func writeExport(c *gin.Context, uuid string, format export.Format, body []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", uuid+"."+format.Extension()))
+	c.Data(http.StatusOK, format.ContentType(), body)
+}
+
+// ExportDiagram godoc
+// @Summary      Export a diagram
+// @Description  renders the elements of a diagram as nodes and its dependencies as edges in Graphviz DOT, Mermaid or GraphML
+// @Tags         models
+// @Produce      plain
+// @Param        uuid path string true "Model UUID"
+// @Param        diagramUUID path string true "Diagram UUID"
+// @Param        format query string false "dot, mermaid or graphml, dot by default"
+// @Success      200 {string} string "Exported diagram"
+// @Failure      400 {object} gin.H "Bad Request: Unknown format"
+// @Failure      404 {object} gin.H "Model or diagram not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/diagrams/{diagramUUID}/export [get]
+func (h *ModelHandler) ExportDiagram(c *gin.Context) {
+	format, err := export.ParseFormat(c.DefaultQuery("format", string(export.FormatDOT)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	status, model, err := h.models.GetModelByUUID(c.Param("uuid"))
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	diagramUUID := c.Param("diagramUUID")
+	for _, diagram := range model.Diagrams {
+		if diagram.Meta.UUID != diagramUUID {
+			continue
+		}
+
+		body, err := export.Diagram(diagram, format)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+			return
+		}
+		writeExport(c, diagramUUID, format, body)
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"Error": fmt.Sprintf("diagram with uuid %s not found in this model", diagramUUID)})
+}
+
+// ExportModel godoc
+// @Summary      Export a model
+// @Description  renders every diagram of a model together in Graphviz DOT, Mermaid or GraphML, with each diagram as its own group
+// @Tags         models
+// @Produce      plain
+// @Param        uuid path string true "Model UUID"
+// @Param        format query string false "dot, mermaid or graphml, dot by default"
+// @Success      200 {string} string "Exported model"
+// @Failure      400 {object} gin.H "Bad Request: Unknown format"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/export [get]
+func (h *ModelHandler) ExportModel(c *gin.Context) {
+	format, err := export.ParseFormat(c.DefaultQuery("format", string(export.FormatDOT)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	uuid := c.Param("uuid")
+	status, model, err := h.models.GetModelByUUID(uuid)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	body, err := export.Model(*model, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	writeExport(c, uuid, format, body)
+}
+
+// GetModelThumbnail godoc
+// @Summary      Get a thumbnail of a model
+// @Description  draws the diagrams of a model as an SVG, placing elements where their content positions them or in layers by their dependencies otherwise. Thumbnails are cached until the model changes.
+// @Tags         models
+// @Produce      image/svg+xml
+// @Param        uuid path string true "Model UUID"
+// @Success      200 {string} string "SVG thumbnail"
+// @Success      304 "Not Modified"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/models/{uuid}/thumbnail.svg [get]
+func (h *ModelHandler) GetModelThumbnail(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	// Look the model up first, so a model that is gone isn't reported as unmodified.
+	status, model, err := h.models.GetModelByUUID(uuid)
+	if err != nil {
+		if status == http.StatusNotFound {
+			h.thumbnails.Delete(uuid)
+		}
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	// Thumbnails are cached per commit. The commit ID is part of the key since squashing history reuses version numbers.
+	version := "v0"
+	status, commit, err := h.commits.GetLatestCommitForModelUUID(uuid)
+	if err == nil {
+		version = fmt.Sprintf("v%d-%d", commit.Version, commit.ID)
+	} else if status != http.StatusNotFound {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	etag := fmt.Sprintf("%q", uuid+"-"+version)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	svg, ok := h.thumbnails.Get(uuid, version)
+	if !ok {
+		svg = thumbnail.Render(*model)
+		h.thumbnails.Put(uuid, version, svg)
+	}
+
+	c.Data(http.StatusOK, "image/svg+xml", svg)
+}
+
 // ModelSearch godoc
 // @Summary      Search for models
 // @Description  Search for models by name or user
 // @Tags         models
 // @Accept       json
-// @Produce      json
+// @Produce      json,yaml
 // @Param        type path string true "Search type (model or user)"
 // @Param        name path string true "Search name"
 // @Success      200 {object} []apiTypes.CausalDecisionModel "List of models"
@@ -427,21 +1480,19 @@ func (h *ModelHandler) ModelSearch(c *gin.Context) {
 	searchType := c.Param("type")
 	name := c.Param("name")
 	if searchType == "model" {
-		status, models, err := database.SearchModelsByName(name)
+		status, models, err := h.models.SearchModelsByName(name)
 		if err != nil {
 			c.JSON(status, gin.H{"Error": err.Error()})
 			return
 		}
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.IndentedJSON(status, models)
+		respond(c, status, models)
 	} else if searchType == "user" {
-		status, models, err := database.SearchModelsByUser(name)
+		status, models, err := h.models.SearchModelsByUser(name)
 		if err != nil {
 			c.JSON(status, gin.H{"Error": err.Error()})
 			return
 		}
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.IndentedJSON(status, models)
+		respond(c, status, models)
 	} else {
 		c.JSON(404, gin.H{"Error": "This type of search does not exist"})
 		return
@@ -461,7 +1512,7 @@ func (h *CommitHandler) GetCommitsByModelUUID(c *gin.Context) {
 	uuid := c.Param("uuid")
 
 	// Call the database function to get all commits for the model
-	status, commits, err := database.GetCommitsByModelUUID(uuid)
+	status, commits, err := h.commits.GetCommitsByModelUUID(uuid)
 	if err != nil {
 		// If error, return an appropriate response
 		c.JSON(status, gin.H{"Error": err.Error()})
@@ -469,6 +1520,236 @@ func (h *CommitHandler) GetCommitsByModelUUID(c *gin.Context) {
 	}
 
 	// Return the commits if found
-	c.Header("Access-Control-Allow-Origin", "*")
 	c.IndentedJSON(status, commits)
 }
+
+// TagCommit godoc
+// @Summary      Tag a version of a model
+// @Description  attaches a tag to the commit that produced a version of a model. Tagged versions are preserved when history is squashed or compacted.
+// @Tags         commits
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        version path string true "Model Version"
+// @Param        tag query string true "Tag"
+// @Success      200 {object} apiTypes.Commit "Tagged commit"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Failure      401 {object} gin.H "Unauthorized"
+// @Failure      403 {object} gin.H "Forbidden: Not the creator of the model"
+// @Failure      404 {object} gin.H "Commit not found"
+// @Failure      409 {object} gin.H "Conflict: Tag already used"
+// @Router       /v0/commits/model/{uuid}/{version}/tag [put]
+func (h *CommitHandler) TagCommit(c *gin.Context) {
+	uuid := c.Param("uuid")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	if !h.requireOwner(c, uuid) {
+		return
+	}
+
+	status, commit, err := h.commits.TagCommit(uuid, version, c.Query("tag"))
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(status, commit)
+}
+
+// SquashCommits godoc
+// @Summary      Squash commits of a model
+// @Description  replaces the commits that produced a range of versions of a model with a single commit, renumbering later versions
+// @Tags         commits
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        from query string true "First version to squash"
+// @Param        to query string true "Last version to squash"
+// @Success      200 {object} apiTypes.CompactionResult "Number of commits removed"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Failure      401 {object} gin.H "Unauthorized"
+// @Failure      403 {object} gin.H "Forbidden: Not the creator of the model"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      409 {object} gin.H "Conflict: Version out of range or tagged"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/commits/model/{uuid}/squash [post]
+func (h *CommitHandler) SquashCommits(c *gin.Context) {
+	uuid := c.Param("uuid")
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	if !h.requireOwner(c, uuid) {
+		return
+	}
+
+	status, removed, err := h.commits.SquashCommits(uuid, from, to)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+	h.thumbnails.Delete(uuid)
+
+	c.IndentedJSON(status, apiTypes.CompactionResult{CDMUUID: uuid, RemovedCommits: removed})
+}
+
+// parseOlderThan reads the olderThan query parameter as a duration (for example 720h) and
+// returns the time before which commits are considered old.
+func parseOlderThan(c *gin.Context) (time.Time, error) {
+	olderThan, err := time.ParseDuration(c.Query("olderThan"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("olderThan must be a duration such as 720h: %s", err.Error())
+	}
+	if olderThan < 0 {
+		return time.Time{}, fmt.Errorf("olderThan must not be negative")
+	}
+	return time.Now().Add(-olderThan), nil
+}
+
+// CompactModelHistory godoc
+// @Summary      Compact the history of a model
+// @Description  squashes the commits of a model older than a threshold into snapshots, preserving tagged versions
+// @Tags         commits
+// @Produce      json
+// @Param        uuid path string true "Model UUID"
+// @Param        olderThan query string true "Age of the commits to compact, such as 720h"
+// @Success      200 {object} apiTypes.CompactionResult "Number of commits removed"
+// @Failure      400 {object} gin.H "Bad Request"
+// @Failure      401 {object} gin.H "Unauthorized"
+// @Failure      403 {object} gin.H "Forbidden: Not the creator of the model"
+// @Failure      404 {object} gin.H "Model not found"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/commits/model/{uuid}/compact [post]
+func (h *CommitHandler) CompactModelHistory(c *gin.Context) {
+	uuid := c.Param("uuid")
+	olderThan, err := parseOlderThan(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	if !h.requireOwner(c, uuid) {
+		return
+	}
+
+	status, removed, err := h.commits.CompactModelHistory(uuid, olderThan)
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+	h.thumbnails.Delete(uuid)
+
+	c.IndentedJSON(status, apiTypes.CompactionResult{CDMUUID: uuid, RemovedCommits: removed})
+}
+
+// organizationOf returns the organization a user belongs to, which is the domain of their email address.
+func organizationOf(email string) string {
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return strings.ToLower(email[i+1:])
+	}
+	return ""
+}
+
+// lintConfigOf loads the lint rule configuration of an organization.
+// Rules that are no longer registered are ignored, so removing a rule doesn't break linting for organizations that configured it.
+func lintConfigOf(lintConfigs database.LintStore, organization string) (int, lint.Config, error) {
+	config := lint.Config{}
+	if organization == "" {
+		return http.StatusOK, config, nil
+	}
+
+	status, raw, err := lintConfigs.GetLintConfig(organization)
+	if err != nil {
+		return status, nil, err
+	}
+
+	for id, name := range raw {
+		severity, err := lint.ParseSeverity(name)
+		if _, ok := lint.Lookup(id); ok && err == nil {
+			config[id] = severity
+		}
+	}
+
+	return http.StatusOK, config, nil
+}
+
+// lintRulesFor lists every lint rule along with the severity it runs at under the given configuration.
+func lintRulesFor(config lint.Config) []apiTypes.LintRule {
+	rules := []apiTypes.LintRule{}
+	for _, rule := range lint.Rules() {
+		rules = append(rules, apiTypes.LintRule{
+			ID:              rule.ID,
+			Description:     rule.Description,
+			DefaultSeverity: string(rule.Severity),
+			Severity:        string(config.SeverityOf(rule)),
+		})
+	}
+	return rules
+}
+
+// GetLintRules godoc
+// @Summary      Get lint rules
+// @Description  lists the lint rules and the severity each runs at, for an organization if one is given
+// @Tags         lint
+// @Produce      json
+// @Param        organization query string false "Organization whose rule configuration to apply"
+// @Success      200 {object} []apiTypes.LintRule "Lint rules"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/lint/rules [get]
+func (h *LintHandler) GetLintRules(c *gin.Context) {
+	status, config, err := lintConfigOf(h.lintConfigs, strings.ToLower(c.Query("organization")))
+	if err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, lintRulesFor(config))
+}
+
+// SetLintRules godoc
+// @Summary      Configure lint rules for an organization
+// @Description  replaces the severities an organization has set for lint rules. The body maps rule IDs to off, info, warning or error, and rules left out go back to their default severity.
+// @Tags         lint
+// @Accept       json
+// @Produce      json
+// @Param        organization path string true "Organization"
+// @Param        config body map[string]string true "Severity of each rule"
+// @Success      200 {object} []apiTypes.LintRule "Lint rules as configured for the organization"
+// @Failure      400 {object} gin.H "Bad Request: Unknown rule or severity"
+// @Failure      401 {object} gin.H "Unauthorized"
+// @Failure      403 {object} gin.H "Forbidden: Not a member of the organization"
+// @Failure      500 {object} gin.H "Internal Server Error"
+// @Router       /v0/lint/rules/{organization} [put]
+func (h *LintHandler) SetLintRules(c *gin.Context) {
+	organization := strings.ToLower(c.Param("organization"))
+	// Only members of an organization may configure its rules.
+	if organizationOf(authenticatedUser(c).Email) != organization {
+		c.JSON(http.StatusForbidden, gin.H{"Error": "only members of an organization can configure its lint rules"})
+		return
+	}
+
+	var raw map[string]string
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	config, err := lint.ParseConfig(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+
+	if status, err := h.lintConfigs.SetLintConfig(organization, raw); err != nil {
+		c.JSON(status, gin.H{"Error": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, lintRulesFor(config))
+}
@@ -7,14 +7,22 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/bundle"
+	"opendi/model-hub/api/codec"
 	"opendi/model-hub/api/database"
+	"opendi/model-hub/api/thumbnail"
+	"opendi/model-hub/api/validation"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -22,47 +30,56 @@ import (
 )
 
 var router *gin.Engine
+var store *database.GormStore
 
 // TestMain is the entry point for the test suite. It sets up the test environment and runs the tests.
 func TestMain(m *testing.M) {
+	flag.Parse()
 	// setup test env
-	setup()
+	if err := setup(); err != nil {
+		fmt.Println("Error setting up the tests: ", err)
+		os.Exit(1)
+	}
 	os.Exit(m.Run())
 }
 
 // setup initializes the test environment by loading environment variables and setting up the database.
-func setup() {
+func setup() error {
 
-	//import environment variables
+	//import environment variables. The file is optional, and without a driver the tests use a database in memory.
 	err := godotenv.Load("../config/.env.test")
-	if err != nil {
-		fmt.Println("Error importing environment variables: ", err)
-		os.Exit(1)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not import environment variables: %s", err.Error())
+	}
+	if os.Getenv("OPEN_DI_DB_DRIVER") == "" {
+		os.Setenv("OPEN_DI_DB_DRIVER", database.DriverMemory)
 	}
-	ret := 0
 	//we also test the initialize DB instance here
-	ret, err = database.InitializeDBInstance()
-	if ret != 0 {
-		fmt.Println("Error initializing database: ", err)
-		os.Exit(1)
+	store, err = database.OpenDBInstance()
+	if err != nil {
+		return fmt.Errorf("could not initialize database: %s", err.Error())
 	}
 
-	database.ResetTables()
+	store.ResetTables()
 
 	// Initialize router
 	router = SetUpRouter()
 
+	return nil
 }
 
 func SetUpRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
 	//initialize handler
-	modelHandler, err := NewModelHandler()
+	thumbnails := thumbnail.NewCache(thumbnail.DefaultCacheSize)
+	modelHandler, err := NewModelHandler(store, store, store, thumbnails)
+
+	authHandler, _ := NewAuthHandler(store, true)
 
-	authHandler, _ := NewAuthHandler()
+	commitHandler, _ := NewCommitHandler(store, store, thumbnails)
 
-	commitHandler, _ := NewCommitHandler()
+	lintHandler, _ := NewLintHandler(store)
 
 	// Handle any errors that occur during initialization of the API endpoint handling logic
 	if err != nil {
@@ -76,6 +93,10 @@ func SetUpRouter() *gin.Engine {
 
 		commits.GET("", commitHandler.GetCommits) // Get all commits
 		commits.GET("/:uuid", commitHandler.GetLatestCommitByModelUUID)
+		commits.GET("model/:uuid", commitHandler.GetCommitsByModelUUID)
+		commits.PUT("/model/:uuid/:version/tag", authHandler.RequireUser, commitHandler.TagCommit)
+		commits.POST("/model/:uuid/squash", authHandler.RequireUser, commitHandler.SquashCommits)
+		commits.POST("/model/:uuid/compact", authHandler.RequireUser, commitHandler.CompactModelHistory)
 		//commits.POST("", commitHandler.UploadCommit) // Create a commit (for testing)
 	}
 
@@ -86,10 +107,36 @@ func SetUpRouter() *gin.Engine {
 		models.GET("/:uuid", modelHandler.GetModelByUUID) // Get a model by UUID
 		models.POST("", modelHandler.UploadModel)         // Upload a model
 		models.PUT("", modelHandler.PutModel)             // Update a model
+		models.POST("/validate", modelHandler.ValidateModel)
+		models.POST("/bulk", modelHandler.BulkImportModels)
 		models.GET("/lineage/:uuid", modelHandler.GetModelLineage)
 		models.GET("/children/:uuid", modelHandler.GetModelChildren)
 		models.GET("/search/:type/:name", modelHandler.ModelSearch)
 		models.GET("/modelVersion/:uuid/:version", modelHandler.GetVersionOfModel)
+		models.GET("/:uuid/blame", modelHandler.GetModelBlame)
+		models.GET("/:uuid/changelog", modelHandler.GetModelChangelog)
+		models.POST("/:uuid/fork", modelHandler.ForkModel)
+		models.GET("/:uuid/upstream", modelHandler.GetUpstreamStatus)
+		models.POST("/:uuid/upstream/sync", modelHandler.SyncWithParent)
+		models.GET("/:uuid/family", modelHandler.GetModelFamily)
+		models.GET("/:uuid/lint", modelHandler.LintModel)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/topological-order", modelHandler.GetTopologicalOrder)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/elements/:elementUUID/upstream", modelHandler.GetUpstreamElements)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/elements/:elementUUID/downstream", modelHandler.GetDownstreamElements)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/paths", modelHandler.GetElementPaths)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/components", modelHandler.GetStronglyConnectedComponents)
+		models.GET("/:uuid/diagrams/:diagramUUID/export", modelHandler.ExportDiagram)
+		models.GET("/:uuid/export", modelHandler.ExportModel)
+		models.GET("/:uuid/dmn", modelHandler.ExportDMN)
+		models.GET("/:uuid/bundle", modelHandler.ExportModelBundle)
+		models.POST("/bundle", modelHandler.ImportModelBundle)
+		models.GET("/:uuid/thumbnail.svg", modelHandler.GetModelThumbnail)
+	}
+
+	lint := r.Group("/v0/lint")
+	{
+		lint.GET("/rules", lintHandler.GetLintRules)
+		lint.PUT("/rules/:organization", authHandler.RequireUser, lintHandler.SetLintRules)
 	}
 
 	r.POST("/login", authHandler.UserLogin)
@@ -98,7 +145,7 @@ func SetUpRouter() *gin.Engine {
 }
 
 func TestGetModels(t *testing.T) {
-	database.ResetTables()
+	store.ResetTables()
 	req, _ := http.NewRequest("GET", "/v0/models", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -108,8 +155,8 @@ func TestGetModels(t *testing.T) {
 }
 
 func TestGetModelByUUID(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 	req, _ := http.NewRequest("GET", "/v0/models/123", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -125,7 +172,7 @@ func TestGetModelByUUID(t *testing.T) {
 }
 
 func TestUploadModel(t *testing.T) {
-	database.ResetTables()
+	store.ResetTables()
 
 	example, err := os.ReadFile("../test_files/model.json")
 	if err != nil {
@@ -159,8 +206,8 @@ func TestUploadModel(t *testing.T) {
 }
 
 func TestGetModelLineage(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 	//tests if the handler returns a 200 OK status code when the model exists for the model lineage
 	req, _ := http.NewRequest("GET", "/v0/models/lineage/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6e", nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -172,8 +219,8 @@ func TestGetModelLineage(t *testing.T) {
 
 // tests whether we can get the children of a model. This is an OK test given that the route function is just a wrapper for the database function.
 func TestGetModelChildren(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 
 	req, _ := http.NewRequest("GET", "/v0/models/children/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d", nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -186,7 +233,7 @@ func TestGetModelChildren(t *testing.T) {
 
 func TestUserLogin(t *testing.T) {
 	//Login with a new user
-	database.ResetTables()
+	store.ResetTables()
 	req, _ := http.NewRequest("POST", "/login?email=email1&password=pass1", nil)
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
@@ -203,8 +250,8 @@ func TestUserLogin(t *testing.T) {
 }
 
 func TestModelSearch(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 
 	//First let's search by model name and summary
 	req1, _ := http.NewRequest("GET", "/v0/models/search/model/summary", nil)
@@ -245,8 +292,8 @@ func TestModelSearch(t *testing.T) {
 }
 
 func TestPutModel(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 
 	example, err := os.ReadFile("../test_files/updatedExampleModel.json")
 	if err != nil {
@@ -291,8 +338,8 @@ func TestPutModel(t *testing.T) {
 }
 
 func TestGetAllCommits(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 
 	example, err := os.ReadFile("../test_files/updatedExampleModel.json")
 	if err != nil {
@@ -358,8 +405,8 @@ func TestGetAllCommits(t *testing.T) {
 }
 
 func TestGetLatestCommitByUUID(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 
 	example, err := os.ReadFile("../test_files/updatedExampleModel.json")
 	if err != nil {
@@ -406,8 +453,8 @@ func TestGetLatestCommitByUUID(t *testing.T) {
 
 // tests getting different versions of models.
 func TestGetVersionOfModel(t *testing.T) {
-	database.ResetTables()
-	database.CreateExampleModels()
+	store.ResetTables()
+	store.CreateExampleModels()
 
 	//tests getting version 0 of a model that has not been updated yet.
 	req, _ := http.NewRequest("GET", "/v0/models/modelVersion/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/0", nil)
@@ -421,7 +468,7 @@ func TestGetVersionOfModel(t *testing.T) {
 	byteReturnedModel, _ := json.Marshal(returnedModel)
 	strReturnedModel := string(byteReturnedModel)
 
-	_, model, _ := database.GetModelByUUID("1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d")
+	_, model, _ := store.GetModelByUUID("1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d")
 	bytemodel, _ := json.Marshal(model)
 	strmodel := string(bytemodel)
 
@@ -442,7 +489,7 @@ func TestGetVersionOfModel(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 	//push a change to our model.
 	returnedModel.Meta.Summary = "Updated summary"
-	database.UpdateModelAndCreateCommit(&returnedModel, model)
+	store.UpdateModelAndCreateCommit(&returnedModel, model)
 	//tests getting version 1 of a model that has been updated.
 	req, _ = http.NewRequest("GET", "/v0/models/modelVersion/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/1", nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -482,3 +529,874 @@ func TestGetVersionOfModel(t *testing.T) {
 	assert.Equal(t, strReturnedModel3, strmodel)
 
 }
+
+// tests getting models, lineage and children as they were at a given time.
+func TestGetModelAsOf(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	// remember a time after the example models were created, but before they were updated.
+	time.Sleep(1 * time.Second)
+	beforeUpdate := time.Now().UTC().Format(time.RFC3339)
+	time.Sleep(1 * time.Second)
+
+	//push a change to our model.
+	_, model, _ := store.GetModelByUUID("1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d")
+	_, updatedModel, _ := store.GetModelByUUID("1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d")
+	updatedModel.Meta.Summary = "Updated summary"
+	store.UpdateModelAndCreateCommit(updatedModel, model)
+
+	//tests getting the model as it was before the update.
+	req, _ := http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d?asOf="+beforeUpdate, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var returnedModel apiTypes.CausalDecisionModel
+	json.Unmarshal(w.Body.Bytes(), &returnedModel)
+	assert.Equal(t, "This is a test model", returnedModel.Meta.Summary)
+
+	//tests getting the model as it is now.
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d?asOf="+time.Now().Add(time.Minute).UTC().Format(time.RFC3339), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &returnedModel)
+	assert.Equal(t, "Updated summary", returnedModel.Meta.Summary)
+
+	//tests getting the model before it existed.
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d?asOf=2000-01-01T00:00:00Z", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	//tests a timestamp that can't be parsed.
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d?asOf=yesterday", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	//tests the lineage of the child model shows the parent as it was before the update.
+	req, _ = http.NewRequest("GET", "/v0/models/lineage/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6e?asOf="+beforeUpdate, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var lineage []apiTypes.CausalDecisionModel
+	json.Unmarshal(w.Body.Bytes(), &lineage)
+	assert.Equal(t, 1, len(lineage))
+	assert.Equal(t, "This is a test model", lineage[0].Meta.Summary)
+
+	//tests the children of the parent model as they were before the update.
+	req, _ = http.NewRequest("GET", "/v0/models/children/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d?asOf="+beforeUpdate, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var children []apiTypes.CausalDecisionModel
+	json.Unmarshal(w.Body.Bytes(), &children)
+	assert.Equal(t, 1, len(children))
+
+	//move the child to another parent; it is still a child of the parent as it was before.
+	_, child, _ := store.GetModelByUUID("1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6e")
+	_, movedChild, _ := store.GetModelByUUID("1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6e")
+	movedChild.ParentUUID = ""
+	_, _, err := store.UpdateModelAndCreateCommit(movedChild, child)
+	assert.NoError(t, err)
+
+	req, _ = http.NewRequest("GET", "/v0/models/children/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d?asOf="+beforeUpdate, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	children = nil
+	json.Unmarshal(w.Body.Bytes(), &children)
+	assert.Equal(t, 1, len(children))
+
+	req, _ = http.NewRequest("GET", "/v0/models/children/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	children = nil
+	json.Unmarshal(w.Body.Bytes(), &children)
+	assert.Equal(t, 0, len(children))
+}
+
+// tests that only the creator of a model can rewrite its history.
+func TestHistoryRoutesRequireOwner(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	uuid := "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"
+	_, oldModel, _ := store.GetModelByUUID(uuid)
+	_, newModel, _ := store.GetModelByUUID(uuid)
+	newModel.Meta.Summary = "changed"
+	store.UpdateModelAndCreateCommit(newModel, oldModel)
+
+	req, _ := http.NewRequest("POST", "/login?email=other@example.com&password=o", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	tag := func(email, password string) int {
+		req, _ := http.NewRequest("PUT", "/v0/commits/model/"+uuid+"/1/tag?tag=release", nil)
+		if email != "" {
+			req.SetBasicAuth(email, password)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, tag("", ""))
+	assert.Equal(t, http.StatusUnauthorized, tag("creator@example.com", "wrong"))
+	assert.Equal(t, http.StatusForbidden, tag("other@example.com", "o"))
+	assert.Equal(t, http.StatusOK, tag("creator@example.com", "p"))
+
+	req, _ = http.NewRequest("POST", "/v0/commits/model/"+uuid+"/compact?olderThan=0s", nil)
+	req.SetBasicAuth("other@example.com", "o")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// tests generating a changelog between two versions of a model.
+func TestGetModelChangelog(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	example, err := os.ReadFile("../test_files/updatedExampleModel.json")
+	if err != nil {
+		t.Errorf("Error reading test data: %s", err)
+	}
+
+	//update the example model with a commit message.
+	reqBody := bytes.NewBuffer(example)
+	req, _ := http.NewRequest("PUT", "/v0/models?message=Update+the+summary", reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	//get the changelog as JSON.
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/changelog?from=0&to=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var changelog apiTypes.Changelog
+	json.Unmarshal(w.Body.Bytes(), &changelog)
+	assert.Equal(t, 1, len(changelog.Entries))
+	assert.Equal(t, "Update the summary", changelog.Entries[0].Message)
+	assert.Equal(t, 1, len(changelog.Changed))
+	assert.Equal(t, "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d", changelog.Changed[0].UUID)
+
+	//get the changelog as markdown.
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/changelog?from=0&to=1&format=markdown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "## Version 1"))
+	assert.True(t, strings.Contains(w.Body.String(), "Update the summary"))
+
+	//tests a version past the latest version.
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/changelog?from=0&to=5", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	//tests a format that doesn't exist.
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/changelog?from=0&to=1&format=pdf", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetModelFamily(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	req, _ := http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/family?depth=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var family apiTypes.ModelFamily
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &family))
+	assert.Equal(t, 1, len(family.Descendants))
+
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/family?depth=-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// tests that uploads naming the bundled CDM schema are rejected with the location of each violation.
+func TestUploadModelSchemaValidation(t *testing.T) {
+	store.ResetTables()
+
+	body := `{"$schema": "https://opendi.org/schemas/cdm/v1/cdm.schema.json", "meta": {"uuid": "meta", "creator": {"email": "creator@example.com"}},
+		"diagrams": [{"meta": {"uuid": "diagram"}, "dependencies": [{"meta": {"uuid": "dependency"}, "source": "element"}]}]}`
+	req, _ := http.NewRequest("POST", "/v0/models", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response struct {
+		Issues []apiTypes.ValidationIssue
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, len(response.Issues))
+	assert.Equal(t, "/diagrams/0/dependencies/0", response.Issues[0].Pointer)
+}
+
+// tests validating models without saving them.
+func TestValidateModel(t *testing.T) {
+	validate := func(body string) (int, apiTypes.ValidationReport) {
+		req, _ := http.NewRequest("POST", "/v0/models/validate", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var report apiTypes.ValidationReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w.Code, report
+	}
+
+	code, report := validate(`{"$schema": "Test Schema", "meta": {"uuid": "model"}, "diagrams": [{"meta": {"uuid": "diagram"},
+		"elements": [{"meta": {"uuid": "element"}}], "dependencies": [{"meta": {"uuid": "dependency"}, "source": "element", "target": "element"}]}]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.Valid)
+
+	code, report = validate(`{"$schema": "Test Schema", "meta": {"uuid": "model"}, "diagrams": [{"meta": {"uuid": "diagram"},
+		"elements": [{"meta": {"uuid": "element"}}], "dependencies": [{"meta": {"uuid": "dependency"}, "source": "element", "target": "missing"}]}]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, report.Valid)
+	assert.Equal(t, 1, len(report.Issues))
+	assert.Equal(t, "/diagrams/0/dependencies/0/target", report.Issues[0].Pointer)
+
+	// Models that break their schema are reported even if they can't be bound.
+	code, report = validate(`{"$schema": "https://opendi.org/schemas/cdm/v1/cdm.schema.json", "meta": {"uuid": 1}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, report.Valid)
+	assert.Equal(t, "/meta/uuid", report.Issues[0].Pointer)
+
+	code, _ = validate("not json")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// tests linting a model with the default rules and with the rules configured for an organization.
+func TestLintModel(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	lintModel := func(query string) apiTypes.LintReport {
+		req, _ := http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/lint"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var report apiTypes.LintReport
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return report
+	}
+
+	report := lintModel("")
+	assert.Equal(t, "example.com", report.Organization)
+
+	// Turn every rule off for the organization of the creator.
+	config := map[string]string{}
+	for _, rule := range []string{"orphan-element", "decision-without-outcome", "causal-cycle", "empty-summary", "missing-causal-type"} {
+		config[rule] = "off"
+	}
+	body, _ := json.Marshal(config)
+	setRules := func(organization string, body []byte, email string) int {
+		req, _ := http.NewRequest("PUT", "/v0/lint/rules/"+organization, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if email != "" {
+			req.SetBasicAuth(email, "p")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Only members of the organization can configure its rules.
+	assert.Equal(t, http.StatusUnauthorized, setRules("example.com", body, ""))
+	assert.Equal(t, http.StatusForbidden, setRules("example.org", body, "creator@example.com"))
+	assert.Equal(t, http.StatusOK, setRules("example.com", body, "creator@example.com"))
+
+	report = lintModel("")
+	assert.Equal(t, 0, len(report.Issues))
+
+	assert.Equal(t, http.StatusBadRequest, setRules("example.com", []byte(`{"no-such-rule": "error"}`), "creator@example.com"))
+
+	req, _ := http.NewRequest("GET", "/v0/models/nonexistent/lint", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// tests the graph queries over a diagram of an uploaded model.
+func TestDiagramGraph(t *testing.T) {
+	store.ResetTables()
+
+	req, _ := http.NewRequest("POST", "/login?email=creator@example.com&password=pass1", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	body := `{"$schema": "Test Schema", "meta": {"uuid": "graph-model", "creator": {"email": "creator@example.com"}},
+		"diagrams": [{"meta": {"uuid": "graph-diagram", "creator": {"email": "creator@example.com"}},
+			"elements": [{"meta": {"uuid": "lever", "creator": {"email": "creator@example.com"}}, "causalType": "Lever"}, {"meta": {"uuid": "outcome", "creator": {"email": "creator@example.com"}}, "causalType": "Outcome"}],
+			"dependencies": [{"meta": {"uuid": "dependency", "creator": {"email": "creator@example.com"}}, "source": "lever", "target": "outcome"}]}]}`
+	req, _ = http.NewRequest("POST", "/v0/models", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created apiTypes.CausalDecisionModel
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	uuid := created.Meta.UUID
+
+	get := func(path string) (int, string) {
+		req, _ := http.NewRequest("GET", "/v0/models/"+uuid+"/diagrams/graph-diagram/graph"+path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	code, response := get("/topological-order")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `["lever", "outcome"]`, response)
+
+	code, response = get("/elements/outcome/upstream")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `["lever"]`, response)
+
+	code, response = get("/elements/lever/downstream")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `["outcome"]`, response)
+
+	code, response = get("/paths?from=lever&to=outcome")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[["lever", "outcome"]]`, response)
+
+	code, response = get("/components")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[["lever"], ["outcome"]]`, response)
+
+	code, _ = get("/elements/missing/upstream")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = get("/paths?from=lever")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+uuid+"/diagrams/missing/graph/components", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// tests exporting a diagram and a whole model.
+func TestExportDiagram(t *testing.T) {
+	store.ResetTables()
+
+	req, _ := http.NewRequest("POST", "/login?email=creator@example.com&password=pass1", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	body := `{"$schema": "Test Schema", "meta": {"uuid": "export-model", "creator": {"email": "creator@example.com"}},
+		"diagrams": [{"meta": {"uuid": "export-diagram", "creator": {"email": "creator@example.com"}},
+			"elements": [{"meta": {"uuid": "lever", "name": "Lever", "creator": {"email": "creator@example.com"}}, "causalType": "Lever"}, {"meta": {"uuid": "outcome", "name": "Outcome", "creator": {"email": "creator@example.com"}}, "causalType": "Outcome"}],
+			"dependencies": [{"meta": {"uuid": "dependency", "creator": {"email": "creator@example.com"}}, "source": "lever", "target": "outcome"}]}]}`
+	req, _ = http.NewRequest("POST", "/v0/models", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created apiTypes.CausalDecisionModel
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	uuid := created.Meta.UUID
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+uuid+"/diagrams/export-diagram/export?format=mermaid", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "n0 --> n1")
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+uuid+"/export?format=graphml", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/graphml+xml")
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+uuid+"/export?format=png", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+uuid+"/diagrams/missing/export", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// tests getting a thumbnail of a model, and that it isn't sent again while the model is unchanged.
+func TestGetModelThumbnail(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	req, _ := http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/thumbnail.svg", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "<svg"))
+
+	req, _ = http.NewRequest("GET", "/v0/models/1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d/thumbnail.svg", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// A model that doesn't exist isn't reported as unmodified, whatever the client has cached.
+	req, _ = http.NewRequest("GET", "/v0/models/nonexistent/thumbnail.svg", nil)
+	req.Header.Set("If-None-Match", `"nonexistent-v0"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDMNImportExport(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	file, err := os.ReadFile("../test_files/dmnModel.dmn")
+	if err != nil {
+		t.Fatalf("Failed to read DMN file: %v", err)
+	}
+
+	req, _ := http.NewRequest("POST", "/v0/models", bytes.NewReader(file))
+	req.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("POST", "/v0/models?email=creator@example.com", bytes.NewReader(file))
+	req.Header.Set("Content-Type", "application/xml")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created apiTypes.CausalDecisionModel
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Meta.UUID)
+	assert.Len(t, created.Diagrams, 1)
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+created.Meta.UUID+"/dmn", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+	assert.Contains(t, w.Body.String(), "<definitions")
+
+	req, _ = http.NewRequest("GET", "/v0/models/nonexistent/dmn", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// The imported model is answered in the format asked for, like an uploaded one.
+	req, _ = http.NewRequest("POST", "/v0/models?email=creator@example.com", bytes.NewReader(file))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Accept", "application/yaml")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestModelContentNegotiation(t *testing.T) {
+	store.ResetTables()
+
+	example, err := os.ReadFile("../test_files/model.yaml")
+	if err != nil {
+		t.Fatalf("Error reading test data: %s", err)
+	}
+
+	req, _ := http.NewRequest("POST", "/login?email=creator@example.com&password=pass1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// upload the model as YAML and receive it back as YAML
+	req, _ = http.NewRequest("POST", "/v0/models", bytes.NewReader(example))
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Accept", "application/yaml")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	createdJSON, err := codec.YAMLToJSON(w.Body.Bytes())
+	assert.NoError(t, err)
+	var created apiTypes.CausalDecisionModel
+	assert.NoError(t, json.Unmarshal(createdJSON, &created))
+	assert.NotEmpty(t, created.Meta.UUID)
+
+	// the raw content of the element keeps its key order and number literals through YAML and back
+	content := `{"position":{"x":120,"y":40.50},"unit":"percent","default":"5","enabled":true,"bounds":[0,1.0e1]}`
+	assert.Equal(t, content, string(created.Diagrams[0].Elements[0].Content))
+
+	// compact JSON when JSON is asked for explicitly, indented JSON otherwise
+	req, _ = http.NewRequest("GET", "/v0/models/"+created.Meta.UUID, nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "\n")
+	assert.Contains(t, w.Body.String(), `"content":`+content)
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+created.Meta.UUID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "\n    ")
+
+	// invalid YAML is a bad request
+	req, _ = http.NewRequest("POST", "/v0/models/validate", strings.NewReader("meta: [unclosed"))
+	req.Header.Set("Content-Type", "application/yaml")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestModelBundle(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	uuid := "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"
+	for _, format := range []string{"tar.gz", "zip"} {
+		req, _ := http.NewRequest("GET", "/v0/models/"+uuid+"/bundle?format="+format, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), uuid+"."+format)
+		archive := w.Body.Bytes()
+
+		// the model already exists here
+		req, _ = http.NewRequest("POST", "/v0/models/bundle", bytes.NewReader(archive))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		store.ResetTables()
+		req, _ = http.NewRequest("POST", "/v0/models/bundle", bytes.NewReader(archive))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var imported apiTypes.BundleImport
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+		assert.Equal(t, uuid, imported.Model.Meta.UUID)
+		assert.Empty(t, imported.SkippedAttachments)
+
+		store.ResetTables()
+		store.CreateExampleModels()
+	}
+
+	req, _ := http.NewRequest("GET", "/v0/models/"+uuid+"/bundle?format=rar", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("GET", "/v0/models/nonexistent/bundle", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("POST", "/v0/models/bundle", strings.NewReader("not a bundle"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// a bundle whose model has a dependency on a missing element is rejected like an upload
+	req, _ = http.NewRequest("GET", "/v0/models/"+uuid+"/bundle", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	read, err := bundle.Read(w.Body)
+	if err != nil {
+		t.Fatalf("Error reading bundle: %s", err)
+	}
+	read.Archive.Model.Diagrams = append(read.Archive.Model.Diagrams, apiTypes.Diagram{
+		Meta:         apiTypes.Meta{UUID: "diagram"},
+		Dependencies: []apiTypes.CausalDependency{{Meta: apiTypes.Meta{UUID: "dangling"}, Source: "missing", Target: "missing"}},
+	})
+	var broken bytes.Buffer
+	if err := bundle.New(read.Archive, time.Now()).Write(&broken, bundle.FormatTarGz); err != nil {
+		t.Fatalf("Error writing bundle: %s", err)
+	}
+
+	store.ResetTables()
+	req, _ = http.NewRequest("POST", "/v0/models/bundle", &broken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "unresolved")
+}
+
+func TestBulkImportModels(t *testing.T) {
+	store.ResetTables()
+	store.CreateExampleModels()
+
+	parent := `{"$schema":"","meta":{"uuid":"parent","name":"Parent","creator":{"email":"creator@example.com"}}}`
+	child := `{"$schema":"","meta":{"uuid":"child","name":"Child","creator":{"email":"creator@example.com"}},"parentUUID":"parent"}`
+	stranger := `{"$schema":"","meta":{"name":"Stranger","creator":{"email":"stranger@example.com"}}}`
+
+	// a JSON array with one model whose creator doesn't exist
+	req, _ := http.NewRequest("POST", "/v0/models/bulk", strings.NewReader("["+child+","+parent+","+stranger+"]"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var report apiTypes.BulkImport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, http.StatusConflict, report.Results[2].Status)
+
+	req, _ = http.NewRequest("GET", "/v0/models/"+report.Results[0].UUID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var created apiTypes.CausalDecisionModel
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, report.Results[1].UUID, created.ParentUUID)
+
+	// an NDJSON stream with a line that isn't JSON, atomically
+	req, _ = http.NewRequest("POST", "/v0/models/bulk?atomic=true&provisionCreators=true", strings.NewReader(parent+"\n{not json\n"+stranger+"\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, http.StatusBadRequest, report.Results[1].Status)
+
+	// the same stream without the broken line provisions the missing creator
+	req, _ = http.NewRequest("POST", "/v0/models/bulk?atomic=true&provisionCreators=true", strings.NewReader(parent+"\n"+stranger+"\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	req, _ = http.NewRequest("POST", "/v0/models/bulk?atomic=maybe", strings.NewReader("["+parent+"]"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("POST", "/v0/models/bulk", strings.NewReader(parent))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// fakeUsers is a UserStore that knows a single user, to check the handlers work with stores other than the database.
+type fakeUsers struct {
+	user apiTypes.User
+}
+
+func (f *fakeUsers) UserLogin(email string, password string, register bool) (int, *apiTypes.User, error) {
+	if email != f.user.Email || password != f.user.Password {
+		return http.StatusUnauthorized, nil, fmt.Errorf("wrong email or password")
+	}
+	user := f.user
+	return http.StatusOK, &user, nil
+}
+
+func TestUserLoginWithFakeStore(t *testing.T) {
+	authHandler, err := NewAuthHandler(&fakeUsers{user: apiTypes.User{Email: "ada@example.com", Password: "hunter2"}}, false)
+	assert.NoError(t, err)
+	r := gin.New()
+	r.POST("/login", authHandler.UserLogin)
+
+	req, _ := http.NewRequest("POST", "/login?email=ada@example.com&password=hunter2", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var user apiTypes.User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "ada@example.com", user.Email)
+	assert.Empty(t, user.Password)
+
+	req, _ = http.NewRequest("POST", "/login?email=ada@example.com&password=wrong", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// fakeModels is a ModelStore that keeps models in a map. Methods the tests don't use are left to the embedded
+// interface, and panic if called.
+type fakeModels struct {
+	database.ModelStore
+	models map[string]apiTypes.CausalDecisionModel
+}
+
+func (f *fakeModels) GetModelByUUID(uuid string) (int, *apiTypes.CausalDecisionModel, error) {
+	model, ok := f.models[uuid]
+	if !ok {
+		return http.StatusNotFound, nil, fmt.Errorf("model with UUID %s not found", uuid)
+	}
+	return http.StatusOK, &model, nil
+}
+
+func (f *fakeModels) CreateModelGivenEmail(uploadedModel *apiTypes.CausalDecisionModel) (int, error) {
+	if _, ok := f.models[uploadedModel.Meta.UUID]; ok {
+		return http.StatusConflict, fmt.Errorf("model with UUID %s already exists", uploadedModel.Meta.UUID)
+	}
+	f.models[uploadedModel.Meta.UUID] = *uploadedModel
+	return http.StatusCreated, nil
+}
+
+func (f *fakeModels) UpdateModelAndCreateCommitWithMessage(uploadedModel *apiTypes.CausalDecisionModel, oldModel *apiTypes.CausalDecisionModel, message string) (*apiTypes.CausalDecisionModel, int, error) {
+	f.models[uploadedModel.Meta.UUID] = *uploadedModel
+	return uploadedModel, http.StatusOK, nil
+}
+
+// fakeCommits is a CommitStore that keeps the commits of each model in a map.
+type fakeCommits struct {
+	database.CommitStore
+	commits map[string][]apiTypes.Commit
+}
+
+func (f *fakeCommits) GetLatestCommitForModelUUID(uuid string) (int, *apiTypes.Commit, error) {
+	commits := f.commits[uuid]
+	if len(commits) == 0 {
+		return http.StatusNotFound, nil, fmt.Errorf("no commits found for model %s", uuid)
+	}
+	return http.StatusOK, &commits[len(commits)-1], nil
+}
+
+func (f *fakeCommits) GetCommitsByModelUUID(uuid string) (int, []apiTypes.Commit, error) {
+	commits, ok := f.commits[uuid]
+	if !ok {
+		return http.StatusNotFound, nil, fmt.Errorf("no commits found for model %s", uuid)
+	}
+	return http.StatusOK, commits, nil
+}
+
+func (f *fakeCommits) TagCommit(uuid string, version int, tag string) (int, *apiTypes.Commit, error) {
+	for i := range f.commits[uuid] {
+		if f.commits[uuid][i].Version == version {
+			f.commits[uuid][i].Tag = tag
+			return http.StatusOK, &f.commits[uuid][i], nil
+		}
+	}
+	return http.StatusNotFound, nil, fmt.Errorf("version %d of model %s not found", version, uuid)
+}
+
+func TestModelsWithFakeStore(t *testing.T) {
+	models := &fakeModels{models: map[string]apiTypes.CausalDecisionModel{}}
+	modelHandler, err := NewModelHandler(models, &fakeCommits{}, nil, thumbnail.NewCache(thumbnail.DefaultCacheSize))
+	assert.NoError(t, err)
+	r := gin.New()
+	r.POST("/v0/models", modelHandler.UploadModel)
+	r.PUT("/v0/models", modelHandler.PutModel)
+	r.GET("/v0/models/:uuid", modelHandler.GetModelByUUID)
+
+	body := `{"$schema": "` + validation.DefaultSchemaID + `", "meta": {"uuid": "fake-model", "name": "Fake", "creator": {"email": "ada@example.com"}}}`
+	req, _ := http.NewRequest("POST", "/v0/models", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "Fake", models.models["fake-model"].Meta.Name)
+
+	// the same model again conflicts with the one in the store
+	req, _ = http.NewRequest("POST", "/v0/models", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// a model breaking its schema never reaches the store
+	req, _ = http.NewRequest("POST", "/v0/models", strings.NewReader(`{"$schema": "`+validation.DefaultSchemaID+`", "meta": {"uuid": 1}}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Len(t, models.models, 1)
+
+	req, _ = http.NewRequest("PUT", "/v0/models", strings.NewReader(strings.Replace(body, `"Fake"`, `"Renamed"`, 1)))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	req, _ = http.NewRequest("GET", "/v0/models/fake-model", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var model apiTypes.CausalDecisionModel
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &model))
+	assert.Equal(t, "Renamed", model.Meta.Name)
+
+	req, _ = http.NewRequest("GET", "/v0/models/missing", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCommitsWithFakeStore(t *testing.T) {
+	models := &fakeModels{models: map[string]apiTypes.CausalDecisionModel{
+		"fake-model": {Meta: apiTypes.Meta{UUID: "fake-model", Creator: apiTypes.User{Email: "ada@example.com"}}},
+	}}
+	commits := &fakeCommits{commits: map[string][]apiTypes.Commit{
+		"fake-model": {{CDMUUID: "fake-model", Version: 1}, {CDMUUID: "fake-model", Version: 2}},
+	}}
+	users := &fakeUsers{user: apiTypes.User{Email: "ada@example.com", Password: "hunter2"}}
+
+	commitHandler, err := NewCommitHandler(commits, models, thumbnail.NewCache(thumbnail.DefaultCacheSize))
+	assert.NoError(t, err)
+	authHandler, err := NewAuthHandler(users, false)
+	assert.NoError(t, err)
+	r := gin.New()
+	r.GET("/v0/commits/model/:uuid", commitHandler.GetCommitsByModelUUID)
+	r.PUT("/v0/commits/model/:uuid/:version/tag", authHandler.RequireUser, commitHandler.TagCommit)
+
+	req, _ := http.NewRequest("GET", "/v0/commits/model/fake-model", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var history []apiTypes.Commit
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Len(t, history, 2)
+
+	req, _ = http.NewRequest("GET", "/v0/commits/model/missing", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// only the creator of the model can tag its versions
+	req, _ = http.NewRequest("PUT", "/v0/commits/model/fake-model/2/tag?tag=release", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	users.user.Email = "eve@example.com"
+	req, _ = http.NewRequest("PUT", "/v0/commits/model/fake-model/2/tag?tag=release", nil)
+	req.SetBasicAuth("eve@example.com", "hunter2")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	users.user.Email = "ada@example.com"
+	req, _ = http.NewRequest("PUT", "/v0/commits/model/fake-model/2/tag?tag=release", nil)
+	req.SetBasicAuth("ada@example.com", "hunter2")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "release", commits.commits["fake-model"][1].Tag)
+}
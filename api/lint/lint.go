@@ -0,0 +1,149 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package lint reports problems in models that don't make them invalid but that reviewers want to know about.
+// Rules are registered with a default severity, which organizations can raise, lower or turn off.
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// Severity is how serious a lint issue is. Rules set to SeverityOff are not run.
+type Severity string
+
+const (
+	SeverityOff     Severity = "off"
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// rank orders severities so that issues can be sorted with the most serious first.
+var rank = map[Severity]int{
+	SeverityOff:     0,
+	SeverityInfo:    1,
+	SeverityWarning: 2,
+	SeverityError:   3,
+}
+
+// ParseSeverity returns the severity with the given name.
+func ParseSeverity(name string) (Severity, error) {
+	severity := Severity(name)
+	if _, ok := rank[severity]; !ok {
+		return "", fmt.Errorf("unknown severity %s, expected off, info, warning or error", name)
+	}
+	return severity, nil
+}
+
+// Finding is a problem found by a rule, located by a JSON pointer into the model.
+type Finding struct {
+	Pointer string
+	UUID    string
+	Message string
+}
+
+// Rule checks a model for one kind of problem.
+type Rule struct {
+	ID          string
+	Description string
+	Severity    Severity
+	Check       func(model *apiTypes.CausalDecisionModel) []Finding
+}
+
+// Config overrides the default severity of rules, keyed by rule ID.
+type Config map[string]Severity
+
+var registry = map[string]Rule{}
+
+// Register adds a rule to the registry. It panics if the rule has no ID or check, or if its ID is already registered.
+func Register(rule Rule) {
+	if rule.ID == "" || rule.Check == nil {
+		panic("lint rules need an ID and a check")
+	}
+	if _, ok := registry[rule.ID]; ok {
+		panic(fmt.Sprintf("lint rule %s is already registered", rule.ID))
+	}
+	if _, ok := rank[rule.Severity]; !ok {
+		panic(fmt.Sprintf("lint rule %s has an unknown severity %s", rule.ID, rule.Severity))
+	}
+	registry[rule.ID] = rule
+}
+
+// Lookup returns the registered rule with the given ID.
+func Lookup(id string) (Rule, bool) {
+	rule, ok := registry[id]
+	return rule, ok
+}
+
+// Rules returns every registered rule, sorted by ID.
+func Rules() []Rule {
+	rules := make([]Rule, 0, len(registry))
+	for _, rule := range registry {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// ParseConfig checks that every rule and severity in a raw configuration exists.
+func ParseConfig(raw map[string]string) (Config, error) {
+	config := Config{}
+	for id, name := range raw {
+		if _, ok := registry[id]; !ok {
+			return nil, fmt.Errorf("unknown lint rule %s", id)
+		}
+		severity, err := ParseSeverity(name)
+		if err != nil {
+			return nil, err
+		}
+		config[id] = severity
+	}
+	return config, nil
+}
+
+// SeverityOf returns the severity a rule runs at under the given configuration.
+func (config Config) SeverityOf(rule Rule) Severity {
+	if severity, ok := config[rule.ID]; ok {
+		return severity
+	}
+	return rule.Severity
+}
+
+// Lint runs every rule that isn't turned off in the configuration over the model.
+// Issues are sorted with the most serious first, and then by where they are in the model.
+func Lint(model *apiTypes.CausalDecisionModel, config Config) []apiTypes.LintIssue {
+	issues := []apiTypes.LintIssue{}
+
+	for _, rule := range Rules() {
+		severity := config.SeverityOf(rule)
+		if severity == SeverityOff {
+			continue
+		}
+
+		for _, finding := range rule.Check(model) {
+			issues = append(issues, apiTypes.LintIssue{
+				Rule:     rule.ID,
+				Severity: string(severity),
+				Pointer:  finding.Pointer,
+				UUID:     finding.UUID,
+				Message:  finding.Message,
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Severity != issues[j].Severity {
+			return rank[Severity(issues[i].Severity)] > rank[Severity(issues[j].Severity)]
+		}
+		return issues[i].Pointer < issues[j].Pointer
+	})
+
+	return issues
+}
@@ -0,0 +1,138 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package lint
+
+import (
+	"testing"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+func element(uuid string, causalType string) apiTypes.DiaElement {
+	return apiTypes.DiaElement{Meta: apiTypes.Meta{UUID: uuid, Name: uuid, Summary: "summary"}, CausalType: causalType}
+}
+
+func dependency(source string, target string) apiTypes.CausalDependency {
+	return apiTypes.CausalDependency{Meta: apiTypes.Meta{UUID: source + "-" + target}, Source: source, Target: target}
+}
+
+// testModel has a lever with a path to an outcome, a lever without one that is caught in a cycle, an orphan with no causal type,
+// and a diagram with no summary.
+func testModel() *apiTypes.CausalDecisionModel {
+	return &apiTypes.CausalDecisionModel{
+		Meta: apiTypes.Meta{UUID: "model", Summary: "summary"},
+		Diagrams: []apiTypes.Diagram{{
+			Meta: apiTypes.Meta{UUID: "diagram"},
+			Elements: []apiTypes.DiaElement{
+				element("lever1", "Lever"),
+				element("intermediate", "Intermediate"),
+				element("outcome", "Outcome"),
+				element("lever2", "lever"),
+				element("external", "External"),
+				element("orphan", ""),
+			},
+			Dependencies: []apiTypes.CausalDependency{
+				dependency("lever1", "intermediate"),
+				dependency("intermediate", "outcome"),
+				dependency("lever2", "external"),
+				dependency("external", "lever2"),
+			},
+		}},
+	}
+}
+
+func rulesOf(issues []apiTypes.LintIssue) map[string][]string {
+	rules := map[string][]string{}
+	for _, issue := range issues {
+		rules[issue.Rule] = append(rules[issue.Rule], issue.UUID)
+	}
+	return rules
+}
+
+func TestLint(t *testing.T) {
+	issues := Lint(testModel(), Config{})
+	rules := rulesOf(issues)
+
+	expected := map[string][]string{
+		"orphan-element":           {"orphan"},
+		"decision-without-outcome": {"lever2"},
+		"causal-cycle":             {"lever2"},
+		"empty-summary":            {"diagram"},
+		"missing-causal-type":      {"orphan"},
+	}
+	for rule, uuids := range expected {
+		if len(rules[rule]) != len(uuids) || rules[rule][0] != uuids[0] {
+			t.Errorf("Expected %s to report %v, got %v", rule, uuids, rules[rule])
+		}
+	}
+
+	// Issues are sorted with the most serious first.
+	if issues[len(issues)-1].Severity != string(SeverityInfo) {
+		t.Errorf("Expected the info issue to come last, got %s", issues[len(issues)-1].Severity)
+	}
+}
+
+// tests that DMN decisions are checked for a path to an outcome like levers, unless they are outcomes themselves.
+func TestDecisionsWithoutOutcome(t *testing.T) {
+	decision := func(uuid string, causalType string) apiTypes.DiaElement {
+		e := element(uuid, causalType)
+		e.DiagramType = "decision"
+		return e
+	}
+	model := &apiTypes.CausalDecisionModel{
+		Diagrams: []apiTypes.Diagram{{
+			Elements: []apiTypes.DiaElement{
+				decision("required", "Lever"),
+				decision("untyped", ""),
+				decision("final", "Outcome"),
+				decision("stranded", "Intermediate"),
+			},
+			Dependencies: []apiTypes.CausalDependency{
+				dependency("required", "final"),
+				dependency("untyped", "final"),
+			},
+		}},
+	}
+
+	var uuids []string
+	for _, finding := range checkDecisionsWithoutOutcome(model) {
+		uuids = append(uuids, finding.UUID)
+	}
+	if len(uuids) != 1 || uuids[0] != "stranded" {
+		t.Errorf("Expected only the stranded decision to be reported, got %v", uuids)
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	config, err := ParseConfig(map[string]string{"empty-summary": "off", "causal-cycle": "error"})
+	if err != nil {
+		t.Fatalf("Error parsing config: %s", err)
+	}
+
+	issues := Lint(testModel(), config)
+	if issues[0].Rule != "causal-cycle" || issues[0].Severity != string(SeverityError) {
+		t.Errorf("Expected the cycle to be reported first as an error, got %s as %s", issues[0].Rule, issues[0].Severity)
+	}
+	if _, ok := rulesOf(issues)["empty-summary"]; ok {
+		t.Errorf("Expected rules turned off not to run")
+	}
+
+	if _, err := ParseConfig(map[string]string{"no-such-rule": "error"}); err == nil {
+		t.Errorf("Expected an error for an unknown rule")
+	}
+	if _, err := ParseConfig(map[string]string{"empty-summary": "fatal"}); err == nil {
+		t.Errorf("Expected an error for an unknown severity")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a rule twice to panic")
+		}
+	}()
+
+	Register(Rule{ID: "empty-summary", Severity: SeverityInfo, Check: checkEmptySummaries})
+}
@@ -0,0 +1,199 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/graph"
+)
+
+// Causal types of elements that the rules care about.
+const (
+	causalTypeLever   = "Lever"
+	causalTypeOutcome = "Outcome"
+)
+
+// diagramTypeDecision is the diagram type of decisions imported from DMN, which keep their DMN kind as their diagram type.
+const diagramTypeDecision = "decision"
+
+func init() {
+	Register(Rule{
+		ID:          "orphan-element",
+		Description: "Elements should be connected to the rest of their diagram by at least one dependency.",
+		Severity:    SeverityWarning,
+		Check:       checkOrphanElements,
+	})
+	Register(Rule{
+		ID:          "decision-without-outcome",
+		Description: "Every decision, whether a lever or a DMN decision, should have a path of dependencies leading to an outcome.",
+		Severity:    SeverityWarning,
+		Check:       checkDecisionsWithoutOutcome,
+	})
+	Register(Rule{
+		ID:          "causal-cycle",
+		Description: "The dependencies of a diagram should not form a cycle.",
+		Severity:    SeverityWarning,
+		Check:       checkCausalCycles,
+	})
+	Register(Rule{
+		ID:          "empty-summary",
+		Description: "Models, diagrams and elements should have a summary.",
+		Severity:    SeverityInfo,
+		Check:       checkEmptySummaries,
+	})
+	Register(Rule{
+		ID:          "missing-causal-type",
+		Description: "Every element should have a causal type.",
+		Severity:    SeverityWarning,
+		Check:       checkMissingCausalTypes,
+	})
+}
+
+func elementPointer(diagram int, element int) string {
+	return fmt.Sprintf("/diagrams/%d/elements/%d", diagram, element)
+}
+
+// describe names a component by its name if it has one, and its UUID otherwise.
+func describe(meta apiTypes.Meta) string {
+	if meta.Name != "" {
+		return meta.Name
+	}
+	return meta.UUID
+}
+
+func checkOrphanElements(model *apiTypes.CausalDecisionModel) []Finding {
+	var findings []Finding
+	for i, diagram := range model.Diagrams {
+		connected := map[string]bool{}
+		for _, dependency := range diagram.Dependencies {
+			connected[dependency.Source] = true
+			connected[dependency.Target] = true
+		}
+
+		for j, element := range diagram.Elements {
+			if !connected[element.Meta.UUID] {
+				findings = append(findings, Finding{
+					Pointer: elementPointer(i, j),
+					UUID:    element.Meta.UUID,
+					Message: fmt.Sprintf("element %s has no dependencies", describe(element.Meta)),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// isDecision reports whether an element is a decision: a lever, or a DMN decision that isn't itself an outcome.
+func isDecision(element apiTypes.DiaElement) bool {
+	if strings.EqualFold(element.CausalType, causalTypeLever) {
+		return true
+	}
+	return strings.EqualFold(element.DiagramType, diagramTypeDecision) && !strings.EqualFold(element.CausalType, causalTypeOutcome)
+}
+
+func checkDecisionsWithoutOutcome(model *apiTypes.CausalDecisionModel) []Finding {
+	var findings []Finding
+	for i, diagram := range model.Diagrams {
+		outcomes := map[string]bool{}
+		for _, element := range diagram.Elements {
+			if strings.EqualFold(element.CausalType, causalTypeOutcome) {
+				outcomes[element.Meta.UUID] = true
+			}
+		}
+		g := graph.New(diagram)
+
+		for j, element := range diagram.Elements {
+			if !isDecision(element) {
+				continue
+			}
+
+			reachesOutcome := false
+			for _, downstream := range g.Downstream(element.Meta.UUID) {
+				if outcomes[downstream] {
+					reachesOutcome = true
+					break
+				}
+			}
+
+			if !reachesOutcome {
+				findings = append(findings, Finding{
+					Pointer: elementPointer(i, j),
+					UUID:    element.Meta.UUID,
+					Message: fmt.Sprintf("decision %s has no path to an outcome", describe(element.Meta)),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkCausalCycles(model *apiTypes.CausalDecisionModel) []Finding {
+	var findings []Finding
+	for i, diagram := range model.Diagrams {
+		positions := map[string]int{}
+		elements := map[string]apiTypes.Meta{}
+		for j, element := range diagram.Elements {
+			if _, ok := positions[element.Meta.UUID]; !ok {
+				positions[element.Meta.UUID] = j
+				elements[element.Meta.UUID] = element.Meta
+			}
+		}
+
+		// Each cycle is reported once, at the first of its elements in the diagram.
+		for _, cycle := range graph.New(diagram).Cycles() {
+			names := make([]string, 0, len(cycle))
+			for _, uuid := range cycle {
+				names = append(names, describe(elements[uuid]))
+			}
+			findings = append(findings, Finding{
+				Pointer: elementPointer(i, positions[cycle[0]]),
+				UUID:    cycle[0],
+				Message: fmt.Sprintf("dependencies form a cycle between %s", strings.Join(names, ", ")),
+			})
+		}
+	}
+	return findings
+}
+
+func checkEmptySummaries(model *apiTypes.CausalDecisionModel) []Finding {
+	var findings []Finding
+	check := func(meta apiTypes.Meta, pointer string, kind string) {
+		if strings.TrimSpace(meta.Summary) == "" {
+			findings = append(findings, Finding{
+				Pointer: pointer + "/meta/summary",
+				UUID:    meta.UUID,
+				Message: fmt.Sprintf("%s %s has no summary", kind, describe(meta)),
+			})
+		}
+	}
+
+	check(model.Meta, "", "model")
+	for i, diagram := range model.Diagrams {
+		check(diagram.Meta, fmt.Sprintf("/diagrams/%d", i), "diagram")
+		for j, element := range diagram.Elements {
+			check(element.Meta, elementPointer(i, j), "element")
+		}
+	}
+	return findings
+}
+
+func checkMissingCausalTypes(model *apiTypes.CausalDecisionModel) []Finding {
+	var findings []Finding
+	for i, diagram := range model.Diagrams {
+		for j, element := range diagram.Elements {
+			if strings.TrimSpace(element.CausalType) == "" {
+				findings = append(findings, Finding{
+					Pointer: elementPointer(i, j) + "/causalType",
+					UUID:    element.Meta.UUID,
+					Message: fmt.Sprintf("element %s has no causal type", describe(element.Meta)),
+				})
+			}
+		}
+	}
+	return findings
+}
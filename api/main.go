@@ -5,9 +5,15 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
 	"opendi/model-hub/api/handlers"
 	"os"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -16,57 +22,138 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"opendi/model-hub/api/config"
 	"opendi/model-hub/api/database"
+	"opendi/model-hub/api/thumbnail"
 
 	_ "opendi/model-hub/api/docs"
 	"time"
 )
 
+// loadEnvironment imports environment variables from the .env file, if there is one. Variables already set in the
+// environment are left alone.
+func loadEnvironment() {
+	err := godotenv.Load("./config/.env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Println("Unable to import environment variables: ", err)
+		//the program can still run even if the .env file can't be read, as the configuration may come from elsewhere
+	}
+}
+
+// loadConfig parses the command line with the configuration flags defined on flags, next to the flags of the
+// command, and loads and validates the configuration. Returns nil and the exit code to fail with if it can't.
+func loadConfig(flags *flag.FlagSet, args []string) (*config.Config, int) {
+	configFlags := config.NewFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, 2
+	}
+
+	loadEnvironment()
+	cfg, err := configFlags.Load(os.LookupEnv)
+	if err != nil {
+		fmt.Println("Error loading configuration: ", err)
+		return nil, 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Println("Invalid configuration:")
+		fmt.Println(err)
+		return nil, 1
+	}
+	return cfg, 0
+}
+
 func main() {
-	fmt.Println("Starting Model Hub API")
-	router := gin.Default()
+	// Subcommands run instead of the server, which only takes flags.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		switch os.Args[1] {
+		case "seed":
+			os.Exit(seedCommand(os.Args[2:]))
+		case "migrate":
+			os.Exit(migrateCommand(os.Args[2:]))
+		case "config":
+			os.Exit(configCommand(os.Args[2:]))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %s, expected seed, migrate or config\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+
+	flags := flag.NewFlagSet("modelhub", flag.ContinueOnError)
+	cfg, code := loadConfig(flags, os.Args[1:])
+	if cfg == nil {
+		os.Exit(code)
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument %s\n", flags.Arg(0))
+		os.Exit(2)
+	}
+
+	logger := cfg.Logging.NewLogger(os.Stderr)
+	slog.SetDefault(logger)
+	if !strings.EqualFold(cfg.Logging.Level, "debug") {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	logger.Info("Starting Model Hub API")
+	router := gin.New()
+	router.Use(gin.Recovery(), logRequests(logger))
 
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://129.213.115.50:3000"}, // React frontend URL
+		AllowOrigins:     cfg.CORS.AllowOrigins, // the React frontend, unless configured otherwise
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
+		AllowCredentials: cfg.CORS.AllowCredentials,
 	}))
 
-	//import environment variables
-	err := godotenv.Load("./config/.env")
+	router.Use(limitBody(int64(cfg.Limits.MaxBodySize)))
+
+	//initialize db instance, waiting for the database to start up if it hasn't yet
+	store, err := database.Initialize(cfg.Database)
 	if err != nil {
-		fmt.Println("Unable to import environment variables: ", err)
-		//os.Exit(1)
-		//I think the above line should remain commented out, so that
-		//the program can still run even if the .env file is not found
-		//This is because the .env file is not necessary for the program to run
-		//It is only necessary for the program to run in a specific environment
-	}
-
-	// Wait for 3 seconds to allow the database to start up before initializing the connection to the database table
-	time.Sleep(3 * time.Second)
-	//initialize db instance
-	ret, err := database.InitializeDBInstance()
-	if ret != 0 {
-		fmt.Println("Error initializing database: ", err)
+		logger.Error("Error initializing database", "error", err)
 		os.Exit(1)
 	}
 	//initialize handler
-	modelHandler, _ := handlers.NewModelHandler()
+	thumbnails := thumbnail.NewCache(thumbnail.DefaultCacheSize)
+	modelHandler, err := handlers.NewModelHandler(store, store, store, thumbnails)
+	if err != nil {
+		logger.Error("Error initializing model handler", "error", err)
+		os.Exit(1)
+	}
 
-	authHandler, _ := handlers.NewAuthHandler()
+	authHandler, err := handlers.NewAuthHandler(store, cfg.Auth.RegisterOnLogin)
+	if err != nil {
+		logger.Error("Error initializing auth handler", "error", err)
+		os.Exit(1)
+	}
 
-	commitHandler, err := handlers.NewCommitHandler()
+	commitHandler, err := handlers.NewCommitHandler(store, store, thumbnails)
+	if err != nil {
+		logger.Error("Error initializing commit handler", "error", err)
+		os.Exit(1)
+	}
 
-	// Handle any errors that occur during initialization of the API endpoint handling logic
+	lintHandler, err := handlers.NewLintHandler(store)
 	if err != nil {
-		fmt.Println("Error initializing model handler: ", err)
+		logger.Error("Error initializing lint handler", "error", err)
 		os.Exit(1)
 	}
-	// Debug, creates a model and meta in the database
-	database.CreateExampleModels()
+	// Fixtures are loaded with the seed command, never on start.
+
+	// If a retention period is configured, compact model history older than it once a day.
+	// Tagged versions are always preserved.
+	if cfg.Limits.CommitRetention > 0 {
+		retention := time.Duration(cfg.Limits.CommitRetention)
+		go func() {
+			for {
+				if _, _, err := store.CompactAllModelHistory(time.Now().Add(-retention)); err != nil {
+					logger.Error("Error compacting model history", "error", err)
+				}
+				time.Sleep(24 * time.Hour)
+			}
+		}()
+	}
 
 	//router group for all endpoints related to models
 	models := router.Group("/v0/models")
@@ -108,10 +195,30 @@ func main() {
 		models.GET("/:uuid", modelHandler.GetModelByUUID) // Get a model by UUID
 		models.POST("", modelHandler.UploadModel)         // Upload a model
 		models.PUT("", modelHandler.PutModel)             // Update a model
+		models.POST("/validate", modelHandler.ValidateModel)
+		models.POST("/bulk", modelHandler.BulkImportModels)
 
 		models.GET("/lineage/:uuid", modelHandler.GetModelLineage)
 		models.GET("/children/:uuid", modelHandler.GetModelChildren)
 		models.GET("/modelVersion/:uuid/:version", modelHandler.GetVersionOfModel)
+		models.GET("/:uuid/blame", modelHandler.GetModelBlame)
+		models.GET("/:uuid/changelog", modelHandler.GetModelChangelog)
+		models.POST("/:uuid/fork", modelHandler.ForkModel)
+		models.GET("/:uuid/upstream", modelHandler.GetUpstreamStatus)
+		models.POST("/:uuid/upstream/sync", modelHandler.SyncWithParent)
+		models.GET("/:uuid/family", modelHandler.GetModelFamily)
+		models.GET("/:uuid/lint", modelHandler.LintModel)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/topological-order", modelHandler.GetTopologicalOrder)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/elements/:elementUUID/upstream", modelHandler.GetUpstreamElements)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/elements/:elementUUID/downstream", modelHandler.GetDownstreamElements)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/paths", modelHandler.GetElementPaths)
+		models.GET("/:uuid/diagrams/:diagramUUID/graph/components", modelHandler.GetStronglyConnectedComponents)
+		models.GET("/:uuid/diagrams/:diagramUUID/export", modelHandler.ExportDiagram)
+		models.GET("/:uuid/export", modelHandler.ExportModel)
+		models.GET("/:uuid/dmn", modelHandler.ExportDMN)
+		models.GET("/:uuid/bundle", modelHandler.ExportModelBundle)
+		models.POST("/bundle", modelHandler.ImportModelBundle)
+		models.GET("/:uuid/thumbnail.svg", modelHandler.GetModelThumbnail)
 		models.GET("/search/:type/:name", modelHandler.ModelSearch)
 	}
 
@@ -122,33 +229,53 @@ func main() {
 		commits.GET("", commitHandler.GetCommits) // Get all commits
 		commits.GET("/:uuid", commitHandler.GetLatestCommitByModelUUID)
 		commits.GET("model/:uuid", commitHandler.GetCommitsByModelUUID)
+
+		// Rewriting the history of a model is left to its creator. Every model is compacted by the retention period.
+		commits.PUT("/model/:uuid/:version/tag", authHandler.RequireUser, commitHandler.TagCommit)
+		commits.POST("/model/:uuid/squash", authHandler.RequireUser, commitHandler.SquashCommits)
+		commits.POST("/model/:uuid/compact", authHandler.RequireUser, commitHandler.CompactModelHistory)
 		//commits.POST("", commitHandler.UploadCommit) // Create a commit (for testing)
 	}
 
-	//router group for uploading models
-
-	// Get the address and port from environment variables
-	modelHubAddress := "localhost"
-	modelHubPort := "8080"
-	// Check to make sure the environment variables are set before using them
-	val, ok := os.LookupEnv("OPENDI_MODEL_HUB_ADDRESS")
-	if !ok || val == "" {
-		// error exit since the value is empty
-		fmt.Println("Environment variable OPENDI_MODEL_HUB_ADDRESS is not set or empty")
-		os.Exit(1)
-	}
-	modelHubAddress = val
-	val, ok = os.LookupEnv("OPENDI_MODEL_HUB_PORT")
-	if !ok || val == "" {
-		// error exit since the value is empty
-		fmt.Println("Environment variable OPENDI_MODEL_HUB_PORT is not set or empty")
-		os.Exit(1)
+	//router group for configuring lint rules
+	lint := router.Group("/v0/lint")
+	{
+		lint.GET("/rules", lintHandler.GetLintRules)
+		lint.PUT("/rules/:organization", authHandler.RequireUser, lintHandler.SetLintRules)
 	}
-	modelHubPort = val
+
+	//router group for uploading models
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	router.POST("/login", authHandler.UserLogin)
 
-	router.Run(modelHubAddress + ":" + modelHubPort)
+	logger.Info("Listening", "address", cfg.Server.Addr())
+	if err := router.Run(cfg.Server.Addr()); err != nil {
+		logger.Error("Error running server", "error", err)
+		os.Exit(1)
+	}
+}
+
+// logRequests logs every request once it has been handled, like gin.Logger, through the logger.
+func logRequests(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logger.Info("Request", "method", c.Request.Method, "path", c.Request.URL.Path, "status", c.Writer.Status(),
+			"duration", time.Since(start), "client", c.ClientIP())
+	}
+}
+
+// limitBody refuses requests that declare a body larger than limit, and stops handlers reading more than limit
+// bytes of a body that doesn't declare its length.
+func limitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"Error": fmt.Sprintf("request body is larger than %d bytes", limit)})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
 }
@@ -0,0 +1,96 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"opendi/model-hub/api/database"
+)
+
+const migrateUsage = "usage: modelhub migrate status | up | down [steps] | to <version>"
+
+// migrateCommand shows or changes the version of the schema of the database. Returns the exit code of the command.
+func migrateCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	cfg, code := loadConfig(flags, args)
+	if cfg == nil {
+		return code
+	}
+	action, rest := flags.Arg(0), flags.Args()[min(1, flags.NArg()):]
+	valid := (action == "status" || action == "up") && len(rest) == 0 ||
+		action == "down" && len(rest) <= 1 ||
+		action == "to" && len(rest) == 1
+	if !valid {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	// The number of steps to go down, or the version to go to.
+	number := 1
+	if len(rest) == 1 {
+		var err error
+		if number, err = strconv.Atoi(rest[0]); err != nil || number < 0 || action == "down" && number == 0 {
+			fmt.Fprintf(os.Stderr, "%s is not a valid number of steps or version\n", rest[0])
+			return 2
+		}
+	}
+
+	store, err := database.Open(cfg.Database)
+	if err != nil {
+		fmt.Println("Error initializing database: ", err)
+		return 1
+	}
+	defer store.Close()
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		fmt.Println("Error reading schema version: ", err)
+		return 1
+	}
+
+	var target int
+	switch action {
+	case "status":
+		printMigrationStatus(version)
+		return 0
+	case "up":
+		target = database.LatestSchemaVersion()
+	case "down":
+		target = max(version-number, 0)
+	case "to":
+		target = number
+	}
+
+	ran, err := store.Migrate(target)
+	for _, migration := range ran {
+		if migration.Version > version {
+			fmt.Printf("applied  %d %s\n", migration.Version, migration.Name)
+		} else {
+			fmt.Printf("reverted %d %s\n", migration.Version, migration.Name)
+		}
+	}
+	if err != nil {
+		fmt.Println("Error migrating database: ", err)
+		return 1
+	}
+
+	fmt.Printf("Schema is at version %d\n", target)
+	return 0
+}
+
+// printMigrationStatus prints the version of the schema and whether each migration has been applied.
+func printMigrationStatus(version int) {
+	fmt.Printf("Schema is at version %d, the latest is %d\n", version, database.LatestSchemaVersion())
+	for _, migration := range database.Migrations() {
+		state := "pending"
+		if migration.Version <= version {
+			state = "applied"
+		}
+		fmt.Printf("%-8s %d %s\n", state, migration.Version, migration.Name)
+	}
+}
@@ -0,0 +1,65 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"opendi/model-hub/api/database"
+	"opendi/model-hub/api/seed"
+)
+
+// seedCommand loads the fixture models in a directory into the database, leaving the ones already there alone,
+// so it can be run again safely. Returns the exit code of the command.
+func seedCommand(args []string) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	from := flags.String("from", "", "directory of fixture files to load, such as test_files/demos")
+	cfg, code := loadConfig(flags, args)
+	if cfg == nil {
+		return code
+	}
+	if *from == "" || flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: modelhub seed --from <dir>")
+		return 2
+	}
+
+	// Load every fixture before touching the database, so a broken directory seeds nothing.
+	fixtures, skipped, err := seed.Load(*from)
+	if err != nil {
+		fmt.Println("Error loading fixtures: ", err)
+		return 1
+	}
+	for _, file := range skipped {
+		fmt.Printf("skipped %s: %s\n", file.Name, file.Reason)
+	}
+
+	store, err := database.Initialize(cfg.Database)
+	if err != nil {
+		fmt.Println("Error initializing database: ", err)
+		return 1
+	}
+	defer store.Close()
+
+	created := 0
+	for _, fixture := range fixtures {
+		model := fixture.Model
+		_, ok, err := store.SeedModel(&model)
+		if err != nil {
+			fmt.Printf("Error seeding %s: %s\n", fixture.Name, err)
+			return 1
+		}
+		if ok {
+			created++
+			fmt.Printf("created %s as %s\n", fixture.Name, model.Meta.UUID)
+		} else {
+			fmt.Printf("exists  %s as %s\n", fixture.Name, model.Meta.UUID)
+		}
+	}
+
+	fmt.Printf("Seeded %d models, %d already existed\n", created, len(fixtures)-created)
+	return 0
+}
@@ -0,0 +1,151 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package seed loads fixture models from a directory of files in the test_files format, so environments can be
+// seeded with them. A file holds either a single model or an array of models, as JSON or YAML. Fixtures without a
+// UUID are given one derived from their path, so seeding the same directory again finds the models it created.
+package seed
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/codec"
+	"opendi/model-hub/api/validation"
+)
+
+// Fixture is a model loaded from a fixture file. Name identifies it within the directory, by its path and, for
+// files holding an array of models, its index.
+type Fixture struct {
+	Name  string
+	Model apiTypes.CausalDecisionModel
+}
+
+// Skipped is a file, or a value in a file, that was not loaded because it isn't a model.
+type Skipped struct {
+	Name   string
+	Reason string
+}
+
+// FixtureUUID returns the UUID given to the fixture with the given name when it doesn't have one.
+func FixtureUUID(name string) string {
+	sum := sha1.Sum([]byte("opendi-model-hub-seed:" + name))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Load loads every fixture in dir and its subdirectories, in lexical order of their paths.
+// Files other than .json, .yaml and .yml files, and JSON values without a meta, are skipped. A fixture that doesn't
+// match its schema, or two fixtures with the same UUID, fail the whole load, so nothing is seeded from a broken directory.
+func Load(dir string) ([]Fixture, []Skipped, error) {
+	var fixtures []Fixture
+	var skipped []Skipped
+	names := map[string]string{}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+		case ".yaml", ".yml":
+			if data, err = codec.YAMLToJSON(data); err != nil {
+				return fmt.Errorf("%s: %s", name, err.Error())
+			}
+		default:
+			skipped = append(skipped, Skipped{Name: name, Reason: "not a JSON or YAML file"})
+			return nil
+		}
+
+		items := []json.RawMessage{data}
+		trimmed := bytes.TrimSpace(data)
+		isArray := len(trimmed) > 0 && trimmed[0] == '['
+		if isArray {
+			if err := json.Unmarshal(trimmed, &items); err != nil {
+				return fmt.Errorf("%s: %s", name, err.Error())
+			}
+			if len(items) == 0 {
+				skipped = append(skipped, Skipped{Name: name, Reason: "empty array"})
+			}
+		}
+
+		for i, item := range items {
+			itemName := name
+			if isArray {
+				itemName = fmt.Sprintf("%s#%d", name, i)
+			}
+
+			fixture, reason, err := loadFixture(itemName, item)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				skipped = append(skipped, Skipped{Name: itemName, Reason: reason})
+				continue
+			}
+
+			if other, ok := names[fixture.Model.Meta.UUID]; ok {
+				return fmt.Errorf("%s and %s both have uuid %s", other, itemName, fixture.Model.Meta.UUID)
+			}
+			names[fixture.Model.Meta.UUID] = itemName
+			fixtures = append(fixtures, *fixture)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return fixtures, skipped, nil
+}
+
+// loadFixture parses the JSON of a single fixture. If it isn't a model, it returns the reason instead.
+func loadFixture(name string, item json.RawMessage) (*Fixture, string, error) {
+	if !json.Valid(item) {
+		return nil, "", fmt.Errorf("%s: invalid JSON", name)
+	}
+
+	var header struct {
+		Meta json.RawMessage `json:"meta"`
+	}
+	if err := json.Unmarshal(item, &header); err != nil {
+		return nil, "not a JSON object", nil
+	}
+	if len(header.Meta) == 0 || header.Meta[0] != '{' {
+		return nil, "no meta", nil
+	}
+
+	issues, err := validation.ValidateSchema(item)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %s", name, err.Error())
+	}
+	if len(issues) > 0 {
+		return nil, "", fmt.Errorf("%s: %s does not match its schema: %s", name, issues[0].Pointer, issues[0].Message)
+	}
+
+	fixture := &Fixture{Name: name}
+	if err := json.Unmarshal(item, &fixture.Model); err != nil {
+		return nil, "", fmt.Errorf("%s: %s", name, err.Error())
+	}
+	if fixture.Model.Meta.UUID == "" {
+		fixture.Model.Meta.UUID = FixtureUUID(name)
+	}
+	return fixture, "", nil
+}
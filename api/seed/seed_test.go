@@ -0,0 +1,86 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package seed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"opendi/model-hub/api/validation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFiles writes the given files, keyed by their path, to a new temporary directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"single.json": `{"$schema": "Test Schema", "meta": {"uuid": "", "name": "Single"}}`,
+		"list.json":   `[{"meta": {"uuid": "fixed-uuid", "name": "First"}}, {"meta": {"name": "Second"}}]`,
+		"sub/y.yaml":  "meta:\n  name: From YAML\n",
+		"commit.json": `{"diff": "[]", "version": 1}`,
+		"notes.txt":   "not a fixture",
+	})
+
+	fixtures, skipped, err := Load(dir)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, fixture := range fixtures {
+		names = append(names, fixture.Name)
+	}
+	assert.Equal(t, []string{"list.json#0", "list.json#1", "single.json", "sub/y.yaml"}, names)
+	assert.Equal(t, []Skipped{
+		{Name: "commit.json", Reason: "no meta"},
+		{Name: "notes.txt", Reason: "not a JSON or YAML file"},
+	}, skipped)
+
+	// fixtures keep their UUID, or are given one derived from their name
+	assert.Equal(t, "fixed-uuid", fixtures[0].Model.Meta.UUID)
+	assert.Equal(t, FixtureUUID("list.json#1"), fixtures[1].Model.Meta.UUID)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`, fixtures[2].Model.Meta.UUID)
+	assert.Equal(t, "From YAML", fixtures[3].Model.Meta.Name)
+
+	// loading again gives the same UUIDs
+	again, _, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, fixtures, again)
+}
+
+func TestLoadRejectsBrokenDirectories(t *testing.T) {
+	_, _, err := Load(writeFiles(t, map[string]string{
+		"a.json": `{"meta": {"uuid": "same", "name": "A"}}`,
+		"b.json": `{"meta": {"uuid": "same", "name": "B"}}`,
+	}))
+	assert.ErrorContains(t, err, "a.json and b.json both have uuid same")
+
+	_, _, err = Load(writeFiles(t, map[string]string{"broken.json": `{"meta": `}))
+	assert.ErrorContains(t, err, "broken.json: invalid JSON")
+
+	_, _, err = Load(writeFiles(t, map[string]string{
+		"invalid.json": `{"$schema": "` + validation.DefaultSchemaID + `", "meta": {"uuid": "x", "name": 5}}`,
+	}))
+	assert.ErrorContains(t, err, "invalid.json: /meta")
+
+	_, _, err = Load(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestLoadDemos(t *testing.T) {
+	fixtures, skipped, err := Load("../test_files/demos")
+	assert.NoError(t, err)
+	assert.Len(t, fixtures, 20)
+	assert.Empty(t, skipped)
+}
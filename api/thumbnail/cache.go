@@ -0,0 +1,93 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package thumbnail
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheSize is the number of thumbnails a cache keeps unless told otherwise.
+const DefaultCacheSize = 1024
+
+// Cache keeps the latest thumbnail of each model along with the version of the model it was drawn from.
+// It holds at most size thumbnails, evicting the least recently used one to make room for another.
+// It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// recent orders the entries from the most to the least recently used.
+	recent *list.List
+}
+
+type cacheEntry struct {
+	uuid    string
+	version string
+	svg     []byte
+}
+
+// NewCache returns a cache holding at most size thumbnails. A size below one is taken as DefaultCacheSize.
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = DefaultCacheSize
+	}
+	return &Cache{size: size, entries: map[string]*list.Element{}, recent: list.New()}
+}
+
+// Get returns the thumbnail of a model if it was drawn from the given version.
+func (cache *Cache) Get(uuid string, version string) ([]byte, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[uuid]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if entry.version != version {
+		return nil, false
+	}
+	cache.recent.MoveToFront(element)
+	return entry.svg, true
+}
+
+// Put stores the thumbnail of a model, replacing any drawn from another version.
+func (cache *Cache) Put(uuid string, version string, svg []byte) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[uuid]; ok {
+		element.Value = &cacheEntry{uuid: uuid, version: version, svg: svg}
+		cache.recent.MoveToFront(element)
+		return
+	}
+
+	cache.entries[uuid] = cache.recent.PushFront(&cacheEntry{uuid: uuid, version: version, svg: svg})
+	if cache.recent.Len() > cache.size {
+		oldest := cache.recent.Back()
+		cache.recent.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).uuid)
+	}
+}
+
+// Delete drops the thumbnail of a model, such as when its history is rewritten or it is removed.
+func (cache *Cache) Delete(uuid string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[uuid]; ok {
+		cache.recent.Remove(element)
+		delete(cache.entries, uuid)
+	}
+}
+
+// Len returns the number of thumbnails in the cache.
+func (cache *Cache) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.recent.Len()
+}
@@ -0,0 +1,126 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package thumbnail
+
+import (
+	"encoding/json"
+
+	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/graph"
+)
+
+// Sizes of the drawing, in SVG user units.
+const (
+	nodeWidth    = 120.0
+	nodeHeight   = 40.0
+	layerSpacing = 180.0
+	rowSpacing   = 70.0
+)
+
+// point is the center of an element in the drawing.
+type point struct {
+	X float64
+	Y float64
+}
+
+// position reads the position of an element from its content, which either holds x and y itself or under position.
+func position(content json.RawMessage) (point, bool) {
+	var raw struct {
+		X        *float64 `json:"x"`
+		Y        *float64 `json:"y"`
+		Position *struct {
+			X *float64 `json:"x"`
+			Y *float64 `json:"y"`
+		} `json:"position"`
+	}
+	if len(content) == 0 || json.Unmarshal(content, &raw) != nil {
+		return point{}, false
+	}
+
+	if raw.Position != nil && raw.Position.X != nil && raw.Position.Y != nil {
+		return point{X: *raw.Position.X, Y: *raw.Position.Y}, true
+	}
+	if raw.X != nil && raw.Y != nil {
+		return point{X: *raw.X, Y: *raw.Y}, true
+	}
+	return point{}, false
+}
+
+// layout places every element of a diagram. If every element has a position in its content, those are used.
+// Otherwise the elements are laid out in layers, with each element one layer to the right of the furthest element depending on it.
+func layout(diagram apiTypes.Diagram) map[string]point {
+	positions := map[string]point{}
+	for _, element := range diagram.Elements {
+		p, ok := position(element.Content)
+		if !ok {
+			return layeredLayout(diagram)
+		}
+		positions[element.Meta.UUID] = p
+	}
+	return positions
+}
+
+// layeredLayout assigns each element a layer by the longest path of dependencies leading to it.
+// Elements on a cycle share a layer, since there is no order between them.
+func layeredLayout(diagram apiTypes.Diagram) map[string]point {
+	g := graph.New(diagram)
+
+	components := g.StronglyConnectedComponents()
+	componentOf := map[string]int{}
+	for i, component := range components {
+		for _, node := range component {
+			componentOf[node] = i
+		}
+	}
+
+	// The components form a DAG. Visit them in an order where every component comes after those with dependencies on it.
+	layers := make([]int, len(components))
+	inDegree := make([]int, len(components))
+	successors := make([]map[int]bool, len(components))
+	for i, component := range components {
+		successors[i] = map[int]bool{}
+		for _, node := range component {
+			for _, next := range g.Successors(node) {
+				if j := componentOf[next]; j != i && !successors[i][j] {
+					successors[i][j] = true
+					inDegree[j]++
+				}
+			}
+		}
+	}
+
+	queue := []int{}
+	for i := range components {
+		if inDegree[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for j := range components {
+			if !successors[i][j] {
+				continue
+			}
+			layers[j] = max(layers[j], layers[i]+1)
+			inDegree[j]--
+			if inDegree[j] == 0 {
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	positions := map[string]point{}
+	rows := map[int]int{}
+	for _, node := range g.Nodes() {
+		layer := layers[componentOf[node]]
+		positions[node] = point{
+			X: float64(layer) * layerSpacing,
+			Y: float64(rows[layer]) * rowSpacing,
+		}
+		rows[layer]++
+	}
+	return positions
+}
@@ -0,0 +1,147 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package thumbnail draws small SVG previews of models, with their diagrams stacked from top to bottom.
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+
+	"opendi/model-hub/api/apiTypes"
+	"opendi/model-hub/api/export"
+)
+
+// Size of the thumbnail. The drawing is scaled to fit it, keeping its aspect ratio.
+const (
+	Width  = 320
+	Height = 200
+)
+
+const (
+	margin         = 20.0
+	diagramSpacing = 40.0
+	titleHeight    = 24.0
+	maxLabelLength = 18
+)
+
+// escape escapes text for use in SVG content and attributes.
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// truncate shortens a label so it fits in its element.
+func truncate(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxLabelLength {
+		return s
+	}
+	return string(runes[:maxLabelLength-1]) + "…"
+}
+
+// clip returns where the line from the center of an element towards a point leaves the element's box.
+func clip(center point, towards point) point {
+	dx, dy := towards.X-center.X, towards.Y-center.Y
+	if dx == 0 && dy == 0 {
+		return center
+	}
+
+	scale := math.Min(
+		(nodeWidth/2)/math.Max(math.Abs(dx), 1e-9),
+		(nodeHeight/2)/math.Max(math.Abs(dy), 1e-9),
+	)
+	return point{X: center.X + dx*scale, Y: center.Y + dy*scale}
+}
+
+// Render draws a model as an SVG document.
+func Render(model apiTypes.CausalDecisionModel) []byte {
+	var body strings.Builder
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	extend := func(x1, y1, x2, y2 float64) {
+		minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+		maxX, maxY = math.Max(maxX, x2), math.Max(maxY, y2)
+	}
+
+	offset := 0.0
+	for _, diagram := range model.Diagrams {
+		positions := layout(diagram)
+		if len(positions) == 0 {
+			continue
+		}
+
+		// Move the diagram below the previous one, with its title above it.
+		dMinX, dMinY := math.Inf(1), math.Inf(1)
+		dMaxY := math.Inf(-1)
+		for _, p := range positions {
+			dMinX, dMinY = math.Min(dMinX, p.X), math.Min(dMinY, p.Y)
+			dMaxY = math.Max(dMaxY, p.Y)
+		}
+		shift := point{X: -dMinX, Y: offset + titleHeight - dMinY}
+		for uuid, p := range positions {
+			positions[uuid] = point{X: p.X + shift.X, Y: p.Y + shift.Y}
+		}
+
+		title := diagram.Meta.Name
+		if title == "" {
+			title = diagram.Meta.UUID
+		}
+		fmt.Fprintf(&body, `<text x="%.1f" y="%.1f" class="title">%s</text>`+"\n", -nodeWidth/2, offset, escape(truncate(title)))
+		extend(-nodeWidth/2, offset-titleHeight/2, -nodeWidth/2, offset)
+
+		for _, dependency := range diagram.Dependencies {
+			from, fromOK := positions[dependency.Source]
+			to, toOK := positions[dependency.Target]
+			if !fromOK || !toOK || dependency.Source == dependency.Target {
+				continue
+			}
+			start, end := clip(from, to), clip(to, from)
+			fmt.Fprintf(&body, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" marker-end="url(#arrow)"/>`+"\n", start.X, start.Y, end.X, end.Y)
+		}
+
+		drawn := map[string]bool{}
+		for _, element := range diagram.Elements {
+			p, ok := positions[element.Meta.UUID]
+			if !ok || drawn[element.Meta.UUID] {
+				continue
+			}
+			drawn[element.Meta.UUID] = true
+
+			label := element.Meta.Name
+			if label == "" {
+				label = element.Meta.UUID
+			}
+			x, y := p.X-nodeWidth/2, p.Y-nodeHeight/2
+			fmt.Fprintf(&body, `<rect x="%.1f" y="%.1f" width="%.0f" height="%.0f" rx="6" fill="%s"/>`+"\n", x, y, nodeWidth, nodeHeight, export.FillColor(element.CausalType))
+			fmt.Fprintf(&body, `<text x="%.1f" y="%.1f">%s</text>`+"\n", p.X, p.Y, escape(truncate(label)))
+			extend(x, y, x+nodeWidth, y+nodeHeight)
+		}
+
+		offset += titleHeight + (dMaxY - dMinY) + nodeHeight + diagramSpacing
+	}
+
+	// Models without any elements show their name instead.
+	if math.IsInf(minX, 1) {
+		name := model.Meta.Name
+		if name == "" {
+			name = model.Meta.UUID
+		}
+		fmt.Fprintf(&body, `<text x="%d" y="%d">%s</text>`+"\n", Width/2, Height/2, escape(truncate(name)))
+		minX, minY, maxX, maxY = margin, margin, Width-margin, Height-margin
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%.1f %.1f %.1f %.1f" preserveAspectRatio="xMidYMid meet">`+"\n",
+		Width, Height, minX-margin, minY-margin, maxX-minX+2*margin, maxY-minY+2*margin)
+	svg.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#555"/></marker></defs>` + "\n")
+	svg.WriteString(`<style>rect{stroke:#555;stroke-width:1}line{stroke:#555;stroke-width:1.5}text{font-family:sans-serif;font-size:12px;text-anchor:middle;dominant-baseline:middle}text.title{font-weight:bold;text-anchor:start}</style>` + "\n")
+	svg.WriteString(body.String())
+	svg.WriteString("</svg>\n")
+	return []byte(svg.String())
+}
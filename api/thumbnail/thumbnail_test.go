@@ -0,0 +1,137 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package thumbnail
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+func element(uuid string, content string) apiTypes.DiaElement {
+	return apiTypes.DiaElement{Meta: apiTypes.Meta{UUID: uuid, Name: uuid}, Content: json.RawMessage(content)}
+}
+
+func dependency(source string, target string) apiTypes.CausalDependency {
+	return apiTypes.CausalDependency{Source: source, Target: target}
+}
+
+func TestLayoutFromContent(t *testing.T) {
+	diagram := apiTypes.Diagram{
+		Elements: []apiTypes.DiaElement{
+			element("a", `{"position": {"x": 10, "y": 20}}`),
+			element("b", `{"x": 300, "y": 40}`),
+		},
+	}
+
+	positions := layout(diagram)
+	if positions["a"] != (point{X: 10, Y: 20}) || positions["b"] != (point{X: 300, Y: 40}) {
+		t.Errorf("Expected the positions from the content, got %v", positions)
+	}
+}
+
+func TestLayeredLayout(t *testing.T) {
+	// c has no position, so the whole diagram is laid out in layers: a, then the cycle of b and c, then d.
+	diagram := apiTypes.Diagram{
+		Elements: []apiTypes.DiaElement{
+			element("a", `{"x": 10, "y": 20}`),
+			element("b", ""),
+			element("c", "[0]"),
+			element("d", ""),
+		},
+		Dependencies: []apiTypes.CausalDependency{
+			dependency("a", "b"),
+			dependency("b", "c"),
+			dependency("c", "b"),
+			dependency("c", "d"),
+			dependency("a", "d"),
+		},
+	}
+
+	positions := layout(diagram)
+	expected := map[string]point{
+		"a": {X: 0, Y: 0},
+		"b": {X: layerSpacing, Y: 0},
+		"c": {X: layerSpacing, Y: rowSpacing},
+		"d": {X: 2 * layerSpacing, Y: 0},
+	}
+	for uuid, p := range expected {
+		if positions[uuid] != p {
+			t.Errorf("Expected %s at %v, got %v", uuid, p, positions[uuid])
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	model := apiTypes.CausalDecisionModel{
+		Meta: apiTypes.Meta{UUID: "model", Name: "Model"},
+		Diagrams: []apiTypes.Diagram{
+			{
+				Meta:         apiTypes.Meta{UUID: "first", Name: "<First>"},
+				Elements:     []apiTypes.DiaElement{element("a", ""), element("b", "")},
+				Dependencies: []apiTypes.CausalDependency{dependency("a", "b")},
+			},
+			{
+				Meta:     apiTypes.Meta{UUID: "second"},
+				Elements: []apiTypes.DiaElement{element("a very long element name indeed", "")},
+			},
+		},
+	}
+
+	svg := string(Render(model))
+	if err := xml.Unmarshal([]byte(svg), new(struct{})); err != nil {
+		t.Fatalf("Expected the thumbnail to be valid XML: %s\n%s", err, svg)
+	}
+	if strings.Count(svg, "<rect") != 3 || strings.Count(svg, "<line") != 1 {
+		t.Errorf("Expected 3 elements and 1 dependency, got:\n%s", svg)
+	}
+	for _, expected := range []string{"&lt;First&gt;", "a very long eleme…"} {
+		if !strings.Contains(svg, expected) {
+			t.Errorf("Expected the thumbnail to contain %s, got:\n%s", expected, svg)
+		}
+	}
+
+	// Models without elements show their name.
+	svg = string(Render(apiTypes.CausalDecisionModel{Meta: apiTypes.Meta{Name: "Empty"}}))
+	if !strings.Contains(svg, ">Empty<") {
+		t.Errorf("Expected an empty model to show its name, got:\n%s", svg)
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+	cache.Put("model", "v1", []byte("first"))
+
+	if svg, ok := cache.Get("model", "v1"); !ok || string(svg) != "first" {
+		t.Errorf("Expected the cached thumbnail of v1")
+	}
+	if _, ok := cache.Get("model", "v2"); ok {
+		t.Errorf("Expected no thumbnail for another version")
+	}
+
+	cache.Put("model", "v2", []byte("second"))
+	if _, ok := cache.Get("model", "v1"); ok {
+		t.Errorf("Expected the thumbnail of v1 to be replaced")
+	}
+
+	// Going past the size evicts the least recently used thumbnail.
+	cache.Put("other", "v1", []byte("other"))
+	cache.Get("model", "v2")
+	cache.Put("third", "v1", []byte("third"))
+	if _, ok := cache.Get("other", "v1"); ok {
+		t.Errorf("Expected the least recently used thumbnail to be evicted")
+	}
+	if _, ok := cache.Get("model", "v2"); !ok || cache.Len() != 2 {
+		t.Errorf("Expected the cache to keep the 2 most recently used thumbnails, got %d", cache.Len())
+	}
+
+	cache.Delete("model")
+	if _, ok := cache.Get("model", "v2"); ok {
+		t.Errorf("Expected the thumbnail to be dropped")
+	}
+}
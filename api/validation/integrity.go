@@ -0,0 +1,111 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+// ValidateReferences checks the structure of a model that the schema can't express:
+// every meta UUID is used only once in the model, every dependency connects elements of its own diagram,
+// and every associated evaluation element is an element of the model.
+// Each issue points at the offending value with a JSON pointer.
+func ValidateReferences(model *apiTypes.CausalDecisionModel) []apiTypes.ValidationIssue {
+	issues := []apiTypes.ValidationIssue{}
+
+	// Record where each UUID was first used so duplicates can point back at it.
+	firstUse := map[string]string{}
+	checkUnique := func(uuid string, pointer string) {
+		if uuid == "" {
+			return
+		}
+		if first, ok := firstUse[uuid]; ok {
+			issues = append(issues, apiTypes.ValidationIssue{
+				Pointer: pointer,
+				Message: fmt.Sprintf("uuid %s is already used at %s", uuid, first),
+			})
+			return
+		}
+		firstUse[uuid] = pointer
+	}
+
+	checkUnique(model.Meta.UUID, "/meta/uuid")
+
+	modelElements := map[string]bool{}
+	for _, diagram := range model.Diagrams {
+		for _, element := range diagram.Elements {
+			modelElements[element.Meta.UUID] = true
+		}
+	}
+
+	for i, diagram := range model.Diagrams {
+		diagramPointer := fmt.Sprintf("/diagrams/%d", i)
+		checkUnique(diagram.Meta.UUID, diagramPointer+"/meta/uuid")
+
+		diagramElements := map[string]bool{}
+		for j, element := range diagram.Elements {
+			elementPointer := fmt.Sprintf("%s/elements/%d", diagramPointer, j)
+			checkUnique(element.Meta.UUID, elementPointer+"/meta/uuid")
+			diagramElements[element.Meta.UUID] = true
+
+			issues = append(issues, validateAssociatedElements(element.AssociatedElements, elementPointer+"/associatedEvalElements", modelElements)...)
+		}
+
+		for j, dependency := range diagram.Dependencies {
+			dependencyPointer := fmt.Sprintf("%s/dependencies/%d", diagramPointer, j)
+			checkUnique(dependency.Meta.UUID, dependencyPointer+"/meta/uuid")
+
+			if !diagramElements[dependency.Source] {
+				issues = append(issues, apiTypes.ValidationIssue{
+					Pointer: dependencyPointer + "/source",
+					Message: fmt.Sprintf("source %s is not an element of this diagram", dependency.Source),
+				})
+			}
+			if !diagramElements[dependency.Target] {
+				issues = append(issues, apiTypes.ValidationIssue{
+					Pointer: dependencyPointer + "/target",
+					Message: fmt.Sprintf("target %s is not an element of this diagram", dependency.Target),
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+// validateAssociatedElements checks that the associated evaluation elements of an element are a list of UUIDs of elements in the model.
+func validateAssociatedElements(raw json.RawMessage, pointer string, modelElements map[string]bool) []apiTypes.ValidationIssue {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+
+	var references []any
+	if err := json.Unmarshal(raw, &references); err != nil {
+		return []apiTypes.ValidationIssue{{Pointer: pointer, Message: "associated evaluation elements must be a list of element uuids"}}
+	}
+
+	var issues []apiTypes.ValidationIssue
+	for i, reference := range references {
+		uuid, ok := reference.(string)
+		if !ok {
+			issues = append(issues, apiTypes.ValidationIssue{
+				Pointer: fmt.Sprintf("%s/%d", pointer, i),
+				Message: "associated evaluation element must be an element uuid",
+			})
+			continue
+		}
+		if !modelElements[uuid] {
+			issues = append(issues, apiTypes.ValidationIssue{
+				Pointer: fmt.Sprintf("%s/%d", pointer, i),
+				Message: fmt.Sprintf("associated evaluation element %s is not an element of this model", uuid),
+			})
+		}
+	}
+
+	return issues
+}
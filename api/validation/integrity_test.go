@@ -0,0 +1,63 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"opendi/model-hub/api/apiTypes"
+)
+
+func element(uuid string, associated string) apiTypes.DiaElement {
+	return apiTypes.DiaElement{Meta: apiTypes.Meta{UUID: uuid}, AssociatedElements: json.RawMessage(associated)}
+}
+
+func dependency(uuid string, source string, target string) apiTypes.CausalDependency {
+	return apiTypes.CausalDependency{Meta: apiTypes.Meta{UUID: uuid}, Source: source, Target: target}
+}
+
+func TestValidateReferences(t *testing.T) {
+	model := apiTypes.CausalDecisionModel{
+		Meta: apiTypes.Meta{UUID: "model"},
+		Diagrams: []apiTypes.Diagram{
+			{
+				Meta:         apiTypes.Meta{UUID: "diagram1"},
+				Elements:     []apiTypes.DiaElement{element("lever", ""), element("outcome", `["eval"]`)},
+				Dependencies: []apiTypes.CausalDependency{dependency("dependency", "lever", "outcome")},
+			},
+			{
+				Meta:     apiTypes.Meta{UUID: "diagram2"},
+				Elements: []apiTypes.DiaElement{element("eval", "[]")},
+			},
+		},
+	}
+
+	if issues := ValidateReferences(&model); len(issues) != 0 {
+		t.Fatalf("Expected no issues, got %v", issues)
+	}
+
+	// Point the dependency at an element of the other diagram, associate elements that don't exist and reuse a UUID.
+	model.Diagrams[0].Dependencies[0].Target = "eval"
+	model.Diagrams[0].Elements[1].AssociatedElements = json.RawMessage(`["missing", 0]`)
+	model.Diagrams[1].Dependencies = []apiTypes.CausalDependency{dependency("lever", "eval", "eval")}
+
+	expected := []string{
+		"/diagrams/0/elements/1/associatedEvalElements/0",
+		"/diagrams/0/elements/1/associatedEvalElements/1",
+		"/diagrams/0/dependencies/0/target",
+		"/diagrams/1/dependencies/0/meta/uuid",
+	}
+
+	issues := ValidateReferences(&model)
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), issues)
+	}
+	for i, pointer := range expected {
+		if issues[i].Pointer != pointer {
+			t.Errorf("Expected issue %d at %q, got %q: %s", i, pointer, issues[i].Pointer, issues[i].Message)
+		}
+	}
+}
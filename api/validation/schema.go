@@ -0,0 +1,162 @@
+//
+// COPYRIGHT OpenDI
+//
+
+// Package validation checks uploaded models against the OpenDI CDM JSON Schema.
+// The schemas are bundled with the API, so validation never reaches out to the network. The schemas directory holds
+// the hub's copy of the CDM schema, and hub.json describes the fields the hub adds to models on top of it.
+package validation
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"opendi/model-hub/api/apiTypes"
+
+	"github.com/qri-io/jsonschema"
+)
+
+// DefaultSchemaID is the $id of the schema models built by the API itself, such as those imported from other formats, are given.
+const DefaultSchemaID = "https://opendi.org/schemas/cdm/v1/cdm.schema.json"
+
+//go:embed schemas/*.json hub.json
+var schemaFiles embed.FS
+
+// schemas maps the $id of each bundled schema to the compiled schema.
+var schemas = map[string]*jsonschema.Schema{}
+
+// hubSchema validates the fields the hub adds to models, which are removed before validating against the CDM schema.
+var hubSchema = &jsonschema.Schema{}
+
+// hubFields are the top level fields of a model described by hubSchema.
+var hubFields = map[string]bool{}
+
+func init() {
+	raw, err := schemaFiles.ReadFile("hub.json")
+	if err != nil {
+		panic(fmt.Sprintf("error reading hub schema: %v", err))
+	}
+	var hub struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &hub); err != nil {
+		panic(fmt.Sprintf("error parsing hub schema: %v", err))
+	}
+	if err := json.Unmarshal(raw, hubSchema); err != nil {
+		panic(fmt.Sprintf("error parsing hub schema: %v", err))
+	}
+	for field := range hub.Properties {
+		hubFields[field] = true
+	}
+
+	files, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		panic(fmt.Sprintf("error reading bundled schemas: %v", err))
+	}
+
+	for _, file := range files {
+		raw, err := schemaFiles.ReadFile(path.Join("schemas", file.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("error reading bundled schema %s: %v", file.Name(), err))
+		}
+
+		var header struct {
+			ID string `json:"$id"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil || header.ID == "" {
+			panic(fmt.Sprintf("bundled schema %s has no $id", file.Name()))
+		}
+
+		schema := &jsonschema.Schema{}
+		if err := json.Unmarshal(raw, schema); err != nil {
+			panic(fmt.Sprintf("error parsing bundled schema %s: %v", file.Name(), err))
+		}
+		schemas[header.ID] = schema
+	}
+
+	if _, ok := schemas[DefaultSchemaID]; !ok {
+		panic(fmt.Sprintf("default schema %s is not bundled", DefaultSchemaID))
+	}
+}
+
+// SchemaIDs returns the $id of every bundled schema, which are the values of $schema that uploads are validated against.
+func SchemaIDs() []string {
+	ids := make([]string, 0, len(schemas))
+	for id := range schemas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ValidateSchema validates the raw JSON of a model against the bundled schema named by its $schema field, and the
+// fields the hub adds against the hub schema. Models naming a schema that isn't bundled, such as those uploaded
+// before validation existed, are passed through with only the hub fields validated.
+// Each issue points at the offending value with a JSON pointer, and issues are sorted by their pointer.
+func ValidateSchema(raw []byte) ([]apiTypes.ValidationIssue, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("error parsing model: %v", err)
+	}
+
+	var schemaID string
+	json.Unmarshal(fields["$schema"], &schemaID)
+	schema, ok := schemas[schemaID]
+
+	// Split the fields the hub adds from the model, as the CDM schema doesn't know about them.
+	hub := map[string]json.RawMessage{}
+	for field, value := range fields {
+		if hubFields[field] {
+			hub[field] = value
+			delete(fields, field)
+		}
+	}
+
+	issues, err := validate(hubSchema, hub)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		cdmIssues, err := validate(schema, fields)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, cdmIssues...)
+	}
+
+	// The validator walks properties in map order, so sort the issues to keep responses stable.
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Pointer < issues[j].Pointer
+	})
+
+	return issues, nil
+}
+
+// validate validates the fields of a model against a schema, returning an issue for each violation.
+func validate(schema *jsonschema.Schema, fields map[string]json.RawMessage) ([]apiTypes.ValidationIssue, error) {
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	keyErrors, err := schema.ValidateBytes(context.Background(), raw)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]apiTypes.ValidationIssue, 0, len(keyErrors))
+	for _, keyError := range keyErrors {
+		pointer := keyError.PropertyPath
+		if pointer == "/" {
+			pointer = ""
+		}
+		issues = append(issues, apiTypes.ValidationIssue{
+			Pointer: pointer,
+			Message: keyError.Message,
+		})
+	}
+	return issues, nil
+}
@@ -0,0 +1,91 @@
+//
+// COPYRIGHT OpenDI
+//
+
+package validation
+
+import (
+	"testing"
+)
+
+const cdmSchemaID = DefaultSchemaID
+
+func TestSchemaIDs(t *testing.T) {
+	ids := SchemaIDs()
+	if len(ids) != 1 || ids[0] != cdmSchemaID {
+		t.Errorf("Expected the bundled CDM schema, got %v", ids)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		pointers []string
+	}{
+		{
+			name: "valid model",
+			model: `{"$schema": "` + cdmSchemaID + `", "meta": {"uuid": "model"}, "diagrams": [{"meta": {"uuid": "diagram"},
+				"elements": [{"meta": {"uuid": "element"}, "causalType": "Lever", "content": {"position": {"x": 0, "y": 0}}}],
+				"dependencies": [{"meta": {"uuid": "dependency"}, "source": "element", "target": "element"}]}]}`,
+		},
+		{
+			name:  "legacy schemas are passed through",
+			model: `{"$schema": "Test Schema", "meta": {"uuid": 1}, "diagrams": [0]}`,
+		},
+		{
+			name:     "hub fields of legacy schemas",
+			model:    `{"$schema": "Test Schema", "meta": {"uuid": "model"}, "forkedFromVersion": -1}`,
+			pointers: []string{"/forkedFromVersion"},
+		},
+		{
+			name:  "hub fields",
+			model: `{"$schema": "` + cdmSchemaID + `", "meta": {"uuid": "model"}, "parentUUID": "parent", "forkedFromVersion": 2, "syncedAtVersion": 0}`,
+		},
+		{
+			name:     "invalid hub fields",
+			model:    `{"$schema": "` + cdmSchemaID + `", "meta": {"uuid": "model"}, "parentUUID": 1, "forkedFromVersion": -1}`,
+			pointers: []string{"/forkedFromVersion", "/parentUUID"},
+		},
+		{
+			name:     "missing meta",
+			model:    `{"$schema": "` + cdmSchemaID + `"}`,
+			pointers: []string{""},
+		},
+		{
+			name: "wrong types and missing fields",
+			model: `{"$schema": "` + cdmSchemaID + `", "meta": {"uuid": 1}, "diagrams": [{"meta": {"uuid": ""},
+				"elements": [{"meta": {"uuid": "element"}, "content": [0], "associatedEvalElements": [0]}],
+				"dependencies": [{"meta": {"uuid": "dependency"}, "source": "element"}]}]}`,
+			pointers: []string{
+				"/diagrams/0/dependencies/0",
+				"/diagrams/0/elements/0/associatedEvalElements/0",
+				"/diagrams/0/elements/0/content",
+				"/diagrams/0/meta/uuid",
+				"/meta/uuid",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues, err := ValidateSchema([]byte(test.model))
+			if err != nil {
+				t.Fatalf("Error validating model: %s", err)
+			}
+
+			if len(issues) != len(test.pointers) {
+				t.Fatalf("Expected %d issues, got %v", len(test.pointers), issues)
+			}
+			for i, pointer := range test.pointers {
+				if issues[i].Pointer != pointer {
+					t.Errorf("Expected issue %d at %q, got %q: %s", i, pointer, issues[i].Pointer, issues[i].Message)
+				}
+			}
+		})
+	}
+
+	if _, err := ValidateSchema([]byte("not json")); err == nil {
+		t.Errorf("Expected an error for a body that isn't JSON")
+	}
+}